@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/mdreem/s3_terraform_registry/s3"
+	"os"
+)
+
+// LoadTokensFromFile reads the token list from a local file.
+func LoadTokensFromFile(filename string) (Tokens, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return Tokens{}, fmt.Errorf("unable to read token file %s: %v", filename, err)
+	}
+
+	tokens, err := ParseTokens(string(content))
+	if err != nil {
+		return Tokens{}, fmt.Errorf("invalid token file %s: %v", filename, err)
+	}
+	return tokens, nil
+}
+
+// LoadTokensFromBucket reads the token list from an object in the bucket.
+func LoadTokensFromBucket(bucket s3.GetObject, key string) (Tokens, error) {
+	object, err := bucket.GetObject(key)
+	if err != nil {
+		return Tokens{}, err
+	}
+	defer func() { _ = object.Body.Close() }()
+
+	buf := new(bytes.Buffer)
+	if _, err := buf.ReadFrom(object.Body); err != nil {
+		return Tokens{}, fmt.Errorf("unable to read token list %s: %v", key, err)
+	}
+
+	tokens, err := ParseTokens(buf.String())
+	if err != nil {
+		return Tokens{}, fmt.Errorf("invalid token list %s: %v", key, err)
+	}
+	return tokens, nil
+}
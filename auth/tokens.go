@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+type Scope string
+
+// The scopes a token can be granted.
+const (
+	// ScopeAPI allows using the registry, module and mirror APIs.
+	ScopeAPI Scope = "api"
+	// ScopeDownload allows downloading provider and module archives.
+	ScopeDownload Scope = "download"
+	// ScopeAdmin allows administrative operations like refreshing the index. It is never granted by default.
+	ScopeAdmin Scope = "admin"
+	// ScopePublish allows publishing provider releases. It is never granted by default.
+	ScopePublish Scope = "publish"
+	// ScopeEvents allows posting S3 event notifications. It is never granted by default.
+	ScopeEvents Scope = "events"
+)
+
+// defaultScopes are granted to tokens without explicit scopes.
+var defaultScopes = []Scope{ScopeAPI, ScopeDownload}
+
+const hashPrefix = "sha256:"
+
+// Tokens is a list of tokens and the scopes granted to them. Only hashes of the tokens are kept.
+type Tokens struct {
+	entries []tokenEntry
+}
+
+type tokenEntry struct {
+	hash   [sha256.Size]byte
+	scopes map[Scope]bool
+}
+
+// ParseTokens parses a token list with one token per line, followed by an optional comma separated list of scopes:
+//
+//	<token> [<scope>,...]
+//	sha256:<hex encoded sha256 of the token> [<scope>,...]
+//
+// Tokens without scopes are granted api and download. Empty lines and lines starting with # are ignored.
+func ParseTokens(content string) (Tokens, error) {
+	tokens := Tokens{}
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) > 2 {
+			return Tokens{}, fmt.Errorf("line %d: expected a token and a list of scopes", lineNumber)
+		}
+
+		hash, err := parseTokenHash(fields[0])
+		if err != nil {
+			return Tokens{}, fmt.Errorf("line %d: %v", lineNumber, err)
+		}
+
+		scopes := defaultScopes
+		if len(fields) == 2 {
+			scopes = nil
+			for _, scope := range strings.Split(fields[1], ",") {
+				if scope == "" {
+					return Tokens{}, fmt.Errorf("line %d: empty scope", lineNumber)
+				}
+				scopes = append(scopes, Scope(scope))
+			}
+		}
+
+		entry := tokenEntry{hash: hash, scopes: make(map[Scope]bool)}
+		for _, scope := range scopes {
+			entry.scopes[scope] = true
+		}
+		tokens.entries = append(tokens.entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return Tokens{}, err
+	}
+
+	return tokens, nil
+}
+
+func parseTokenHash(field string) ([sha256.Size]byte, error) {
+	if !strings.HasPrefix(field, hashPrefix) {
+		return sha256.Sum256([]byte(field)), nil
+	}
+
+	var hash [sha256.Size]byte
+	decoded, err := hex.DecodeString(strings.TrimPrefix(field, hashPrefix))
+	if err != nil || len(decoded) != sha256.Size {
+		return hash, fmt.Errorf("%s is not a hex encoded sha256 hash", field)
+	}
+	copy(hash[:], decoded)
+	return hash, nil
+}
+
+// Lookup returns the scopes granted to token. ok is false if the token is unknown.
+func (tokens Tokens) Lookup(token string) (map[Scope]bool, bool) {
+	hash := sha256.Sum256([]byte(token))
+
+	var scopes map[Scope]bool
+	for _, entry := range tokens.entries {
+		if subtle.ConstantTimeCompare(hash[:], entry.hash[:]) == 1 {
+			scopes = entry.scopes
+		}
+	}
+	return scopes, scopes != nil
+}
+
+// Len returns the number of tokens.
+func (tokens Tokens) Len() int {
+	return len(tokens.entries)
+}
@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"github.com/mdreem/s3_terraform_registry/internal/testsupport"
+	"github.com/mdreem/s3_terraform_registry/s3"
+	"reflect"
+	"testing"
+)
+
+const tokenList = `# tokens of the black lodge
+owl-cave
+sha256:5ae72103df07586935fec90b195d4f125e42b56a8003b92825a8b24551b7303b download
+
+red-room api
+`
+
+func TestTokens_Lookup(t *testing.T) {
+	tokens, err := ParseTokens(tokenList)
+	if err != nil {
+		t.Fatalf("ParseTokens() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		token      string
+		wantScopes map[Scope]bool
+		wantOk     bool
+	}{
+		{
+			name:       "token without scopes",
+			token:      "owl-cave",
+			wantScopes: map[Scope]bool{ScopeAPI: true, ScopeDownload: true},
+			wantOk:     true,
+		},
+		{
+			name:       "hashed token",
+			token:      "315-coffee",
+			wantScopes: map[Scope]bool{ScopeDownload: true},
+			wantOk:     true,
+		},
+		{
+			name:       "token with scope",
+			token:      "red-room",
+			wantScopes: map[Scope]bool{ScopeAPI: true},
+			wantOk:     true,
+		},
+		{
+			name:   "unknown token",
+			token:  "white-lodge",
+			wantOk: false,
+		},
+		{
+			name:   "hash is not a token",
+			token:  "sha256:5ae72103df07586935fec90b195d4f125e42b56a8003b92825a8b24551b7303b",
+			wantOk: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scopes, ok := tokens.Lookup(tt.token)
+			if ok != tt.wantOk {
+				t.Errorf("Lookup() ok = %v, wantOk %v", ok, tt.wantOk)
+				return
+			}
+			if !reflect.DeepEqual(scopes, tt.wantScopes) {
+				t.Errorf("Lookup() scopes = %v, want %v", scopes, tt.wantScopes)
+			}
+		})
+	}
+}
+
+func TestParseTokens_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "invalid hash", content: "sha256:315"},
+		{name: "too many fields", content: "owl-cave api download"},
+		{name: "empty scope", content: "owl-cave api,"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseTokens(tt.content); err == nil {
+				t.Errorf("ParseTokens() expected error")
+			}
+		})
+	}
+}
+
+func TestLoadTokensFromBucket(t *testing.T) {
+	bucket := testsupport.NewTestBucketWithObjects(nil, map[string]s3.BucketObject{
+		"auth/tokens": {Body: testsupport.CreateReaderFor(tokenList)},
+	})
+
+	tokens, err := LoadTokensFromBucket(bucket, "auth/tokens")
+	if err != nil {
+		t.Fatalf("LoadTokensFromBucket() error = %v", err)
+	}
+	if tokens.Len() != 3 {
+		t.Errorf("LoadTokensFromBucket() loaded %d tokens, want 3", tokens.Len())
+	}
+
+	if _, err := LoadTokensFromBucket(bucket, "auth/missing"); err == nil {
+		t.Errorf("LoadTokensFromBucket() of missing key expected error")
+	}
+}
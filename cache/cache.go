@@ -3,98 +3,527 @@ package cache
 import (
 	"fmt"
 	"github.com/mdreem/s3_terraform_registry/logger"
+	"github.com/mdreem/s3_terraform_registry/moduledata"
 	"github.com/mdreem/s3_terraform_registry/providerdata"
+	"github.com/mdreem/s3_terraform_registry/registryerror"
 	"github.com/mdreem/s3_terraform_registry/s3"
 	"github.com/mdreem/s3_terraform_registry/schema"
-	"regexp"
+	"golang.org/x/sync/singleflight"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type Cache interface {
 	Refresh() error
+	// RefreshProvider re-indexes a single provider and publishes it without refreshing the whole index.
+	RefreshProvider(namespace string, providerType string) error
+	// RefreshModule re-indexes a single module and publishes it without refreshing the whole index.
+	RefreshModule(namespace string, name string, system string) error
+	Status() Status
+}
+
+// Status describes the outcome of the refreshes of a cache.
+type Status struct {
+	// Generation is incremented every time a new index is published.
+	Generation  uint64
+	LastSuccess time.Time
+	LastAttempt time.Time
+	// LastError is the error of the last refresh or nil if it succeeded.
+	LastError error
+	// Providers and Modules are the number of providers and modules in the published index.
+	Providers int
+	Modules   int
 }
 
 type CacheableProviderData interface {
 	providerdata.ProviderData
+	moduledata.ModuleData
 	Cache
 }
 
+// DefaultDownloadCacheSize is the number of versions whose download metadata is cached if nothing else is configured.
+const DefaultDownloadCacheSize = 1000
+
 type s3ProviderData struct {
-	providerData providerdata.ProviderData
-	cachedResult cachedResult
-	bucket       s3.ListObjects
+	providerData      providerdata.Backend
+	moduleData        moduledata.Backend
+	bucket            s3.ListObjects
+	downloadCacheSize int
+
+	// snapshot holds the currently published index. Readers load it without locking, refreshes build a new one
+	// off to the side and swap it in.
+	snapshot atomic.Pointer[snapshot]
+	// refreshLock serializes refreshes, so a slow refresh cannot publish over the result of a later one.
+	refreshLock sync.Mutex
+	status      atomic.Pointer[Status]
+	// metadataLoads coalesces concurrent loads of the same metadata, so a burst of requests for a version which is
+	// not cached yet reads it from the bucket only once.
+	metadataLoads singleflight.Group
+}
+
+// snapshot is one generation of the index. Its versions must not be modified after it has been published. The
+// download metadata belongs to the generation, so it is invalidated whenever a new snapshot is published.
+type snapshot struct {
+	generation uint64
+	versions   map[string]map[string]schema.ProviderVersions
+	// modules are keyed by <namespace>/<name>/<system>.
+	modules   map[string]moduledata.Module
+	downloads *metadataCache
+}
+
+type Option func(cache *s3ProviderData)
+
+// WithModules indexes and serves the modules stored in the bucket as well.
+func WithModules(moduleData moduledata.Backend) Option {
+	return func(cache *s3ProviderData) {
+		cache.moduleData = moduleData
+	}
 }
 
-type cachedResult struct {
-	versions map[string]map[string]schema.ProviderVersions
+// WithDownloadCacheSize sets the number of versions whose download metadata is cached. Caching is disabled if size is 0.
+func WithDownloadCacheSize(size int) Option {
+	return func(cache *s3ProviderData) {
+		cache.downloadCacheSize = size
+	}
 }
 
-func NewCache(client providerdata.ProviderData, bucketReader s3.ListObjects) CacheableProviderData {
-	return &s3ProviderData{
-		providerData: client,
-		cachedResult: cachedResult{},
-		bucket:       bucketReader,
+func NewCache(client providerdata.Backend, bucketReader s3.ListObjects, options ...Option) CacheableProviderData {
+	cache := &s3ProviderData{
+		providerData:      client,
+		bucket:            bucketReader,
+		downloadCacheSize: DefaultDownloadCacheSize,
+	}
+	for _, option := range options {
+		option(cache)
 	}
+
+	cache.snapshot.Store(cache.newSnapshot(0, make(map[string]map[string]schema.ProviderVersions), make(map[string]moduledata.Module)))
+	cache.status.Store(&Status{})
+	return cache
 }
 
-func (cache s3ProviderData) ListVersions(namespace string, providerType string) (schema.ProviderVersions, error) {
-	namespaceData, ok := cache.cachedResult.versions[namespace]
+func (cache *s3ProviderData) newSnapshot(generation uint64, versions map[string]map[string]schema.ProviderVersions, modules map[string]moduledata.Module) *snapshot {
+	return &snapshot{
+		generation: generation,
+		versions:   versions,
+		modules:    modules,
+		downloads:  newMetadataCache(cache.downloadCacheSize),
+	}
+}
+
+func (cache *s3ProviderData) ListVersions(namespace string, providerType string) (schema.ProviderVersions, error) {
+	currentSnapshot := cache.snapshot.Load()
+
+	namespaceData, ok := currentSnapshot.versions[namespace]
 	if !ok {
-		return schema.ProviderVersions{}, fmt.Errorf("unable to find data for namespace %s", namespace)
+		return schema.ProviderVersions{}, registryerror.NotFound(nil, "namespace %s does not exist", namespace)
 	}
 
 	providerData, ok := namespaceData[providerType]
 	if !ok {
-		return schema.ProviderVersions{}, fmt.Errorf("unable to find data for type %s", providerType)
+		return schema.ProviderVersions{}, registryerror.NotFound(nil, "provider %s/%s does not exist", namespace, providerType)
 	}
 	return providerData, nil
 }
 
-func (cache s3ProviderData) GetDownloadData(namespace string, providerType string, version string, os string, arch string) (schema.DownloadData, error) {
-	return cache.providerData.GetDownloadData(namespace, providerType, version, os, arch)
+// indexedVersion checks that the version is part of the current index. Versions which are hidden, e.g. because their
+// signature does not verify, are not served at all.
+func (cache *s3ProviderData) indexedVersion(namespace string, providerType string, version string) error {
+	providerVersions, err := cache.ListVersions(namespace, providerType)
+	if err != nil {
+		return err
+	}
+	for _, providerVersion := range providerVersions.Versions {
+		if providerVersion.Version == version {
+			return nil
+		}
+	}
+	return registryerror.NotFound(nil, "version %s of provider %s/%s does not exist", version, namespace, providerType)
+}
+
+func (cache *s3ProviderData) GetDownloadData(namespace string, providerType string, version string, os string, arch string) (schema.DownloadData, error) {
+	if err := cache.indexedVersion(namespace, providerType, version); err != nil {
+		return schema.DownloadData{}, err
+	}
+	metadata, err := cache.versionMetadata(namespace, providerType, version)
+	if err != nil {
+		return schema.DownloadData{}, err
+	}
+	return cache.providerData.DownloadData(namespace, providerType, version, os, arch, metadata)
+}
+
+func (cache *s3ProviderData) GetMirrorArchives(namespace string, providerType string, version string) (schema.MirrorArchives, error) {
+	if err := cache.indexedVersion(namespace, providerType, version); err != nil {
+		return schema.MirrorArchives{}, err
+	}
+	metadata, err := cache.mirrorMetadata(namespace, providerType, version)
+	if err != nil {
+		return schema.MirrorArchives{}, err
+	}
+	return cache.providerData.MirrorArchives(namespace, providerType, version, metadata)
+}
+
+// mirrorKeySuffix is appended to the keys of the metadata read for the mirror only. Versions contain no slashes, so
+// the keys differ from the ones of the download metadata but share the prefix of their provider.
+const mirrorKeySuffix = "/mirror"
+
+// versionMetadata returns the metadata of the version from the download cache of the current snapshot, reading it
+// from storage on a miss.
+func (cache *s3ProviderData) versionMetadata(namespace string, providerType string, version string) (schema.VersionMetadata, error) {
+	return cache.cachedMetadata(fmt.Sprintf("%s/%s/%s", namespace, providerType, version), func() (schema.VersionMetadata, error) {
+		return cache.providerData.GetVersionMetadata(namespace, providerType, version)
+	})
+}
+
+// mirrorMetadata returns the metadata of the version needed by the mirror. Cached download metadata is used if
+// available, otherwise only the hashes are read, so versions without signing keys can be mirrored as well.
+func (cache *s3ProviderData) mirrorMetadata(namespace string, providerType string, version string) (schema.VersionMetadata, error) {
+	key := fmt.Sprintf("%s/%s/%s", namespace, providerType, version)
+	if metadata, ok := cache.snapshot.Load().downloads.get(key); ok {
+		return metadata, nil
+	}
+	return cache.cachedMetadata(key+mirrorKeySuffix, func() (schema.VersionMetadata, error) {
+		return cache.providerData.GetMirrorMetadata(namespace, providerType, version)
+	})
+}
+
+// cachedMetadata returns the metadata stored under key in the download cache of the current snapshot, loading it on a
+// miss. Concurrent misses of the same key share one load.
+func (cache *s3ProviderData) cachedMetadata(key string, load func() (schema.VersionMetadata, error)) (schema.VersionMetadata, error) {
+	currentSnapshot := cache.snapshot.Load()
+
+	metadata, ok := currentSnapshot.downloads.get(key)
+	if ok {
+		logger.Sugar.Debugw("download metadata cache hit", "version", key, "generation", currentSnapshot.generation)
+		return metadata, nil
+	}
+	logger.Sugar.Debugw("download metadata cache miss", "version", key, "generation", currentSnapshot.generation)
+
+	loaded, err, shared := cache.metadataLoads.Do(fmt.Sprintf("%d/%s", currentSnapshot.generation, key), func() (interface{}, error) {
+		metadata, err := load()
+		if err != nil {
+			return schema.VersionMetadata{}, err
+		}
+		currentSnapshot.downloads.add(key, metadata)
+		return metadata, nil
+	})
+	if shared {
+		logger.Sugar.Debugw("download metadata loaded by concurrent request", "version", key, "generation", currentSnapshot.generation)
+	}
+	if err != nil {
+		return schema.VersionMetadata{}, err
+	}
+	return loaded.(schema.VersionMetadata), nil
+}
+
+func (cache *s3ProviderData) Proxy(namespace string, providerType string, version string, filename string, options s3.GetObjectOptions) (schema.ProxyResponse, error) {
+	if err := cache.indexedVersion(namespace, providerType, version); err != nil {
+		return schema.ProxyResponse{}, err
+	}
+	return cache.providerData.Proxy(namespace, providerType, version, filename, options)
+}
+
+func (cache *s3ProviderData) ListModuleVersions(namespace string, name string, system string) (schema.ModuleVersions, error) {
+	module, ok := cache.snapshot.Load().modules[moduleID(namespace, name, system)]
+	if !ok {
+		return schema.ModuleVersions{}, registryerror.NotFound(nil, "module %s/%s/%s does not exist", namespace, name, system)
+	}
+	return module.ModuleVersions(), nil
+}
+
+func (cache *s3ProviderData) GetModuleDownloadURL(namespace string, name string, system string, version string) (string, error) {
+	module, ok := cache.snapshot.Load().modules[moduleID(namespace, name, system)]
+	if !ok {
+		return "", registryerror.NotFound(nil, "module %s/%s/%s does not exist", namespace, name, system)
+	}
+
+	moduleVersion, ok := module.Find(version)
+	if !ok {
+		return "", registryerror.NotFound(nil, "version %s of module %s/%s/%s does not exist", version, namespace, name, system)
+	}
+	return cache.moduleData.ArchiveURL(namespace, name, system, moduleVersion), nil
 }
 
-func (cache s3ProviderData) Proxy(namespace string, providerType string, version string, os string) (schema.ProxyResponse, error) {
-	return cache.providerData.Proxy(namespace, providerType, version, os)
+// ProxyModule only gets archives of versions contained in the index, so the archive has to be the one indexed for the
+// version.
+func (cache *s3ProviderData) ProxyModule(namespace string, name string, system string, version string, filename string, options s3.GetObjectOptions) (schema.ProxyResponse, error) {
+	module, ok := cache.snapshot.Load().modules[moduleID(namespace, name, system)]
+	if !ok || cache.moduleData == nil {
+		return schema.ProxyResponse{}, registryerror.NotFound(nil, "module %s/%s/%s does not exist", namespace, name, system)
+	}
+
+	moduleVersion, ok := module.FindArchive(version, filename)
+	if !ok {
+		return schema.ProxyResponse{}, moduledata.ArchiveNotFound(namespace, name, system, version, filename)
+	}
+	return cache.moduleData.ProxyArchive(namespace, name, system, moduleVersion, options)
 }
 
 func (cache *s3ProviderData) Refresh() error {
-	r := regexp.MustCompile(`^(?P<namespace>[^/]*)/(?P<type>[^/]*)/`)
-	names := r.SubexpNames()
+	cache.refreshLock.Lock()
+	defer cache.refreshLock.Unlock()
+
+	status := cache.Status()
+	status.LastAttempt = time.Now()
 
-	versionData := cachedResult{
-		versions: make(map[string]map[string]schema.ProviderVersions),
+	versions, modules, err := cache.buildIndex()
+	if err != nil {
+		status.LastError = err
+		cache.status.Store(&status)
+		return err
 	}
 
-	objects, err := cache.bucket.ListObjects()
+	generation := cache.snapshot.Load().generation + 1
+	cache.snapshot.Store(cache.newSnapshot(generation, versions, modules))
+	logger.Sugar.Infow("published new index", "generation", generation)
+
+	status.Generation = generation
+	status.LastSuccess = status.LastAttempt
+	status.LastError = nil
+	status.Providers = countProviders(versions)
+	status.Modules = len(modules)
+	cache.status.Store(&status)
+	return nil
+}
+
+func (cache *s3ProviderData) RefreshProvider(namespace string, providerType string) error {
+	cache.refreshLock.Lock()
+	defer cache.refreshLock.Unlock()
+
+	prefix := fmt.Sprintf("%s/%s/", namespace, providerType)
+	listedObjects, eTags, err := s3.ListObjectsWithETags(cache.bucket, prefix)
 	if err != nil {
-		logger.Sugar.Errorw("an error occurred when listing objects in S3", "error", err)
+		logger.Sugar.Errorw("an error occurred when listing objects in S3", "prefix", prefix, "error", err)
 		return err
 	}
+	// inherited keys of the provider type do not make it a provider, the same as for a full refresh
+	objects := make([]string, 0, len(listedObjects))
+	for _, object := range listedObjects {
+		if !providerdata.IsInheritedKeyFile(object) {
+			objects = append(objects, object)
+		}
+	}
+
+	var providerVersions schema.ProviderVersions
+	if len(objects) > 0 {
+		providerVersions, err = cache.providerData.VersionsFromObjects(namespace, providerType, objects, eTags)
+		if err != nil {
+			logger.Sugar.Errorw("an error occurred when updating listing versions", "prefix", prefix, "error", err)
+			return err
+		}
+	}
+
+	currentSnapshot := cache.snapshot.Load()
+
+	// only the maps on the path to the provider are copied, all other entries are shared with the current snapshot
+	versions := make(map[string]map[string]schema.ProviderVersions, len(currentSnapshot.versions))
+	for existingNamespace, providers := range currentSnapshot.versions {
+		versions[existingNamespace] = providers
+	}
+	providers := make(map[string]schema.ProviderVersions, len(versions[namespace])+1)
+	for existingType, existingVersions := range versions[namespace] {
+		providers[existingType] = existingVersions
+	}
+
+	if len(objects) > 0 {
+		providers[providerType] = providerVersions
+	} else {
+		delete(providers, providerType)
+	}
+	if len(providers) > 0 {
+		versions[namespace] = providers
+	} else {
+		delete(versions, namespace)
+	}
+
+	generation := currentSnapshot.generation + 1
+	cache.snapshot.Store(&snapshot{
+		generation: generation,
+		versions:   versions,
+		modules:    currentSnapshot.modules,
+		downloads:  currentSnapshot.downloads.copyWithout(prefix),
+	})
+	logger.Sugar.Infow("published provider", "namespace", namespace, "type", providerType, "generation", generation)
+
+	status := cache.Status()
+	status.Generation = generation
+	status.Providers = countProviders(versions)
+	cache.status.Store(&status)
+	return nil
+}
+
+func (cache *s3ProviderData) RefreshModule(namespace string, name string, system string) error {
+	if cache.moduleData == nil {
+		return nil
+	}
 
-	for _, item := range objects {
-		logger.Sugar.Debugw("checking item", "item", item)
-		if r.MatchString(item) {
-			result := r.FindAllStringSubmatch(item, -1)
-			matches := map[string]string{}
-			for i, n := range result[0] {
-				matches[names[i]] = n
-			}
+	cache.refreshLock.Lock()
+	defer cache.refreshLock.Unlock()
 
-			listVersions, err := cache.providerData.ListVersions(matches["namespace"], matches["type"])
-			if err != nil {
-				logger.Sugar.Errorw("an error occurred when updating listing versions", "error", err)
-				return err
-			}
+	prefix := moduledata.ModulePrefix(namespace, name, system)
+	objects, err := cache.bucket.ListObjectsWithPrefix(prefix, "")
+	if err != nil {
+		logger.Sugar.Errorw("an error occurred when listing objects in S3", "prefix", prefix, "error", err)
+		return err
+	}
 
-			_, ok := versionData.versions[matches["namespace"]]
-			if !ok {
-				versionData.versions[matches["namespace"]] = make(map[string]schema.ProviderVersions)
-			}
+	currentSnapshot := cache.snapshot.Load()
+	modules := make(map[string]moduledata.Module, len(currentSnapshot.modules)+1)
+	for id, module := range currentSnapshot.modules {
+		modules[id] = module
+	}
 
-			versionData.versions[matches["namespace"]][matches["type"]] = listVersions
+	id := moduleID(namespace, name, system)
+	if len(objects) > 0 {
+		module, err := cache.moduleData.ModuleFromObjects(namespace, name, system, objects)
+		if err != nil {
+			logger.Sugar.Errorw("an error occurred when indexing module", "prefix", prefix, "error", err)
+			return err
 		}
+		modules[id] = module
+	} else {
+		delete(modules, id)
 	}
 
-	cache.cachedResult = versionData
+	generation := currentSnapshot.generation + 1
+	cache.snapshot.Store(&snapshot{
+		generation: generation,
+		versions:   currentSnapshot.versions,
+		modules:    modules,
+		downloads:  currentSnapshot.downloads,
+	})
+	logger.Sugar.Infow("published module", "module", id, "generation", generation)
+
+	status := cache.Status()
+	status.Generation = generation
+	status.Modules = len(modules)
+	cache.status.Store(&status)
 	return nil
 }
+
+func (cache *s3ProviderData) Status() Status {
+	status := cache.status.Load()
+	if status == nil {
+		return Status{Generation: cache.snapshot.Load().generation}
+	}
+	return *status
+}
+
+func countProviders(versions map[string]map[string]schema.ProviderVersions) int {
+	count := 0
+	for _, providers := range versions {
+		count += len(providers)
+	}
+	return count
+}
+
+// buildIndex lists the bucket once and builds the versions of every provider and module from that listing. The ETags
+// of the listing, if the storage lists them, let the providers reuse results of previous refreshes.
+func (cache *s3ProviderData) buildIndex() (map[string]map[string]schema.ProviderVersions, map[string]moduledata.Module, error) {
+	objects, eTags, err := s3.ListObjectsWithETags(cache.bucket, "")
+	if err != nil {
+		logger.Sugar.Errorw("an error occurred when listing objects in S3", "error", err)
+		return nil, nil, err
+	}
+
+	providerKeys := make([]string, 0, len(objects))
+	moduleKeys := make([]string, 0)
+	for _, object := range objects {
+		if strings.HasPrefix(object, moduledata.Prefix) {
+			moduleKeys = append(moduleKeys, object)
+		} else {
+			providerKeys = append(providerKeys, object)
+		}
+	}
+
+	versions := make(map[string]map[string]schema.ProviderVersions)
+	for _, provider := range groupByProvider(providerKeys) {
+		logger.Sugar.Debugw("indexing provider", "namespace", provider.namespace, "type", provider.providerType, "objects", len(provider.objects))
+
+		providerVersions, err := cache.providerData.VersionsFromObjects(provider.namespace, provider.providerType, provider.objects, eTags)
+		if err != nil {
+			logger.Sugar.Errorw("an error occurred when updating listing versions", "error", err)
+			return nil, nil, err
+		}
+
+		_, ok := versions[provider.namespace]
+		if !ok {
+			versions[provider.namespace] = make(map[string]schema.ProviderVersions)
+		}
+
+		versions[provider.namespace][provider.providerType] = providerVersions
+	}
+
+	modules, err := cache.buildModuleIndex(moduleKeys)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return versions, modules, nil
+}
+
+func (cache *s3ProviderData) buildModuleIndex(objects []string) (map[string]moduledata.Module, error) {
+	modules := make(map[string]moduledata.Module)
+	if cache.moduleData == nil {
+		return modules, nil
+	}
+
+	objectsByModule := make(map[moduledata.ModuleKey][]string)
+	for _, object := range objects {
+		moduleKey, ok := moduledata.ParseModuleKey(object)
+		if !ok {
+			continue
+		}
+		id := moduledata.ModuleKey{Namespace: moduleKey.Namespace, Name: moduleKey.Name, System: moduleKey.System}
+		objectsByModule[id] = append(objectsByModule[id], object)
+	}
+
+	for id, moduleObjects := range objectsByModule {
+		logger.Sugar.Debugw("indexing module", "namespace", id.Namespace, "name", id.Name, "system", id.System, "objects", len(moduleObjects))
+
+		module, err := cache.moduleData.ModuleFromObjects(id.Namespace, id.Name, id.System, moduleObjects)
+		if err != nil {
+			logger.Sugar.Errorw("an error occurred when indexing module", "error", err)
+			return nil, err
+		}
+		modules[moduleID(id.Namespace, id.Name, id.System)] = module
+	}
+	return modules, nil
+}
+
+func moduleID(namespace string, name string, system string) string {
+	return fmt.Sprintf("%s/%s/%s", namespace, name, system)
+}
+
+type providerObjects struct {
+	namespace    string
+	providerType string
+	objects      []string
+}
+
+// groupByProvider groups all keys of the form <namespace>/<type>/... by their provider, keeping the order in which
+// the providers first appear.
+func groupByProvider(objects []string) []*providerObjects {
+	providers := make([]*providerObjects, 0)
+	providersByPrefix := make(map[string]*providerObjects)
+
+	for _, object := range objects {
+		parts := strings.SplitN(object, "/", 3)
+		if len(parts) < 3 || parts[0] == "" || parts[1] == "" || providerdata.IsInheritedKeyFile(object) {
+			continue
+		}
+
+		prefix := parts[0] + "/" + parts[1]
+		provider, ok := providersByPrefix[prefix]
+		if !ok {
+			provider = &providerObjects{namespace: parts[0], providerType: parts[1]}
+			providersByPrefix[prefix] = provider
+			providers = append(providers, provider)
+		}
+		provider.objects = append(provider.objects, object)
+	}
+
+	return providers
+}
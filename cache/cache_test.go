@@ -1,13 +1,20 @@
 package cache
 
 import (
+	"errors"
 	"fmt"
 	"github.com/mdreem/s3_terraform_registry/internal/testsupport"
+	"github.com/mdreem/s3_terraform_registry/moduledata"
 	"github.com/mdreem/s3_terraform_registry/providerdata"
+	"github.com/mdreem/s3_terraform_registry/registryerror"
 	"github.com/mdreem/s3_terraform_registry/s3"
 	"github.com/mdreem/s3_terraform_registry/schema"
 	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func defaultBucketContent() []string {
@@ -74,10 +81,19 @@ func listVersionsData() map[string]map[string]schema.ProviderVersions {
 	}
 }
 
+func newTestCache(providerData providerdata.Backend, bucket s3.ListObjects, versions map[string]map[string]schema.ProviderVersions) *s3ProviderData {
+	cache := &s3ProviderData{
+		providerData: providerData,
+		bucket:       bucket,
+	}
+	cache.snapshot.Store(cache.newSnapshot(1, versions, nil))
+	return cache
+}
+
 func TestS3ProviderData_ListVersions(t *testing.T) {
 	type fields struct {
-		providerData providerdata.ProviderData
-		cachedResult cachedResult
+		providerData providerdata.Backend
+		versions     map[string]map[string]schema.ProviderVersions
 		bucket       s3.ListObjects
 	}
 	type args struct {
@@ -95,10 +111,8 @@ func TestS3ProviderData_ListVersions(t *testing.T) {
 			name: "fetch cached result for versions data",
 			fields: fields{
 				providerData: nil,
-				cachedResult: cachedResult{
-					versions: listVersionsData(),
-				},
-				bucket: nil,
+				versions:     listVersionsData(),
+				bucket:       nil,
 			},
 			args: args{
 				namespace:    "black",
@@ -111,10 +125,8 @@ func TestS3ProviderData_ListVersions(t *testing.T) {
 			name: "fail to fetch cached result for versions data",
 			fields: fields{
 				providerData: nil,
-				cachedResult: cachedResult{
-					versions: listVersionsData(),
-				},
-				bucket: nil,
+				versions:     listVersionsData(),
+				bucket:       nil,
 			},
 			args: args{
 				namespace:    "twin",
@@ -126,11 +138,7 @@ func TestS3ProviderData_ListVersions(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			cache := s3ProviderData{
-				providerData: tt.fields.providerData,
-				cachedResult: tt.fields.cachedResult,
-				bucket:       tt.fields.bucket,
-			}
+			cache := newTestCache(tt.fields.providerData, tt.fields.bucket, tt.fields.versions)
 			got, err := cache.ListVersions(tt.args.namespace, tt.args.providerType)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("listVersions() error = %v, wantErr %v", err, tt.wantErr)
@@ -145,76 +153,513 @@ func TestS3ProviderData_ListVersions(t *testing.T) {
 
 func TestS3ProviderData_Refresh(t *testing.T) {
 	type fields struct {
-		providerData providerdata.ProviderData
-		cachedResult cachedResult
+		providerData providerdata.Backend
+		versions     map[string]map[string]schema.ProviderVersions
 		bucket       s3.ListObjects
 	}
 	tests := []struct {
-		name             string
-		fields           fields
-		wantErr          bool
-		wantCachedResult cachedResult
+		name           string
+		fields         fields
+		wantErr        bool
+		wantVersions   map[string]map[string]schema.ProviderVersions
+		wantGeneration uint64
+		wantProviders  int
 	}{
 		{
 			name: "test refreshing data in bucket",
 			fields: fields{
 				providerData: testsupport.NewTestProviderData(),
-				cachedResult: cachedResult{
-					versions: defaultVersions(),
-				},
-				bucket: testsupport.NewTestBucket(defaultBucketContent()),
+				versions:     defaultVersions(),
+				bucket:       testsupport.NewTestBucket(defaultBucketContent()),
 			},
 			wantErr: false,
-			wantCachedResult: cachedResult{
-				map[string]map[string]schema.ProviderVersions{
-					"some_namespace": {
-						"some_type": {
-							ID: "some_namespace",
-							Versions: []schema.ProviderVersion{
-								{
-									Version:   "1.0.0",
-									Protocols: []string{"4.0", "5.0"},
-									Platforms: []schema.Platform{{
-										Os:   "linux",
-										Arch: "amd64",
-									}},
-								},
+			wantVersions: map[string]map[string]schema.ProviderVersions{
+				"some_namespace": {
+					"some_type": {
+						ID: "some_namespace",
+						Versions: []schema.ProviderVersion{
+							{
+								Version:   "1.0.0",
+								Protocols: []string{"4.0", "5.0"},
+								Platforms: []schema.Platform{{
+									Os:   "linux",
+									Arch: "amd64",
+								}},
 							},
-							Warnings: nil,
 						},
+						Warnings: nil,
 					},
 				},
 			},
+			wantGeneration: 2,
+			wantProviders:  1,
 		},
 		{
-			name: "test refreshing data in bucket",
+			name: "keep previous snapshot if refreshing fails",
 			fields: fields{
 				providerData: testsupport.NewTestProviderData(),
-				cachedResult: cachedResult{
-					versions: defaultVersions(),
-				},
-				bucket: testsupport.NewTestBucket(errorBucketContent()),
-			},
-			wantErr: true,
-			wantCachedResult: cachedResult{
-				versions: defaultVersions(),
+				versions:     defaultVersions(),
+				bucket:       testsupport.NewTestBucket(errorBucketContent()),
 			},
+			wantErr:        true,
+			wantVersions:   defaultVersions(),
+			wantGeneration: 1,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			cache := s3ProviderData{
-				providerData: tt.fields.providerData,
-				cachedResult: tt.fields.cachedResult,
-				bucket:       tt.fields.bucket,
-			}
+			cache := newTestCache(tt.fields.providerData, tt.fields.bucket, tt.fields.versions)
 			if err := cache.Refresh(); (err != nil) != tt.wantErr {
 				t.Errorf("Refresh() error = %v, wantErr %v", err, tt.wantErr)
 			}
 
-			if !reflect.DeepEqual(cache.cachedResult, tt.wantCachedResult) {
-				t.Errorf("Refresh() updated to %v\n, want = %v", cache.cachedResult, tt.wantCachedResult)
+			status := cache.Status()
+			if (status.LastError != nil) != tt.wantErr {
+				t.Errorf("Status() last error = %v, wantErr %v", status.LastError, tt.wantErr)
+			}
+			if status.LastAttempt.IsZero() || status.LastSuccess.IsZero() == !tt.wantErr {
+				t.Errorf("Status() last attempt = %v, last success = %v", status.LastAttempt, status.LastSuccess)
+			}
+			if status.Generation != tt.wantGeneration {
+				t.Errorf("Status() generation = %v, want = %v", status.Generation, tt.wantGeneration)
+			}
+			if status.Providers != tt.wantProviders {
+				t.Errorf("Status() providers = %v, want = %v", status.Providers, tt.wantProviders)
+			}
+
+			currentSnapshot := cache.snapshot.Load()
+			if !reflect.DeepEqual(currentSnapshot.versions, tt.wantVersions) {
+				t.Errorf("Refresh() updated to %v\n, want = %v", currentSnapshot.versions, tt.wantVersions)
+			}
+			if currentSnapshot.generation != tt.wantGeneration {
+				t.Errorf("Refresh() generation = %v, want = %v", currentSnapshot.generation, tt.wantGeneration)
 			}
 		})
 	}
 }
+
+// countingProviderData answers every VersionsFromObjects call with the number of calls made so far as ID.
+type countingProviderData struct {
+	testsupport.TestProviderData
+	calls atomic.Int64
+}
+
+func (providerData *countingProviderData) VersionsFromObjects(_ string, _ string, _ []string, _ map[string]string) (schema.ProviderVersions, error) {
+	return schema.ProviderVersions{ID: strconv.FormatInt(providerData.calls.Add(1), 10)}, nil
+}
+
+// countingMetadataProviderData counts how often version metadata is read from storage.
+type countingMetadataProviderData struct {
+	testsupport.TestProviderData
+	calls       atomic.Int64
+	mirrorCalls atomic.Int64
+	// release blocks reading version metadata until it is closed, if set.
+	release chan struct{}
+}
+
+// VersionsFromObjects lists the versions 1.0.0 and 1.0.1 of every provider.
+func (providerData *countingMetadataProviderData) VersionsFromObjects(namespace string, providerType string, _ []string, _ map[string]string) (schema.ProviderVersions, error) {
+	return schema.ProviderVersions{
+		ID:       namespace + "/" + providerType,
+		Versions: []schema.ProviderVersion{{Version: "1.0.0"}, {Version: "1.0.1"}},
+	}, nil
+}
+
+// metadataBucketContent contains the providers served by countingMetadataProviderData.
+func metadataBucketContent() []string {
+	return []string{
+		"black/lodge/1.0.0/terraform-provider-lodge_1.0.0_linux_amd64.zip",
+		"UPSTREAM_ERROR_PROVIDER/lodge/1.0.0/terraform-provider-lodge_1.0.0_linux_amd64.zip",
+	}
+}
+
+func (providerData *countingMetadataProviderData) GetVersionMetadata(namespace string, providerType string, version string) (schema.VersionMetadata, error) {
+	providerData.calls.Add(1)
+	if providerData.release != nil {
+		<-providerData.release
+	}
+	return providerData.TestProviderData.GetVersionMetadata(namespace, providerType, version)
+}
+
+func (providerData *countingMetadataProviderData) GetMirrorMetadata(namespace string, providerType string, version string) (schema.VersionMetadata, error) {
+	providerData.mirrorCalls.Add(1)
+	return providerData.TestProviderData.GetMirrorMetadata(namespace, providerType, version)
+}
+
+func TestS3ProviderData_GetDownloadDataIsCachedPerVersion(t *testing.T) {
+	providerData := &countingMetadataProviderData{}
+	cache := NewCache(providerData, testsupport.NewTestBucket(metadataBucketContent()), WithDownloadCacheSize(1))
+	if err := cache.Refresh(); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	download := func(version string, os string) {
+		if _, err := cache.GetDownloadData("black", "lodge", version, os, "amd64"); err != nil {
+			t.Fatalf("GetDownloadData() error = %v", err)
+		}
+	}
+	expectCalls := func(want int64) {
+		if calls := providerData.calls.Load(); calls != want {
+			t.Errorf("read version metadata %d times, want %d", calls, want)
+		}
+	}
+
+	download("1.0.0", "linux")
+	download("1.0.0", "windows")
+	expectCalls(1)
+
+	// the cache only holds one version
+	download("1.0.1", "linux")
+	download("1.0.0", "linux")
+	expectCalls(3)
+
+	if err := cache.Refresh(); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	download("1.0.0", "linux")
+	expectCalls(4)
+}
+
+func TestS3ProviderData_GetDownloadDataDoesNotCacheErrors(t *testing.T) {
+	providerData := &countingMetadataProviderData{}
+	cache := NewCache(providerData, testsupport.NewTestBucket(metadataBucketContent()))
+	if err := cache.Refresh(); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := cache.GetDownloadData("UPSTREAM_ERROR_PROVIDER", "lodge", "1.0.0", "linux", "amd64"); err == nil {
+			t.Errorf("GetDownloadData() expected error")
+		}
+	}
+	if calls := providerData.calls.Load(); calls != 2 {
+		t.Errorf("read version metadata %d times, want 2", calls)
+	}
+}
+
+func TestS3ProviderData_GetDownloadDataCoalescesConcurrentMisses(t *testing.T) {
+	providerData := &countingMetadataProviderData{release: make(chan struct{})}
+	cache := NewCache(providerData, testsupport.NewTestBucket(metadataBucketContent()))
+	if err := cache.Refresh(); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := cache.GetDownloadData("black", "lodge", "1.0.0", "linux", "amd64")
+			errs <- err
+		}()
+	}
+
+	// let the requests pile up behind the first load before it finishes
+	for providerData.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(providerData.release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("GetDownloadData() error = %v", err)
+		}
+	}
+	if calls := providerData.calls.Load(); calls != 1 {
+		t.Errorf("read version metadata %d times, want 1", calls)
+	}
+}
+
+func TestS3ProviderData_GetMirrorArchivesIsCached(t *testing.T) {
+	providerData := &countingMetadataProviderData{}
+	cache := NewCache(providerData, testsupport.NewTestBucket(metadataBucketContent()))
+	if err := cache.Refresh(); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	mirror := func(version string) {
+		if _, err := cache.GetMirrorArchives("black", "lodge", version); err != nil {
+			t.Fatalf("GetMirrorArchives() error = %v", err)
+		}
+	}
+	expectCalls := func(wantCalls int64, wantMirrorCalls int64) {
+		if calls, mirrorCalls := providerData.calls.Load(), providerData.mirrorCalls.Load(); calls != wantCalls || mirrorCalls != wantMirrorCalls {
+			t.Errorf("read version metadata %d and mirror metadata %d times, want %d and %d", calls, mirrorCalls, wantCalls, wantMirrorCalls)
+		}
+	}
+
+	// the mirror only reads the hashes, which are cached separately
+	mirror("1.0.0")
+	mirror("1.0.0")
+	expectCalls(0, 1)
+
+	// cached download metadata is used by the mirror as well
+	if _, err := cache.GetDownloadData("black", "lodge", "1.0.1", "linux", "amd64"); err != nil {
+		t.Fatalf("GetDownloadData() error = %v", err)
+	}
+	mirror("1.0.1")
+	expectCalls(1, 1)
+}
+
+// countingBucket counts the listings made.
+type countingBucket struct {
+	testsupport.TestBucket
+	listings int
+}
+
+func (bucket *countingBucket) ListObjects() ([]string, error) {
+	bucket.listings++
+	return bucket.TestBucket.ListObjects()
+}
+
+func (bucket *countingBucket) ListObjectsWithPrefix(prefix string, delimiter string) ([]string, error) {
+	bucket.listings++
+	return bucket.TestBucket.ListObjectsWithPrefix(prefix, delimiter)
+}
+
+func TestS3ProviderData_RefreshListsBucketOnce(t *testing.T) {
+	bucket := &countingBucket{TestBucket: testsupport.NewTestBucket([]string{
+		"black/lodge/1.0.0/terraform-provider-lodge_1.0.0_linux_amd64.zip",
+		"black/lodge/1.0.0/terraform-provider-lodge_1.0.0_darwin_arm64.zip",
+		"black/lodge/1.0.0/shasum",
+		"black/lodge/1.0.1/terraform-provider-lodge_1.0.1_linux_amd64.zip",
+		"black/owl/1.0.0/terraform-provider-owl_1.0.0_linux_amd64.zip",
+		"white/lodge/2.0.0/terraform-provider-lodge_2.0.0_linux_amd64.zip",
+		"README.md",
+		"black/keyfile",
+	})}
+	providerData := &countingProviderData{}
+
+	cache := NewCache(providerData, bucket)
+	if err := cache.Refresh(); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	if bucket.listings != 1 {
+		t.Errorf("Refresh() listed the bucket %d times, want 1", bucket.listings)
+	}
+	if calls := providerData.calls.Load(); calls != 3 {
+		t.Errorf("Refresh() indexed %d providers, want 3", calls)
+	}
+}
+
+func TestS3ProviderData_RefreshProvider(t *testing.T) {
+	bucket := &countingBucket{TestBucket: testsupport.NewTestBucket([]string{
+		"black/lodge/1.0.0/terraform-provider-lodge_1.0.0_linux_amd64.zip",
+		"black/owl/1.0.0/terraform-provider-owl_1.0.0_linux_amd64.zip",
+	})}
+	cache := newTestCache(testsupport.NewTestProviderData(), bucket, listVersionsData())
+	cache.downloadCacheSize = 10
+	cache.snapshot.Store(cache.newSnapshot(1, listVersionsData(), nil))
+	cache.snapshot.Load().downloads.add("white/lodge/1.0.0", schema.VersionMetadata{})
+	cache.snapshot.Load().downloads.add("black/lodge/1.0.0", schema.VersionMetadata{})
+
+	if err := cache.RefreshProvider("black", "owl"); err != nil {
+		t.Fatalf("RefreshProvider() error = %v", err)
+	}
+	if err := cache.RefreshProvider("white", "lodge"); err != nil {
+		t.Fatalf("RefreshProvider() error = %v", err)
+	}
+
+	if bucket.listings != 2 {
+		t.Errorf("RefreshProvider() listed the bucket %d times, want 2", bucket.listings)
+	}
+	if _, err := cache.ListVersions("black", "owl"); err != nil {
+		t.Errorf("ListVersions() of added provider error = %v", err)
+	}
+	if got, _ := cache.ListVersions("black", "lodge"); !reflect.DeepEqual(got, listVersionDataFor("black", "lodge")) {
+		t.Errorf("ListVersions() of unchanged provider got = %v, want %v", got, listVersionDataFor("black", "lodge"))
+	}
+	if _, err := cache.ListVersions("white", "lodge"); err == nil {
+		t.Errorf("ListVersions() of removed provider expected error")
+	}
+
+	if _, ok := cache.snapshot.Load().downloads.get("black/lodge/1.0.0"); !ok {
+		t.Errorf("download metadata of unchanged provider was dropped")
+	}
+	if _, ok := cache.snapshot.Load().downloads.get("white/lodge/1.0.0"); ok {
+		t.Errorf("download metadata of removed provider was kept")
+	}
+	if generation := cache.Status().Generation; generation != 3 {
+		t.Errorf("Status().Generation = %d, want 3", generation)
+	}
+	if providers := cache.Status().Providers; providers != countProviders(cache.snapshot.Load().versions) {
+		t.Errorf("Status().Providers = %d, want %d", providers, countProviders(cache.snapshot.Load().versions))
+	}
+}
+
+func TestS3ProviderData_RefreshProviderWithOnlyInheritedKeys(t *testing.T) {
+	bucket := testsupport.NewTestBucket([]string{
+		"white/lodge/keys/author.asc",
+	})
+	cache := newTestCache(testsupport.NewTestProviderData(), bucket, listVersionsData())
+	cache.snapshot.Store(cache.newSnapshot(1, listVersionsData(), nil))
+
+	if err := cache.RefreshProvider("white", "lodge"); err != nil {
+		t.Fatalf("RefreshProvider() error = %v", err)
+	}
+
+	if _, err := cache.ListVersions("white", "lodge"); !errors.Is(err, registryerror.ErrNotFound) {
+		t.Errorf("ListVersions() of provider with only inherited keys error = %v, want not found", err)
+	}
+	if providers := cache.Status().Providers; providers != countProviders(cache.snapshot.Load().versions) {
+		t.Errorf("Status().Providers = %d, want %d", providers, countProviders(cache.snapshot.Load().versions))
+	}
+}
+
+func TestS3ProviderData_Modules(t *testing.T) {
+	bucket := testsupport.NewTestBucket([]string{
+		"black/lodge/1.0.0/terraform-provider-lodge_1.0.0_linux_amd64.zip",
+		"modules/black/lodge/aws/1.0.0/lodge.tar.gz",
+		"modules/white/lodge/aws/1.0.0/lodge.zip",
+	})
+	moduleData, _ := moduledata.NewS3Backend(bucket, "twin.peaks")
+	cache := NewCache(testsupport.NewTestProviderData(), bucket, WithModules(moduleData))
+
+	if err := cache.RefreshModule("black", "lodge", "aws"); err != nil {
+		t.Fatalf("RefreshModule() error = %v", err)
+	}
+	if _, err := cache.ListModuleVersions("black", "lodge", "aws"); err != nil {
+		t.Errorf("ListModuleVersions() of refreshed module error = %v", err)
+	}
+	if _, err := cache.ListModuleVersions("white", "lodge", "aws"); err == nil {
+		t.Errorf("ListModuleVersions() of module which was not refreshed expected error")
+	}
+
+	if err := cache.Refresh(); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if _, err := cache.ListVersions("modules", "black"); err == nil {
+		t.Errorf("ListVersions() indexed modules as provider")
+	}
+
+	downloadURL, err := cache.GetModuleDownloadURL("white", "lodge", "aws", "1.0.0")
+	if err != nil {
+		t.Fatalf("GetModuleDownloadURL() error = %v", err)
+	}
+	const wantedURL = "https://twin.peaks/v1/modules/white/lodge/aws/1.0.0/archive/lodge.zip"
+	if downloadURL != wantedURL {
+		t.Errorf("GetModuleDownloadURL() got = %v, want %v", downloadURL, wantedURL)
+	}
+	if _, err := cache.GetModuleDownloadURL("white", "lodge", "aws", "1.0.1"); err == nil {
+		t.Errorf("GetModuleDownloadURL() of missing version expected error")
+	}
+
+	if _, err := cache.ProxyModule("white", "lodge", "aws", "1.0.0", "lodge.zip", s3.GetObjectOptions{}); err != nil {
+		t.Errorf("ProxyModule() of indexed archive error = %v", err)
+	}
+	for _, archive := range [][2]string{{"1.0.1", "lodge.zip"}, {"1.0.0", "lodge.tgz"}} {
+		if _, err := cache.ProxyModule("white", "lodge", "aws", archive[0], archive[1], s3.GetObjectOptions{}); !errors.Is(err, registryerror.ErrNotFound) {
+			t.Errorf("ProxyModule() of %s/%s error = %v, want not found", archive[0], archive[1], err)
+		}
+	}
+}
+
+func TestGroupByProvider(t *testing.T) {
+	got := groupByProvider([]string{
+		"black/lodge/1.0.0/terraform-provider-lodge_1.0.0_linux_amd64.zip",
+		"white/lodge/2.0.0/terraform-provider-lodge_2.0.0_linux_amd64.zip",
+		"black/lodge/1.0.1/terraform-provider-lodge_1.0.1_linux_amd64.zip",
+		"black/lodge/",
+		"black/",
+		"black/keys/cooper.asc",
+		"black/lodge/keys/cooper.asc",
+		"keys/cooper.asc",
+		"README.md",
+	})
+
+	want := []*providerObjects{
+		{
+			namespace:    "black",
+			providerType: "lodge",
+			objects: []string{
+				"black/lodge/1.0.0/terraform-provider-lodge_1.0.0_linux_amd64.zip",
+				"black/lodge/1.0.1/terraform-provider-lodge_1.0.1_linux_amd64.zip",
+				"black/lodge/",
+			},
+		},
+		{
+			namespace:    "white",
+			providerType: "lodge",
+			objects:      []string{"white/lodge/2.0.0/terraform-provider-lodge_2.0.0_linux_amd64.zip"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("groupByProvider() got = %v, want %v", got, want)
+	}
+}
+
+func TestS3ProviderData_ConcurrentRefreshAndListVersions(t *testing.T) {
+	cache := NewCache(&countingProviderData{}, testsupport.NewTestBucket([]string{
+		"black/lodge/1.0.0/terraform-provider-lodge_1.0.0_linux_amd64.zip",
+		"white/lodge/1.0.0/terraform-provider-lodge_1.0.0_linux_amd64.zip",
+	}))
+	if err := cache.Refresh(); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	var waitGroup sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			for j := 0; j < 50; j++ {
+				if err := cache.Refresh(); err != nil {
+					t.Errorf("Refresh() error = %v", err)
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 8; i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			for j := 0; j < 200; j++ {
+				if _, err := cache.ListVersions("black", "lodge"); err != nil {
+					t.Errorf("ListVersions() error = %v", err)
+				}
+
+				// every refresh lists black/lodge and white/lodge in this order, so a consistent snapshot
+				// contains two consecutive calls
+				currentSnapshot := cache.(*s3ProviderData).snapshot.Load()
+				black, _ := strconv.Atoi(currentSnapshot.versions["black"]["lodge"].ID)
+				white, _ := strconv.Atoi(currentSnapshot.versions["white"]["lodge"].ID)
+				if white != black+1 || black%2 != 1 {
+					t.Errorf("inconsistent snapshot of generation %d: black = %d, white = %d", currentSnapshot.generation, black, white)
+				}
+			}
+		}()
+	}
+
+	waitGroup.Wait()
+
+	if generation := cache.(*s3ProviderData).snapshot.Load().generation; generation != 201 {
+		t.Errorf("generation = %d, want 201", generation)
+	}
+}
+
+func TestS3ProviderData_ServesOnlyIndexedVersions(t *testing.T) {
+	providerData := &countingMetadataProviderData{}
+	cache := NewCache(providerData, testsupport.NewTestBucket(metadataBucketContent()))
+	if err := cache.Refresh(); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	if _, err := cache.GetDownloadData("black", "lodge", "1.0.2", "linux", "amd64"); !errors.Is(err, registryerror.ErrNotFound) {
+		t.Errorf("GetDownloadData() error = %v, want not found", err)
+	}
+	if _, err := cache.GetMirrorArchives("black", "lodge", "1.0.2"); !errors.Is(err, registryerror.ErrNotFound) {
+		t.Errorf("GetMirrorArchives() error = %v, want not found", err)
+	}
+	if _, err := cache.Proxy("black", "lodge", "1.0.2", "shasum", s3.GetObjectOptions{}); !errors.Is(err, registryerror.ErrNotFound) {
+		t.Errorf("Proxy() error = %v, want not found", err)
+	}
+	if calls := providerData.calls.Load(); calls != 0 {
+		t.Errorf("read version metadata %d times for versions which are not indexed", calls)
+	}
+}
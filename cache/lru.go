@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"container/list"
+	"github.com/mdreem/s3_terraform_registry/schema"
+	"strings"
+	"sync"
+)
+
+// metadataCache is a size bounded least recently used cache of version metadata, safe for concurrent use.
+type metadataCache struct {
+	lock     sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List
+}
+
+type metadataEntry struct {
+	key      string
+	metadata schema.VersionMetadata
+}
+
+func newMetadataCache(capacity int) *metadataCache {
+	return &metadataCache{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+func (cache *metadataCache) get(key string) (schema.VersionMetadata, bool) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	element, ok := cache.entries[key]
+	if !ok {
+		return schema.VersionMetadata{}, false
+	}
+	cache.order.MoveToFront(element)
+	return element.Value.(*metadataEntry).metadata, true
+}
+
+func (cache *metadataCache) add(key string, metadata schema.VersionMetadata) {
+	if cache.capacity <= 0 {
+		return
+	}
+
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	if element, ok := cache.entries[key]; ok {
+		element.Value.(*metadataEntry).metadata = metadata
+		cache.order.MoveToFront(element)
+		return
+	}
+
+	cache.entries[key] = cache.order.PushFront(&metadataEntry{key: key, metadata: metadata})
+	for cache.order.Len() > cache.capacity {
+		oldest := cache.order.Back()
+		cache.order.Remove(oldest)
+		delete(cache.entries, oldest.Value.(*metadataEntry).key)
+	}
+}
+
+// copyWithout returns a new cache containing all entries whose keys do not start with prefix.
+func (cache *metadataCache) copyWithout(prefix string) *metadataCache {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	copied := newMetadataCache(cache.capacity)
+	for element := cache.order.Back(); element != nil; element = element.Prev() {
+		entry := element.Value.(*metadataEntry)
+		if strings.HasPrefix(entry.key, prefix) {
+			continue
+		}
+		copied.entries[entry.key] = copied.order.PushFront(&metadataEntry{key: entry.key, metadata: entry.metadata})
+	}
+	return copied
+}
+
+func (cache *metadataCache) len() int {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	return cache.order.Len()
+}
@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"github.com/mdreem/s3_terraform_registry/schema"
+	"reflect"
+	"testing"
+)
+
+func metadataWithProtocol(protocol string) schema.VersionMetadata {
+	return schema.VersionMetadata{Protocols: []string{protocol}}
+}
+
+func TestMetadataCache(t *testing.T) {
+	cache := newMetadataCache(2)
+
+	cache.add("black/lodge/1.0.0", metadataWithProtocol("4.0"))
+	cache.add("black/lodge/1.0.1", metadataWithProtocol("5.0"))
+
+	// reading 1.0.0 makes 1.0.1 the least recently used entry
+	if _, ok := cache.get("black/lodge/1.0.0"); !ok {
+		t.Errorf("get() did not find black/lodge/1.0.0")
+	}
+	cache.add("black/lodge/1.0.2", metadataWithProtocol("6.0"))
+
+	if _, ok := cache.get("black/lodge/1.0.1"); ok {
+		t.Errorf("get() found evicted black/lodge/1.0.1")
+	}
+	if got, _ := cache.get("black/lodge/1.0.2"); !reflect.DeepEqual(got, metadataWithProtocol("6.0")) {
+		t.Errorf("get() got = %v, want %v", got, metadataWithProtocol("6.0"))
+	}
+
+	cache.add("black/lodge/1.0.0", metadataWithProtocol("5.0"))
+	if got, _ := cache.get("black/lodge/1.0.0"); !reflect.DeepEqual(got, metadataWithProtocol("5.0")) {
+		t.Errorf("get() got = %v, want %v", got, metadataWithProtocol("5.0"))
+	}
+	if cache.len() != 2 {
+		t.Errorf("len() = %d, want 2", cache.len())
+	}
+}
+
+func TestMetadataCache_Disabled(t *testing.T) {
+	cache := newMetadataCache(0)
+
+	cache.add("black/lodge/1.0.0", metadataWithProtocol("4.0"))
+	if _, ok := cache.get("black/lodge/1.0.0"); ok {
+		t.Errorf("get() found entry in disabled cache")
+	}
+}
+
+func TestMetadataCache_CopyWithout(t *testing.T) {
+	cache := newMetadataCache(2)
+	cache.add("black/lodge/1.0.0", metadataWithProtocol("4.0"))
+	cache.add("black/lodgepole/1.0.0", metadataWithProtocol("5.0"))
+
+	copied := cache.copyWithout("black/lodge/")
+
+	if _, ok := copied.get("black/lodge/1.0.0"); ok {
+		t.Errorf("get() found removed black/lodge/1.0.0")
+	}
+	if got, _ := copied.get("black/lodgepole/1.0.0"); !reflect.DeepEqual(got, metadataWithProtocol("5.0")) {
+		t.Errorf("get() got = %v, want %v", got, metadataWithProtocol("5.0"))
+	}
+	if cache.len() != 2 {
+		t.Errorf("copyWithout() modified the original cache, len() = %d, want 2", cache.len())
+	}
+}
@@ -0,0 +1,44 @@
+package cache
+
+import (
+	"context"
+	"github.com/mdreem/s3_terraform_registry/logger"
+	"math/rand"
+	"time"
+)
+
+// maxJitterFraction limits the random delay added to the refresh interval, so several instances of the registry do
+// not refresh at the same time.
+const maxJitterFraction = 0.1
+
+// RefreshPeriodically refreshes the cache every interval plus some jitter until ctx is done. If a refresh fails, the
+// error is logged and the previous snapshot keeps being served.
+func RefreshPeriodically(ctx context.Context, cache Cache, interval time.Duration) {
+	logger.Sugar.Infow("starting periodic refresh", "interval", interval)
+
+	for {
+		timer := time.NewTimer(interval + jitter(interval))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			logger.Sugar.Infow("stopping periodic refresh")
+			return
+		case <-timer.C:
+		}
+
+		if err := cache.Refresh(); err != nil {
+			status := cache.Status()
+			logger.Sugar.Errorw("periodic refresh failed, serving previous snapshot", "error", err, "generation", status.Generation, "lastSuccess", status.LastSuccess)
+			continue
+		}
+		logger.Sugar.Debugw("periodic refresh succeeded", "generation", cache.Status().Generation)
+	}
+}
+
+func jitter(interval time.Duration) time.Duration {
+	maxJitter := int64(float64(interval) * maxJitterFraction)
+	if maxJitter <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(maxJitter))
+}
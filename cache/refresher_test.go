@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// failingCache fails every second refresh.
+type failingCache struct {
+	refreshes atomic.Int64
+}
+
+func (cache *failingCache) Refresh() error {
+	if cache.refreshes.Add(1)%2 == 0 {
+		return errors.New("unable to list objects")
+	}
+	return nil
+}
+
+func (cache *failingCache) RefreshProvider(_ string, _ string) error {
+	return nil
+}
+
+func (cache *failingCache) RefreshModule(_ string, _ string, _ string) error {
+	return nil
+}
+
+func (cache *failingCache) Status() Status {
+	return Status{}
+}
+
+func TestRefreshPeriodically(t *testing.T) {
+	cache := &failingCache{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		RefreshPeriodically(ctx, cache, 5*time.Millisecond)
+		close(stopped)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for cache.refreshes.Load() < 4 {
+		if time.Now().After(deadline) {
+			t.Fatalf("only %d refreshes happened", cache.refreshes.Load())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("periodic refresh did not stop")
+	}
+}
+
+func TestJitter(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		if got := jitter(time.Minute); got < 0 || got >= 6*time.Second {
+			t.Errorf("jitter() = %v, want value in [0s, 6s)", got)
+		}
+	}
+	if got := jitter(0); got != 0 {
+		t.Errorf("jitter() = %v, want 0", got)
+	}
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"github.com/aws/aws-sdk-go/aws"
@@ -216,15 +217,21 @@ func createProvider(t *testing.T, sess *session.Session, namespace, providerType
 	path := fmt.Sprintf("%s/%s/%s/", namespace, providerType, version)
 	zipFileName := fmt.Sprintf("%s_%s_%s_%s.zip", name, version, os, arch)
 
-	uploadFileToS3(t, sess, path+zipFileName)
-	uploadFileToS3(t, sess, path+"shasum")
+	zipContent := uploadFileToS3(t, sess, path+zipFileName)
+	shaSum := sha256.Sum256([]byte(zipContent))
+	uploadContentToS3(t, sess, path+"shasum", fmt.Sprintf("%x  %s\n", shaSum, zipFileName))
 	uploadFileToS3(t, sess, path+"shasum.sig")
 	uploadFileToS3(t, sess, path+"key_id")
 	uploadFileToS3(t, sess, path+"keyfile")
 }
 
-func uploadFileToS3(t *testing.T, sess *session.Session, filename string) {
+func uploadFileToS3(t *testing.T, sess *session.Session, filename string) string {
 	fileData := "Content for: " + filename
+	uploadContentToS3(t, sess, filename, fileData)
+	return fileData
+}
+
+func uploadContentToS3(t *testing.T, sess *session.Session, filename string, fileData string) {
 	bucketFileData := strings.NewReader(fileData)
 
 	uploader := s3manager.NewUploader(sess)
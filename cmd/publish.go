@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/mdreem/s3_terraform_registry/common"
+	"github.com/mdreem/s3_terraform_registry/logger"
+	"github.com/mdreem/s3_terraform_registry/publish"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+var publishCmd = &cobra.Command{
+	Use:   "publish <dist directory>",
+	Short: "publishes a provider release from a goreleaser dist directory into the bucket",
+	Args:  cobra.ExactArgs(1),
+	Run:   runPublish,
+}
+
+func runPublish(command *cobra.Command, args []string) {
+	namespace := common.GetString(command, "namespace")
+	providerType := common.GetString(command, "type")
+	version := common.GetString(command, "version")
+	dryRun, err := command.Flags().GetBool("dry-run")
+	if err != nil {
+		common.PrintInformationf("could not fetch dry-run option: %v\n", err)
+		os.Exit(1)
+	}
+
+	var publicKey []byte
+	if publicKeyFile := common.GetString(command, "public-key"); publicKeyFile != "" {
+		if publicKey, err = os.ReadFile(publicKeyFile); err != nil {
+			common.PrintInformationf("unable to read public key: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	release, err := publish.ReadDist(args[0], namespace, providerType, version, string(publicKey))
+	if err != nil {
+		common.PrintInformationf("%v\n", err)
+		os.Exit(1)
+	}
+	if release.IsSigned() && len(publicKey) == 0 {
+		common.PrintInformationf("%s is signed, public-key is required\n", release.String())
+		os.Exit(1)
+	}
+
+	bucket := openStorage(command)
+	// the registry picks up the release on its next refresh or via event notifications
+	publisher := publish.NewPublisher(bucket, nil, publisherOptions(command)...)
+
+	publishRelease := publisher.Publish
+	if dryRun {
+		publishRelease = publisher.DryRun
+	}
+	published, err := publishRelease(release)
+	if err != nil {
+		logger.Sugar.Errorw("publishing failed.", "release", release.String(), "error", err)
+		common.PrintInformationf("unable to publish %s: %v\n", release.String(), err)
+		os.Exit(1)
+	}
+
+	action := "uploaded"
+	if dryRun {
+		action = "would upload"
+	}
+	fmt.Printf("%s %s signed with key %s:\n", action, release.String(), published.KeyID)
+	for _, file := range published.Files {
+		fmt.Printf("  %s\n", file)
+	}
+}
+
+func init() {
+	flags := publishCmd.Flags()
+	flags.String("namespace", "", "namespace of the provider.")
+	flags.String("type", "", "type of the provider.")
+	flags.String("version", "", "version of the release, without a leading v.")
+	flags.String("public-key", "", "file containing the ASCII armored public key which signed the shasum file. Not needed if the release is signed with the signing key of the registry.")
+	flags.Bool("dry-run", false, "validate the release and show the files which would be uploaded without uploading them.")
+
+	for _, flagName := range []string{"namespace", "type", "version"} {
+		markFlagRequired(publishCmd, flagName)
+	}
+
+	RootCmd.AddCommand(publishCmd)
+}
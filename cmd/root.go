@@ -1,19 +1,30 @@
 package cmd
 
 import (
+	"context"
+	"github.com/mdreem/s3_terraform_registry/auth"
 	"github.com/mdreem/s3_terraform_registry/cache"
 	"github.com/mdreem/s3_terraform_registry/common"
 	"github.com/mdreem/s3_terraform_registry/endpoints"
+	"github.com/mdreem/s3_terraform_registry/events"
 	"github.com/mdreem/s3_terraform_registry/logger"
+	"github.com/mdreem/s3_terraform_registry/moduledata"
 	"github.com/mdreem/s3_terraform_registry/providerdata"
+	"github.com/mdreem/s3_terraform_registry/publish"
 	"github.com/mdreem/s3_terraform_registry/s3"
 	"github.com/spf13/cobra"
 	"os"
+	"time"
 )
 
 var GitCommit string
 var Version string
 
+const (
+	downloadModeProxy     = "proxy"
+	downloadModePresigned = "presigned"
+)
+
 var RootCmd = &cobra.Command{
 	Use: "s3-terraform-registry",
 	Run: runCommand,
@@ -22,27 +33,127 @@ var RootCmd = &cobra.Command{
 func runCommand(command *cobra.Command, _ []string) {
 	logger.Sugar.Infow("s3_terraform_registry. ", "Version", Version, "Commit", GitCommit)
 
-	bucketName := common.GetString(command, "bucket-name")
 	hostname := common.GetString(command, "hostname")
+	bucketName := common.GetString(command, "bucket-name")
 	region := common.GetString(command, "region")
+	defaultProtocols := common.GetStringSlice(command, "default-protocols")
+
+	bucket := openStorage(command)
+	signatureVerification, err := providerdata.ParseSignatureVerification(common.GetString(command, "verify-signatures"))
+	if err != nil {
+		logger.Sugar.Panicw("invalid signature verification.", "error", err)
+	}
+	providerOptions := []providerdata.Option{
+		providerdata.WithDefaultProtocols(defaultProtocols),
+		providerdata.WithSignatureVerification(signatureVerification),
+	}
+	moduleOptions := make([]moduledata.Option, 0)
+
+	downloadMode := common.GetString(command, "download-mode")
+	switch downloadMode {
+	case downloadModeProxy:
+	case downloadModePresigned:
+		presigner, ok := bucket.(s3.Presigner)
+		if !ok {
+			logger.Sugar.Panicw("presigned downloads are only supported by S3 storage.", "storage", common.GetString(command, "storage"))
+		}
+		presignExpiry := common.GetDuration(command, "presign-expiry")
+		providerOptions = append(providerOptions, providerdata.WithPresignedDownloads(presigner, presignExpiry))
+		moduleOptions = append(moduleOptions, moduledata.WithPresignedDownloads(presigner, presignExpiry))
+	default:
+		logger.Sugar.Panicw("unknown download mode.", "downloadMode", downloadMode)
+	}
 
-	bucket := s3.New(region, bucketName)
-	s3Backend, err := providerdata.NewS3Backend(bucket, hostname)
+	s3Backend, err := providerdata.NewS3Backend(bucket, hostname, providerOptions...)
 	if err != nil {
 		logger.Sugar.Panicw("failed to initialize S3 backend.", "error", err)
 	}
 
-	cache := cache.NewCache(s3Backend, bucket)
-	if err = cache.Refresh(); err != nil {
+	moduleBackend, err := moduledata.NewS3Backend(bucket, hostname, moduleOptions...)
+	if err != nil {
+		logger.Sugar.Panicw("failed to initialize S3 module backend.", "error", err)
+	}
+
+	downloadCacheSize := common.GetInt(command, "download-cache-size")
+	registryCache := cache.NewCache(s3Backend, bucket, cache.WithDownloadCacheSize(downloadCacheSize), cache.WithModules(moduleBackend))
+
+	// storages without event notifications are polled for changes
+	var poller *events.Poller
+	watchInterval := common.GetDuration(command, "watch-interval")
+	if storage, ok := bucket.(s3.ListFingerprints); ok && watchInterval > 0 {
+		poller = events.NewPoller(storage, registryCache)
+		// records the state which the initial refresh indexes
+		if _, err = poller.PollOnce(); err != nil {
+			logger.Sugar.Panicw("failed to list files.", "error", err)
+		}
+	}
+
+	if err = registryCache.Refresh(); err != nil {
 		panic(err)
 	}
 
-	r := endpoints.SetupRouter(cache)
+	if poller != nil {
+		go poller.Run(context.Background(), watchInterval)
+	}
+
+	refreshInterval := common.GetDuration(command, "refresh-interval")
+	if refreshInterval > 0 {
+		go cache.RefreshPeriodically(context.Background(), registryCache, refreshInterval)
+	}
+
+	sqsQueueURL := common.GetString(command, "sqs-queue-url")
+	if sqsQueueURL != "" {
+		worker := events.NewSQSWorker(region, sqsQueueURL, bucketName, registryCache)
+		go worker.Run(context.Background())
+	}
+
+	options := append(routerOptions(command, bucket),
+		endpoints.WithPublishing(publish.NewPublisher(bucket, registryCache, publisherOptions(command)...)),
+		endpoints.WithMaxUploadSize(common.GetInt64(command, "max-upload-size")),
+		endpoints.WithBucketName(bucketName),
+	)
+	r := endpoints.SetupRouter(registryCache, options...)
 
 	port := common.GetString(command, "port")
 	_ = r.Run(":" + port)
 }
 
+// routerOptions enables authentication for the scopes listed in require-auth with the tokens read from the
+// configured token list. The tokens are loaded whenever a token list is configured, as the administrative routes
+// always require a token. They are only loaded once, changes of the token list need a restart.
+func routerOptions(command *cobra.Command, bucket s3.GetObject) []endpoints.Option {
+	requiredScopes := common.GetStringSlice(command, "require-auth")
+	protectedScopes := make([]auth.Scope, 0, len(requiredScopes))
+	for _, scope := range requiredScopes {
+		if auth.Scope(scope) != auth.ScopeAPI && auth.Scope(scope) != auth.ScopeDownload {
+			logger.Sugar.Panicw("unknown scope in require-auth.", "scope", scope)
+		}
+		protectedScopes = append(protectedScopes, auth.Scope(scope))
+	}
+
+	var tokens auth.Tokens
+	var err error
+	tokensFile := common.GetString(command, "auth-tokens-file")
+	tokensKey := common.GetString(command, "auth-tokens-key")
+	switch {
+	case tokensFile != "":
+		tokens, err = auth.LoadTokensFromFile(tokensFile)
+	case tokensKey != "":
+		tokens, err = auth.LoadTokensFromBucket(bucket, tokensKey)
+	case len(protectedScopes) > 0:
+		logger.Sugar.Panicw("require-auth needs auth-tokens-file or auth-tokens-key to be set.")
+	default:
+		logger.Sugar.Warnw("no tokens configured, administrative routes are disabled")
+		return nil
+	}
+	if err != nil {
+		logger.Sugar.Panicw("failed to load tokens.", "error", err)
+	}
+	logger.Sugar.Infow("requiring authentication", "scopes", protectedScopes, "tokens", tokens.Len())
+
+	return []endpoints.Option{endpoints.WithAuthentication(tokens, protectedScopes...)}
+}
+
 func Execute() {
 	if err := RootCmd.Execute(); err != nil {
 		logger.Sugar.Errorw("could not execute command. ", "error", err)
@@ -51,23 +162,48 @@ func Execute() {
 }
 
 func init() {
-	flags := RootCmd.PersistentFlags()
-	flags.StringP("bucket-name", "b", "", "the S3 bucket where the files are placed.")
+	persistentFlags := RootCmd.PersistentFlags()
+	persistentFlags.String("storage", storageS3, "where the files are placed: `s3` for an S3 bucket or `filesystem` for a local directory.")
+	persistentFlags.StringP("bucket-name", "b", "", "the S3 bucket where the files are placed. Required for S3 storage.")
+	persistentFlags.String("root-dir", "", "the directory where the files are placed. Required for filesystem storage.")
+
+	persistentFlags.StringP("loglevel", "l", "info", "can be set to `error`, `info`, `debug` to set loglevel.")
 
+	persistentFlags.StringP("region", "r", "", "needs to be set to the region of the bucket for S3 storage. E.g. eu-central-1.")
+
+	persistentFlags.String("signing-key-file", "", "file containing the ASCII armored private key used to sign unsigned releases. Defaults to the environment variable SIGNING_KEY.")
+
+	// flags only needed for serving the registry
+	flags := RootCmd.Flags()
 	flags.StringP("hostname", "H", "", "hostname under which this registry will be available.")
 	flags.StringP("port", "p", "8080", "port the registry will listen on.")
 
-	flags.StringP("loglevel", "l", "info", "can be set to `error`, `info`, `debug` to set loglevel.")
+	flags.Duration("refresh-interval", 0, "interval in which the index is refreshed in the background, e.g. 5m. Disabled if 0.")
+
+	flags.String("download-mode", downloadModeProxy, "can be set to `proxy` to stream downloads through the registry or `presigned` to redirect them to presigned S3 URLs.")
+	flags.Duration("presign-expiry", 15*time.Minute, "time after which presigned download URLs expire.")
+
+	flags.StringSlice("require-auth", nil, "scopes which require a bearer token: `api` for the registry APIs, `download` for downloads.")
+	flags.String("auth-tokens-file", "", "file containing the tokens which are accepted.")
+	flags.String("auth-tokens-key", "", "key of the object in the bucket containing the tokens which are accepted.")
+
+	flags.Duration("watch-interval", 5*time.Second, "interval in which filesystem storage is checked for changed files. Disabled if 0.")
+
+	flags.Int64("max-upload-size", endpoints.DefaultMaxUploadSize, "maximum size in bytes of a release uploaded to the publish API.")
+
+	flags.String("sqs-queue-url", "", "SQS queue receiving S3 event notifications of the bucket. Changed providers are refreshed when set.")
+
+	flags.Int("download-cache-size", cache.DefaultDownloadCacheSize, "number of versions whose download metadata is cached. Disabled if 0.")
+
+	flags.StringSlice("default-protocols", providerdata.DefaultProtocols, "protocols announced for provider versions without terraform-registry-manifest.json.")
 
-	flags.StringP("region", "r", "", "needs to be set to the region. E.g. eu-central-1.")
+	flags.String("verify-signatures", string(providerdata.VerifySignaturesOff), "verify the shasum signatures while indexing: `off`, `warn` to report versions which do not verify or `hide` to skip them.")
 
-	markPersistentFlagRequired("bucket-name")
-	markPersistentFlagRequired("hostname")
-	markPersistentFlagRequired("region")
+	markFlagRequired(RootCmd, "hostname")
 }
 
-func markPersistentFlagRequired(flagName string) {
-	err := RootCmd.MarkPersistentFlagRequired(flagName)
+func markFlagRequired(command *cobra.Command, flagName string) {
+	err := command.MarkFlagRequired(flagName)
 	if err != nil {
 		logger.Sugar.Errorw("unable to set flag to required.", "flag", flagName)
 		os.Exit(1)
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/mdreem/s3_terraform_registry/common"
+	"github.com/mdreem/s3_terraform_registry/logger"
+	"github.com/mdreem/s3_terraform_registry/publish"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+var signCmd = &cobra.Command{
+	Use:   "sign",
+	Short: "signs the shasum files of all provider versions in the bucket which are not signed yet",
+	Args:  cobra.NoArgs,
+	Run:   runSign,
+}
+
+func runSign(command *cobra.Command, _ []string) {
+	dryRun, err := command.Flags().GetBool("dry-run")
+	if err != nil {
+		common.PrintInformationf("could not fetch dry-run option: %v\n", err)
+		os.Exit(1)
+	}
+
+	options := publisherOptions(command)
+	if len(options) == 0 {
+		common.PrintInformationf("a signing key is required, set signing-key-file or %s\n", envSigningKey)
+		os.Exit(1)
+	}
+
+	bucket := openStorage(command)
+	// the registry picks up the signatures on its next refresh or via event notifications
+	signed, err := publish.NewPublisher(bucket, nil, options...).SignUnsigned(dryRun)
+
+	action := "signed"
+	if dryRun {
+		action = "would sign"
+	}
+	for _, versionPath := range signed {
+		fmt.Printf("%s %s\n", action, versionPath)
+	}
+	if err != nil {
+		logger.Sugar.Errorw("signing failed.", "error", err)
+		common.PrintInformationf("%v\n", err)
+		os.Exit(1)
+	}
+	if len(signed) == 0 {
+		fmt.Println("all provider versions are signed")
+	}
+}
+
+func init() {
+	signCmd.Flags().Bool("dry-run", false, "show the versions which would be signed without signing them.")
+
+	RootCmd.AddCommand(signCmd)
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"github.com/mdreem/s3_terraform_registry/common"
+	"github.com/mdreem/s3_terraform_registry/logger"
+	"github.com/mdreem/s3_terraform_registry/pgp"
+	"github.com/mdreem/s3_terraform_registry/publish"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+const (
+	envSigningKey           = "SIGNING_KEY"
+	envSigningKeyPassphrase = "SIGNING_KEY_PASSPHRASE"
+)
+
+// publisherOptions configures the publisher with the signing key of the registry, read from the file given in
+// signing-key-file or the environment variable SIGNING_KEY. An encrypted key is decrypted with the passphrase in
+// SIGNING_KEY_PASSPHRASE.
+func publisherOptions(command *cobra.Command) []publish.Option {
+	armoredKey := os.Getenv(envSigningKey)
+	if signingKeyFile := common.GetString(command, "signing-key-file"); signingKeyFile != "" {
+		content, err := os.ReadFile(signingKeyFile)
+		if err != nil {
+			common.PrintInformationf("unable to read signing key: %v\n", err)
+			os.Exit(1)
+		}
+		armoredKey = string(content)
+	}
+	if armoredKey == "" {
+		return nil
+	}
+
+	signer, err := pgp.ReadSigner(armoredKey, []byte(os.Getenv(envSigningKeyPassphrase)))
+	if err != nil {
+		common.PrintInformationf("invalid signing key: %v\n", err)
+		os.Exit(1)
+	}
+	logger.Sugar.Infow("signing unsigned releases", "keyID", signer.KeyID())
+
+	return []publish.Option{publish.WithSigner(signer)}
+}
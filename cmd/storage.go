@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"github.com/mdreem/s3_terraform_registry/common"
+	"github.com/mdreem/s3_terraform_registry/s3"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+const (
+	storageS3         = "s3"
+	storageFilesystem = "filesystem"
+)
+
+// openStorage opens the storage selected with storage: the S3 bucket given by bucket-name and region or the directory
+// given by root-dir.
+func openStorage(command *cobra.Command) s3.BucketReaderWriter {
+	storage := common.GetString(command, "storage")
+	switch storage {
+	case storageS3:
+		bucketName := common.GetString(command, "bucket-name")
+		region := common.GetString(command, "region")
+		if bucketName == "" || region == "" {
+			common.PrintInformationf("bucket-name and region are required for %s storage\n", storageS3)
+			os.Exit(1)
+		}
+		return s3.New(region, bucketName)
+	case storageFilesystem:
+		rootDir := common.GetString(command, "root-dir")
+		if rootDir == "" {
+			common.PrintInformationf("root-dir is required for %s storage\n", storageFilesystem)
+			os.Exit(1)
+		}
+		filesystem, err := s3.NewFilesystem(rootDir)
+		if err != nil {
+			common.PrintInformationf("%v\n", err)
+			os.Exit(1)
+		}
+		return filesystem
+	default:
+		common.PrintInformationf("unknown storage %s, expected %s or %s\n", storage, storageS3, storageFilesystem)
+		os.Exit(1)
+		return nil
+	}
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"encoding/json"
+	"github.com/mdreem/s3_terraform_registry/common"
+	"github.com/mdreem/s3_terraform_registry/validation"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "checks the providers and modules in the bucket against the layout of the registry",
+	Args:  cobra.NoArgs,
+	Run:   runValidate,
+}
+
+func runValidate(command *cobra.Command, _ []string) {
+	output := common.GetString(command, "output")
+	if output != outputText && output != outputJSON {
+		common.PrintInformationf("unknown output %s, expected %s or %s\n", output, outputText, outputJSON)
+		os.Exit(1)
+	}
+
+	bucket := openStorage(command)
+	report, err := validation.Validate(bucket)
+	if err != nil {
+		common.PrintInformationf("unable to validate bucket: %v\n", err)
+		os.Exit(1)
+	}
+
+	if output == outputJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(report)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		common.PrintInformationf("unable to write report: %v\n", err)
+		os.Exit(1)
+	}
+
+	if report.HasErrors() {
+		os.Exit(1)
+	}
+}
+
+func init() {
+	validateCmd.Flags().StringP("output", "o", outputText, "format of the report: `text` or `json`.")
+
+	RootCmd.AddCommand(validateCmd)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/spf13/cobra"
 	"os"
+	"time"
 )
 
 func GetString(rootCmd *cobra.Command, option string) string {
@@ -16,6 +17,46 @@ func GetString(rootCmd *cobra.Command, option string) string {
 	return optionString
 }
 
+func GetStringSlice(rootCmd *cobra.Command, option string) []string {
+	optionStrings, err := rootCmd.Flags().GetStringSlice(option)
+
+	if err != nil {
+		PrintInformationf("could not fetch %s option: %v\n", option, err)
+		os.Exit(1)
+	}
+	return optionStrings
+}
+
+func GetInt(rootCmd *cobra.Command, option string) int {
+	optionInt, err := rootCmd.Flags().GetInt(option)
+
+	if err != nil {
+		PrintInformationf("could not fetch %s option: %v\n", option, err)
+		os.Exit(1)
+	}
+	return optionInt
+}
+
+func GetInt64(rootCmd *cobra.Command, option string) int64 {
+	optionInt, err := rootCmd.Flags().GetInt64(option)
+
+	if err != nil {
+		PrintInformationf("could not fetch %s option: %v\n", option, err)
+		os.Exit(1)
+	}
+	return optionInt
+}
+
+func GetDuration(rootCmd *cobra.Command, option string) time.Duration {
+	optionDuration, err := rootCmd.Flags().GetDuration(option)
+
+	if err != nil {
+		PrintInformationf("could not fetch %s option: %v\n", option, err)
+		os.Exit(1)
+	}
+	return optionDuration
+}
+
 func PrintInformationf(format string, a ...interface{}) {
 	_, err := fmt.Fprintf(os.Stderr, format, a...)
 	if err != nil {
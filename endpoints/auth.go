@@ -0,0 +1,58 @@
+package endpoints
+
+import (
+	"encoding/base64"
+	"github.com/gin-gonic/gin"
+	"github.com/mdreem/s3_terraform_registry/auth"
+	"github.com/mdreem/s3_terraform_registry/logger"
+	"github.com/mdreem/s3_terraform_registry/registryerror"
+	"strings"
+)
+
+const (
+	bearerPrefix = "Bearer "
+	basicPrefix  = "Basic "
+)
+
+// requireScope rejects requests which do not carry a bearer token granted scope.
+func requireScope(tokens auth.Tokens, scope auth.Scope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := requestToken(c.GetHeader("Authorization"))
+		if !ok {
+			respondWithError(c, registryerror.Unauthorized(nil, "a bearer token is required"))
+			return
+		}
+
+		scopes, ok := tokens.Lookup(token)
+		if !ok {
+			logger.Sugar.Warnw("rejected unknown token", "path", c.Request.URL.Path)
+			respondWithError(c, registryerror.Unauthorized(nil, "the bearer token is invalid"))
+			return
+		}
+		if !scopes[scope] {
+			logger.Sugar.Warnw("rejected token without scope", "path", c.Request.URL.Path, "scope", scope)
+			respondWithError(c, registryerror.Forbidden(nil, "the bearer token does not grant %s access", scope))
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// requestToken extracts the token from a bearer token or from the password of basic credentials, which is the only
+// way to send credentials for clients like SNS which cannot set headers.
+func requestToken(authorization string) (string, bool) {
+	if strings.HasPrefix(authorization, bearerPrefix) {
+		return strings.TrimSpace(strings.TrimPrefix(authorization, bearerPrefix)), true
+	}
+	if !strings.HasPrefix(authorization, basicPrefix) {
+		return "", false
+	}
+
+	credentials, err := base64.StdEncoding.DecodeString(strings.TrimSpace(strings.TrimPrefix(authorization, basicPrefix)))
+	if err != nil {
+		return "", false
+	}
+	_, password, found := strings.Cut(string(credentials), ":")
+	return password, found
+}
@@ -1,22 +1,90 @@
 package endpoints
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/mdreem/s3_terraform_registry/cache"
 	"github.com/mdreem/s3_terraform_registry/logger"
+	"github.com/mdreem/s3_terraform_registry/schema"
+	"net/http"
+	"sync"
+	"time"
 )
 
-func refreshHandler(cache *cache.Cache) func(c *gin.Context) {
+// refreshCall is a refresh of the whole index which requests can wait for.
+type refreshCall struct {
+	done    chan struct{}
+	summary schema.RefreshSummary
+	err     error
+}
+
+// refreshCoalescer runs at most one refresh at a time. Requests arriving while a refresh is running wait for it and
+// share its result instead of starting another one.
+type refreshCoalescer struct {
+	cache   cache.Cache
+	lock    sync.Mutex
+	current *refreshCall
+}
+
+func newRefreshCoalescer(cache cache.Cache) *refreshCoalescer {
+	return &refreshCoalescer{cache: cache}
+}
+
+func (coalescer *refreshCoalescer) refresh() (schema.RefreshSummary, error) {
+	coalescer.lock.Lock()
+	call := coalescer.current
+	if call != nil {
+		coalescer.lock.Unlock()
+		logger.Sugar.Infow("joining running refresh")
+		<-call.done
+		return call.summary, call.err
+	}
+
+	// requests joining a refresh which panics receive this error
+	aborted := errors.New("the refresh was aborted")
+	call = &refreshCall{
+		done:    make(chan struct{}),
+		summary: schema.RefreshSummary{Errors: []string{aborted.Error()}},
+		err:     aborted,
+	}
+	coalescer.current = call
+	coalescer.lock.Unlock()
+	defer func() {
+		coalescer.lock.Lock()
+		coalescer.current = nil
+		coalescer.lock.Unlock()
+		close(call.done)
+	}()
+
+	start := time.Now()
+	call.err = coalescer.cache.Refresh()
+	status := coalescer.cache.Status()
+	call.summary = schema.RefreshSummary{
+		Duration:   time.Since(start).String(),
+		Generation: status.Generation,
+		Providers:  status.Providers,
+		Modules:    status.Modules,
+		Errors:     make([]string, 0),
+	}
+	if call.err != nil {
+		call.summary.Errors = append(call.summary.Errors, call.err.Error())
+	}
+	return call.summary, call.err
+}
+
+// refreshHandler refreshes the whole index and answers with a summary of the refresh. If the refresh fails, the
+// previous index keeps being served and the summary lists the error.
+func refreshHandler(coalescer *refreshCoalescer) func(c *gin.Context) {
 	return func(c *gin.Context) {
 		logger.Sugar.Infow("refreshing cache")
 
-		err := (*cache).Refresh()
+		summary, err := coalescer.refresh()
 		if err != nil {
 			logger.Sugar.Errorw("error refreshing data", "error", err)
-			c.String(500, "")
+			c.JSON(errorStatus(err), summary)
 			return
 		}
 
-		c.String(200, "refreshed")
+		c.JSON(http.StatusOK, summary)
 	}
 }
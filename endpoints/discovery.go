@@ -7,6 +7,6 @@ import (
 
 func discovery() func(c *gin.Context) {
 	return func(c *gin.Context) {
-		c.JSON(200, schema.Discovery{ProvidersV1: "/v1/providers/"})
+		c.JSON(200, schema.Discovery{ProvidersV1: "/v1/providers/", ModulesV1: "/v1/modules/"})
 	}
 }
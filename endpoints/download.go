@@ -6,7 +6,7 @@ import (
 	"github.com/mdreem/s3_terraform_registry/providerdata"
 )
 
-func getDownloadData(providerData *providerdata.ProviderData) func(c *gin.Context) {
+func getDownloadData(providerData providerdata.ProviderData) func(c *gin.Context) {
 	return func(c *gin.Context) {
 		namespace := c.Param("namespace")
 		providerType := c.Param("type")
@@ -16,10 +16,10 @@ func getDownloadData(providerData *providerdata.ProviderData) func(c *gin.Contex
 
 		logger.Sugar.Infow("called get download data", "namespace", namespace, "type", providerType, "version", version, "os", os, "arch", arch)
 
-		downloadData, err := (*providerData).GetDownloadData(namespace, providerType, version, os, arch)
+		downloadData, err := providerData.GetDownloadData(namespace, providerType, version, os, arch)
 		if err != nil {
 			logger.Sugar.Errorw("get download data returned error", "error", err)
-			c.String(500, "")
+			respondWithError(c, err)
 			return
 		}
 
@@ -0,0 +1,49 @@
+package endpoints
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/mdreem/s3_terraform_registry/registryerror"
+	"github.com/mdreem/s3_terraform_registry/schema"
+	"net/http"
+)
+
+// respondWithError answers with a status code matching the kind of err and an error body like the public registry.
+func respondWithError(c *gin.Context, err error) {
+	status := errorStatus(err)
+	if status == http.StatusUnauthorized {
+		c.Header("WWW-Authenticate", `Bearer, Basic realm="s3-terraform-registry"`)
+	}
+
+	message := http.StatusText(status)
+	var registryError *registryerror.Error
+	if status != http.StatusInternalServerError && errors.As(err, &registryError) {
+		message = registryError.Message()
+	}
+
+	c.AbortWithStatusJSON(status, schema.Errors{Errors: []string{message}})
+}
+
+// errorStatus returns the status code matching the kind of err.
+func errorStatus(err error) int {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, registryerror.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, registryerror.ErrBadRequest):
+		status = http.StatusBadRequest
+	case errors.Is(err, registryerror.ErrUpstream):
+		status = http.StatusBadGateway
+	case errors.Is(err, registryerror.ErrRangeNotSatisfiable):
+		status = http.StatusRequestedRangeNotSatisfiable
+	case errors.Is(err, registryerror.ErrUnauthorized):
+		status = http.StatusUnauthorized
+	case errors.Is(err, registryerror.ErrForbidden):
+		status = http.StatusForbidden
+	case errors.Is(err, registryerror.ErrConflict):
+		status = http.StatusConflict
+	case errors.Is(err, registryerror.ErrTooLarge):
+		status = http.StatusRequestEntityTooLarge
+	}
+	return status
+}
@@ -0,0 +1,42 @@
+package endpoints
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/mdreem/s3_terraform_registry/cache"
+	"github.com/mdreem/s3_terraform_registry/events"
+	"github.com/mdreem/s3_terraform_registry/logger"
+	"github.com/mdreem/s3_terraform_registry/registryerror"
+	"github.com/mdreem/s3_terraform_registry/schema"
+	"io"
+	"net/http"
+)
+
+// maxEventSize limits the size of event notifications, as they are read into memory. SNS messages and EventBridge
+// events are at most 256 KiB, which leaves room for the envelope and the escaping of SNS.
+const maxEventSize = 1 << 20
+
+func s3EventHandler(cache cache.Cache, bucketName string) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxEventSize)
+		payload, err := io.ReadAll(c.Request.Body)
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			respondWithError(c, registryerror.TooLarge(err, "the event notification exceeds %d bytes", maxEventSize))
+			return
+		}
+		if err != nil {
+			respondWithError(c, registryerror.BadRequest(err, "unable to read event notification"))
+			return
+		}
+
+		changes, err := events.Handle(cache, payload, bucketName)
+		if err != nil {
+			logger.Sugar.Errorw("error handling event notification", "error", err)
+			respondWithError(c, err)
+			return
+		}
+
+		c.JSON(200, schema.Refreshed{Providers: changes.ProviderNames(), Modules: changes.ModuleNames()})
+	}
+}
@@ -6,17 +6,17 @@ import (
 	"github.com/mdreem/s3_terraform_registry/providerdata"
 )
 
-func listVersions(providerData *providerdata.ProviderData) func(c *gin.Context) {
+func listVersions(providerData providerdata.ProviderData) func(c *gin.Context) {
 	return func(c *gin.Context) {
 		namespace := c.Param("namespace")
 		providerType := c.Param("type")
 
 		logger.Sugar.Infow("called list versions ", "namespace", namespace, "providerType", providerType)
 
-		versions, err := (*providerData).ListVersions(namespace, providerType)
+		versions, err := providerData.ListVersions(namespace, providerType)
 		if err != nil {
 			logger.Sugar.Errorw("list versions returned error", "error", err)
-			c.String(500, "")
+			respondWithError(c, err)
 			return
 		}
 
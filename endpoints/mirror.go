@@ -0,0 +1,47 @@
+package endpoints
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mdreem/s3_terraform_registry/logger"
+	"github.com/mdreem/s3_terraform_registry/providerdata"
+	"github.com/mdreem/s3_terraform_registry/registryerror"
+	"strings"
+)
+
+// mirror implements the provider network mirror protocol. Providers of every hostname are served from the same
+// bucket data, so the hostname is ignored.
+func mirror(providerData providerdata.ProviderData) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		hostname := c.Param("hostname")
+		namespace := c.Param("namespace")
+		providerType := c.Param("type")
+		file := c.Param("file")
+
+		logger.Sugar.Infow("called mirror", "hostname", hostname, "namespace", namespace, "type", providerType, "file", file)
+
+		if file == "index.json" {
+			versions, err := providerData.ListVersions(namespace, providerType)
+			if err != nil {
+				logger.Sugar.Errorw("mirror list versions returned error", "error", err)
+				respondWithError(c, err)
+				return
+			}
+			c.JSON(200, providerdata.MirrorVersions(versions))
+			return
+		}
+
+		version := strings.TrimSuffix(file, ".json")
+		if version == file {
+			respondWithError(c, registryerror.NotFound(nil, "%s does not exist", file))
+			return
+		}
+
+		archives, err := providerData.GetMirrorArchives(namespace, providerType, version)
+		if err != nil {
+			logger.Sugar.Errorw("mirror archives returned error", "error", err)
+			respondWithError(c, err)
+			return
+		}
+		c.JSON(200, archives)
+	}
+}
@@ -0,0 +1,73 @@
+package endpoints
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mdreem/s3_terraform_registry/logger"
+	"github.com/mdreem/s3_terraform_registry/moduledata"
+	"github.com/mdreem/s3_terraform_registry/s3"
+	"github.com/mdreem/s3_terraform_registry/schema"
+	"net/http"
+)
+
+func listModuleVersions(moduleData moduledata.ModuleData) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		name := c.Param("name")
+		system := c.Param("system")
+
+		logger.Sugar.Infow("called list module versions", "namespace", namespace, "name", name, "system", system)
+
+		versions, err := moduleData.ListModuleVersions(namespace, name, system)
+		if err != nil {
+			logger.Sugar.Errorw("list module versions returned error", "error", err)
+			respondWithError(c, err)
+			return
+		}
+
+		c.JSON(200, versions)
+	}
+}
+
+func getModuleDownload(moduleData moduledata.ModuleData) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		name := c.Param("name")
+		system := c.Param("system")
+		version := c.Param("version")
+
+		logger.Sugar.Infow("called get module download", "namespace", namespace, "name", name, "system", system, "version", version)
+
+		downloadURL, err := moduleData.GetModuleDownloadURL(namespace, name, system, version)
+		if err != nil {
+			logger.Sugar.Errorw("get module download returned error", "error", err)
+			respondWithError(c, err)
+			return
+		}
+
+		c.Header("X-Terraform-Get", downloadURL)
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func proxyModule(moduleData moduledata.ModuleData) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		name := c.Param("name")
+		system := c.Param("system")
+		version := c.Param("version")
+		filename := c.Param("filename")
+
+		logger.Sugar.Infow("proxy module archive with", "namespace", namespace, "name", name, "system", system, "version", version, "filename", filename)
+
+		archive, err := getFile(c, func(options s3.GetObjectOptions) (schema.ProxyResponse, error) {
+			return moduleData.ProxyModule(namespace, name, system, version, filename, options)
+		})
+		if err != nil {
+			logger.Sugar.Errorw("error proxying module archive", "error", err)
+			respondWithError(c, err)
+			return
+		}
+
+		respondWithFile(c, archive)
+	}
+}
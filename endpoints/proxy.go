@@ -1,12 +1,21 @@
 package endpoints
 
 import (
+	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/mdreem/s3_terraform_registry/logger"
 	"github.com/mdreem/s3_terraform_registry/providerdata"
+	"github.com/mdreem/s3_terraform_registry/registryerror"
+	"github.com/mdreem/s3_terraform_registry/s3"
+	"github.com/mdreem/s3_terraform_registry/schema"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
-func proxy(providerData *providerdata.ProviderData) func(c *gin.Context) {
+func proxy(providerData providerdata.ProviderData) func(c *gin.Context) {
 	return func(c *gin.Context) {
 		namespace := c.Param("namespace")
 		providerType := c.Param("type")
@@ -15,13 +24,120 @@ func proxy(providerData *providerdata.ProviderData) func(c *gin.Context) {
 
 		logger.Sugar.Infow("proxy data with", "namespace", namespace, "type", providerType, "version", version, "filename", filename)
 
-		downloadData, err := (*providerData).Proxy(namespace, providerType, version, filename)
+		downloadData, err := getFile(c, func(options s3.GetObjectOptions) (schema.ProxyResponse, error) {
+			return providerData.Proxy(namespace, providerType, version, filename, options)
+		})
 		if err != nil {
 			logger.Sugar.Errorw("error proxying data", "error", err)
-			c.String(500, "")
+			respondWithError(c, err)
 			return
 		}
 
-		c.DataFromReader(200, downloadData.ContentLength, downloadData.ContentType, downloadData.Body, nil)
+		respondWithFile(c, downloadData)
 	}
 }
+
+// getObjectOptions passes the range and the conditions of the request through to the bucket.
+func getObjectOptions(c *gin.Context) s3.GetObjectOptions {
+	options := s3.GetObjectOptions{
+		Range:       c.GetHeader("Range"),
+		IfNoneMatch: c.GetHeader("If-None-Match"),
+		HeadOnly:    c.Request.Method == http.MethodHead,
+	}
+
+	if ifModifiedSince := c.GetHeader("If-Modified-Since"); ifModifiedSince != "" {
+		modifiedSince, err := http.ParseTime(ifModifiedSince)
+		if err == nil {
+			options.IfModifiedSince = modifiedSince
+		} else {
+			logger.Sugar.Debugw("ignoring invalid If-Modified-Since", "value", ifModifiedSince, "error", err)
+		}
+	}
+	return options
+}
+
+// getFile gets the file with the range and the conditions of the request. S3 does not evaluate If-Range, so the
+// validators of the file are compared beforehand and the range is dropped if they do not match, which returns the
+// whole file. If the range is not satisfiable, Content-Range reports the size of the file.
+func getFile(c *gin.Context, get func(options s3.GetObjectOptions) (schema.ProxyResponse, error)) (schema.ProxyResponse, error) {
+	options := getObjectOptions(c)
+	if ifRange := c.GetHeader("If-Range"); ifRange != "" && options.Range != "" && !options.HeadOnly {
+		file, err := get(s3.GetObjectOptions{HeadOnly: true})
+		if err != nil {
+			return schema.ProxyResponse{}, err
+		}
+		if file.RedirectURL == "" && !ifRangeMatches(ifRange, file) {
+			logger.Sugar.Debugw("ignoring range as If-Range does not match", "range", options.Range, "if-range", ifRange)
+			options.Range = ""
+		}
+	}
+
+	file, err := get(options)
+	if errors.Is(err, registryerror.ErrRangeNotSatisfiable) {
+		whole, headErr := get(s3.GetObjectOptions{HeadOnly: true})
+		if headErr == nil && whole.RedirectURL == "" {
+			c.Header("Content-Range", fmt.Sprintf("bytes */%d", whole.ContentLength))
+		} else {
+			logger.Sugar.Debugw("unable to determine the size of the file", "error", headErr)
+		}
+	}
+	return file, err
+}
+
+// ifRangeMatches checks whether the ETag or the date of an If-Range header matches the file. ETags are compared
+// strongly, dates have to be equal to the last modification, as required for If-Range.
+func ifRangeMatches(ifRange string, file schema.ProxyResponse) bool {
+	if strings.HasPrefix(ifRange, `"`) {
+		return file.ETag == ifRange
+	}
+	if strings.HasPrefix(ifRange, "W/") {
+		return false
+	}
+
+	date, err := http.ParseTime(ifRange)
+	if err != nil {
+		return false
+	}
+	return !file.LastModified.IsZero() && file.LastModified.Truncate(time.Second).Equal(date)
+}
+
+// respondWithFile answers with the file, the requested part of it or only its headers, depending on the request.
+func respondWithFile(c *gin.Context, file schema.ProxyResponse) {
+	if file.RedirectURL != "" {
+		c.Redirect(http.StatusTemporaryRedirect, file.RedirectURL)
+		return
+	}
+
+	headers := map[string]string{"Accept-Ranges": "bytes"}
+	if file.ETag != "" {
+		headers["ETag"] = file.ETag
+	}
+	if !file.LastModified.IsZero() {
+		headers["Last-Modified"] = file.LastModified.UTC().Format(http.TimeFormat)
+	}
+
+	if file.NotModified {
+		for key, value := range headers {
+			c.Header(key, value)
+		}
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	if c.Request.Method == http.MethodHead {
+		for key, value := range headers {
+			c.Header(key, value)
+		}
+		c.Header("Content-Length", strconv.FormatInt(file.ContentLength, 10))
+		c.Header("Content-Type", file.ContentType)
+		c.Status(http.StatusOK)
+		return
+	}
+
+	status := http.StatusOK
+	if file.ContentRange != "" {
+		status = http.StatusPartialContent
+		headers["Content-Range"] = file.ContentRange
+	}
+	c.DataFromReader(status, file.ContentLength, file.ContentType, file.Body, headers)
+}
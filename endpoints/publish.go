@@ -0,0 +1,148 @@
+package endpoints
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/mdreem/s3_terraform_registry/logger"
+	"github.com/mdreem/s3_terraform_registry/publish"
+	"github.com/mdreem/s3_terraform_registry/registryerror"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+)
+
+// DefaultMaxUploadSize is the default limit of the size of a release uploaded to the publish API.
+const DefaultMaxUploadSize = 1 << 30
+
+// maxFieldSize limits the size of the fields other than the archives, as they are read into memory.
+const maxFieldSize = 1 << 20
+
+// publishProvider publishes a release uploaded as multipart form with the fields archives, shasums, signature,
+// public_key and the optional manifest. signature and public_key may be left out if the registry signs releases. The
+// fields other than archives may be sent as files or as plain values, unknown and repeated fields are rejected. The
+// archives are written into a temporary directory while they are received, so they are not held in memory.
+func publishProvider(publisher publish.Publisher, maxUploadSize int64) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		release := publish.Release{
+			Namespace: c.Param("namespace"),
+			Type:      c.Param("type"),
+			Version:   c.Param("version"),
+			Archives:  make(map[string]publish.Archive),
+		}
+		logger.Sugar.Infow("called publish provider", "release", release.String())
+
+		directory, err := os.MkdirTemp("", "publish-")
+		if err != nil {
+			logger.Sugar.Errorw("unable to create directory for uploaded archives", "error", err)
+			respondWithError(c, err)
+			return
+		}
+		defer func() {
+			if err := os.RemoveAll(directory); err != nil {
+				logger.Sugar.Warnw("unable to remove uploaded archives", "directory", directory, "error", err)
+			}
+		}()
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxUploadSize)
+		reader, err := c.Request.MultipartReader()
+		if err != nil {
+			respondWithError(c, registryerror.BadRequest(err, "expected a multipart form"))
+			return
+		}
+
+		var publicKey []byte
+		fields := map[string]*[]byte{
+			"shasums":    &release.ShaSums,
+			"signature":  &release.Signature,
+			"public_key": &publicKey,
+			"manifest":   &release.Manifest,
+		}
+		received := make(map[string]bool)
+		for {
+			part, err := reader.NextPart()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				respondWithError(c, uploadError(err, maxUploadSize))
+				return
+			}
+
+			target, isField := fields[part.FormName()]
+			switch {
+			case part.FormName() == "archives":
+				err = readArchive(part, directory, release.Archives)
+			case !isField:
+				err = registryerror.BadRequest(nil, "unknown field %s", part.FormName())
+			case received[part.FormName()]:
+				err = registryerror.BadRequest(nil, "the field %s is uploaded twice", part.FormName())
+			default:
+				received[part.FormName()] = true
+				*target, err = readField(part)
+			}
+			_ = part.Close()
+			if err != nil {
+				respondWithError(c, uploadError(err, maxUploadSize))
+				return
+			}
+		}
+		if len(release.ShaSums) == 0 {
+			respondWithError(c, registryerror.BadRequest(nil, "the field shasums is required"))
+			return
+		}
+		release.PublicKey = string(publicKey)
+
+		published, err := publisher.Publish(release)
+		if err != nil {
+			logger.Sugar.Errorw("publish provider returned error", "release", release.String(), "error", err)
+			respondWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, published)
+	}
+}
+
+// readArchive writes the archive in part into directory and adds it to archives.
+func readArchive(part *multipart.Part, directory string, archives map[string]publish.Archive) error {
+	filename := part.FileName()
+	if filename == "" {
+		return registryerror.BadRequest(nil, "the archives need a file name")
+	}
+	if _, ok := archives[filename]; ok {
+		return registryerror.BadRequest(nil, "%s is uploaded twice", filename)
+	}
+
+	archive, err := publish.SpoolArchive(directory, filename, part)
+	if err != nil {
+		return err
+	}
+	archives[filename] = archive
+	return nil
+}
+
+func readField(part *multipart.Part) ([]byte, error) {
+	content, err := io.ReadAll(io.LimitReader(part, maxFieldSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > maxFieldSize {
+		return nil, registryerror.TooLarge(nil, "the field %s exceeds %d bytes", part.FormName(), maxFieldSize)
+	}
+	return content, nil
+}
+
+// uploadError classifies errors which occurred while reading the upload.
+func uploadError(err error, maxUploadSize int64) error {
+	var registryError *registryerror.Error
+	var maxBytesError *http.MaxBytesError
+	switch {
+	case errors.As(err, &registryError):
+		return err
+	case errors.As(err, &maxBytesError):
+		return registryerror.TooLarge(err, "the release exceeds %d bytes", maxUploadSize)
+	default:
+		return registryerror.BadRequest(err, "unable to read the upload")
+	}
+}
@@ -3,21 +3,69 @@ package endpoints
 import (
 	ginzap "github.com/gin-contrib/zap"
 	"github.com/gin-gonic/gin"
+	"github.com/mdreem/s3_terraform_registry/auth"
 	"github.com/mdreem/s3_terraform_registry/cache"
 	"github.com/mdreem/s3_terraform_registry/logger"
-	"github.com/mdreem/s3_terraform_registry/providerdata"
+	"github.com/mdreem/s3_terraform_registry/publish"
 	"time"
 )
 
-func SetupRouter(cacheableProviderData cache.CacheableProviderData) *gin.Engine {
-	providerData, ok := cacheableProviderData.(providerdata.ProviderData)
-	if !ok {
-		logger.Sugar.Panicw("unable to cast to ProviderData.")
+type routerConfig struct {
+	tokens          auth.Tokens
+	protectedScopes map[auth.Scope]bool
+	publisher       *publish.Publisher
+	maxUploadSize   int64
+	bucketName      string
+}
+
+type Option func(config *routerConfig)
+
+// WithAuthentication requires a bearer token from tokens granting the respective scope for the routes of every scope
+// in protectedScopes. Routes of other scopes stay public, except for the administrative routes, which always require
+// a token granting auth.ScopeAdmin.
+func WithAuthentication(tokens auth.Tokens, protectedScopes ...auth.Scope) Option {
+	return func(config *routerConfig) {
+		config.tokens = tokens
+		for _, scope := range protectedScopes {
+			config.protectedScopes[scope] = true
+		}
+	}
+}
+
+// WithPublishing serves the publish API, which writes releases with publisher. It always requires a token granting
+// auth.ScopePublish.
+func WithPublishing(publisher publish.Publisher) Option {
+	return func(config *routerConfig) {
+		config.publisher = &publisher
 	}
+}
+
+// WithMaxUploadSize limits the size of the requests of the publish API to maxUploadSize bytes.
+func WithMaxUploadSize(maxUploadSize int64) Option {
+	return func(config *routerConfig) {
+		config.maxUploadSize = maxUploadSize
+	}
+}
 
-	cache, ok := cacheableProviderData.(cache.Cache)
-	if !ok {
-		logger.Sugar.Panicw("unable to cast to Cache.")
+// WithBucketName accepts only event notifications about objects in the bucket bucketName.
+func WithBucketName(bucketName string) Option {
+	return func(config *routerConfig) {
+		config.bucketName = bucketName
+	}
+}
+
+// middleware returns the handlers guarding the routes of scope.
+func (config routerConfig) middleware(scope auth.Scope) []gin.HandlerFunc {
+	if !config.protectedScopes[scope] {
+		return nil
+	}
+	return []gin.HandlerFunc{requireScope(config.tokens, scope)}
+}
+
+func SetupRouter(cacheableProviderData cache.CacheableProviderData, options ...Option) *gin.Engine {
+	config := routerConfig{protectedScopes: make(map[auth.Scope]bool), maxUploadSize: DefaultMaxUploadSize}
+	for _, option := range options {
+		option(&config)
 	}
 
 	gin.SetMode(gin.ReleaseMode)
@@ -28,11 +76,34 @@ func SetupRouter(cacheableProviderData cache.CacheableProviderData) *gin.Engine
 
 	r.GET("/.well-known/terraform.json", discovery())
 
-	r.GET("/v1/providers/:namespace/:type/versions", listVersions(&providerData))
-	r.GET("/v1/providers/:namespace/:type/:version/download/:os/:arch", getDownloadData(&providerData))
+	api := r.Group("", config.middleware(auth.ScopeAPI)...)
+	api.GET("/v1/providers/:namespace/:type/versions", listVersions(cacheableProviderData))
+	api.GET("/v1/providers/:namespace/:type/:version/download/:os/:arch", getDownloadData(cacheableProviderData))
+
+	api.GET("/mirror/:hostname/:namespace/:type/:file", mirror(cacheableProviderData))
+
+	api.GET("/v1/modules/:namespace/:name/:system/versions", listModuleVersions(cacheableProviderData))
+	api.GET("/v1/modules/:namespace/:name/:system/:version/download", getModuleDownload(cacheableProviderData))
+
+	downloads := r.Group("", config.middleware(auth.ScopeDownload)...)
+	downloads.GET("/v1/modules/:namespace/:name/:system/:version/archive/:filename", proxyModule(cacheableProviderData))
+	downloads.HEAD("/v1/modules/:namespace/:name/:system/:version/archive/:filename", proxyModule(cacheableProviderData))
+
+	downloads.GET("/proxy/:namespace/:type/:version/:filename", proxy(cacheableProviderData))
+	downloads.HEAD("/proxy/:namespace/:type/:version/:filename", proxy(cacheableProviderData))
+
+	// administrative routes always require a token granting the admin scope
+	admin := r.Group("/admin", requireScope(config.tokens, auth.ScopeAdmin))
+	admin.POST("/refresh", refreshHandler(newRefreshCoalescer(cacheableProviderData)))
+
+	if config.publisher != nil {
+		publishing := r.Group("/publish", requireScope(config.tokens, auth.ScopePublish))
+		publishing.POST("/providers/:namespace/:type/:version", publishProvider(*config.publisher, config.maxUploadSize))
+	}
 
-	r.GET("/proxy/:namespace/:type/:version/:filename", proxy(&providerData))
-	r.GET("/refresh", refreshHandler(&cache))
+	// event notifications make the registry list the bucket, so they always require a token granting the events scope
+	eventRoutes := r.Group("/events", requireScope(config.tokens, auth.ScopeEvents))
+	eventRoutes.POST("/s3", s3EventHandler(cacheableProviderData, config.bucketName))
 
 	return r
 }
@@ -1,18 +1,33 @@
 package endpoints
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/mdreem/s3_terraform_registry/auth"
 	"github.com/mdreem/s3_terraform_registry/cache"
 	"github.com/mdreem/s3_terraform_registry/internal/testsupport"
 	"github.com/mdreem/s3_terraform_registry/logger"
+	"github.com/mdreem/s3_terraform_registry/moduledata"
+	"github.com/mdreem/s3_terraform_registry/pgp"
 	"github.com/mdreem/s3_terraform_registry/providerdata"
+	"github.com/mdreem/s3_terraform_registry/publish"
 	"github.com/mdreem/s3_terraform_registry/s3"
 	"github.com/mdreem/s3_terraform_registry/schema"
 	"go.uber.org/zap"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestGetVersions(t *testing.T) {
@@ -70,9 +85,10 @@ func TestGetDownloadData(t *testing.T) {
 		"black/lodge/",
 		"black/lodge/1.0.0/",
 		"black/lodge/1.0.1/",
+		"black/lodge/1.0.1/terraform-provider-lodge_1.0.1_linux_amd64.zip",
 	}, map[string]s3.BucketObject{
 		"black/lodge/1.0.1/shasum": {
-			Body:          testsupport.CreateReaderFor("sha315 coffee"),
+			Body:          testsupport.CreateReaderFor("caf90169eefa5f807d577486b9f795ab86ae2983c5c20806cff959117e90af18  terraform-provider-lodge_1.0.1_linux_amd64.zip\n"),
 			ContentLength: 0,
 			ContentType:   "",
 		},
@@ -118,7 +134,7 @@ func TestGetDownloadData(t *testing.T) {
 		DownloadURL:         "https://twin.peaks/proxy/black/lodge/1.0.1/terraform-provider-lodge_1.0.1_linux_amd64.zip",
 		ShasumsURL:          "https://twin.peaks/proxy/black/lodge/1.0.1/shasum",
 		ShasumsSignatureURL: "https://twin.peaks/proxy/black/lodge/1.0.1/shasum.sig",
-		Shasum:              "sha315",
+		Shasum:              "caf90169eefa5f807d577486b9f795ab86ae2983c5c20806cff959117e90af18",
 		SigningKeys: struct {
 			GpgPublicKeys []schema.GpgPublicKey `json:"gpg_public_keys"`
 		}{
@@ -144,6 +160,7 @@ func TestProxy(t *testing.T) {
 		"black/lodge/",
 		"black/lodge/1.0.0/",
 		"black/lodge/1.0.1/",
+		"black/lodge/1.0.1/terraform-provider-lodge_1.0.1_linux_amd64.zip",
 	}, map[string]s3.BucketObject{
 		"black/lodge/1.0.1/terraform-provider-lodge_1.0.1_linux_amd64.zip": {
 			Body:          testsupport.CreateReaderFor("315 coffee provider"),
@@ -175,6 +192,232 @@ func TestProxy(t *testing.T) {
 	}
 }
 
+func TestProxyRangesAndConditions(t *testing.T) {
+	logger.Logger, _ = zap.NewDevelopment()
+	logger.Sugar = logger.Logger.Sugar()
+
+	lastModified := time.Date(1989, 2, 24, 11, 30, 0, 0, time.UTC)
+	const url = "/proxy/black/lodge/1.0.1/terraform-provider-lodge_1.0.1_linux_amd64.zip"
+
+	tests := []struct {
+		name        string
+		method      string
+		headers     map[string]string
+		wantStatus  int
+		wantBody    string
+		wantHeaders map[string]string
+	}{
+		{
+			name:       "get whole file",
+			method:     "GET",
+			wantStatus: http.StatusOK,
+			wantBody:   "315 coffee provider",
+			wantHeaders: map[string]string{
+				"ETag":          `"coffee"`,
+				"Last-Modified": "Fri, 24 Feb 1989 11:30:00 GMT",
+				"Accept-Ranges": "bytes",
+			},
+		},
+		{
+			name:        "get range",
+			method:      "GET",
+			headers:     map[string]string{"Range": "bytes=4-9"},
+			wantStatus:  http.StatusPartialContent,
+			wantBody:    "coffee",
+			wantHeaders: map[string]string{"Content-Range": "bytes 4-9/19", "Content-Length": "6"},
+		},
+		{
+			name:        "get unsatisfiable range",
+			method:      "GET",
+			headers:     map[string]string{"Range": "bytes=20-"},
+			wantStatus:  http.StatusRequestedRangeNotSatisfiable,
+			wantBody:    `{"errors":["range bytes=20- of black/lodge/1.0.1/terraform-provider-lodge_1.0.1_linux_amd64.zip is not satisfiable"]}`,
+			wantHeaders: map[string]string{"Content-Range": "bytes */19"},
+		},
+		{
+			name:        "get range with matching If-Range ETag",
+			method:      "GET",
+			headers:     map[string]string{"Range": "bytes=4-9", "If-Range": `"coffee"`},
+			wantStatus:  http.StatusPartialContent,
+			wantBody:    "coffee",
+			wantHeaders: map[string]string{"Content-Range": "bytes 4-9/19"},
+		},
+		{
+			name:        "get whole file with outdated If-Range ETag",
+			method:      "GET",
+			headers:     map[string]string{"Range": "bytes=4-9", "If-Range": `"pie"`},
+			wantStatus:  http.StatusOK,
+			wantBody:    "315 coffee provider",
+			wantHeaders: map[string]string{"Content-Range": ""},
+		},
+		{
+			name:        "get range with matching If-Range date",
+			method:      "GET",
+			headers:     map[string]string{"Range": "bytes=4-9", "If-Range": "Fri, 24 Feb 1989 11:30:00 GMT"},
+			wantStatus:  http.StatusPartialContent,
+			wantBody:    "coffee",
+			wantHeaders: map[string]string{"Content-Range": "bytes 4-9/19"},
+		},
+		{
+			name:        "get whole file with outdated If-Range date",
+			method:      "GET",
+			headers:     map[string]string{"Range": "bytes=4-9", "If-Range": "Thu, 23 Feb 1989 11:30:00 GMT"},
+			wantStatus:  http.StatusOK,
+			wantBody:    "315 coffee provider",
+			wantHeaders: map[string]string{"Content-Range": ""},
+		},
+		{
+			name:       "get file with matching ETag",
+			method:     "GET",
+			headers:    map[string]string{"If-None-Match": `"coffee"`},
+			wantStatus: http.StatusNotModified,
+		},
+		{
+			name:       "get file modified since",
+			method:     "GET",
+			headers:    map[string]string{"If-Modified-Since": "Thu, 23 Feb 1989 11:30:00 GMT"},
+			wantStatus: http.StatusOK,
+			wantBody:   "315 coffee provider",
+		},
+		{
+			name:       "get file not modified since",
+			method:     "GET",
+			headers:    map[string]string{"If-Modified-Since": "Fri, 24 Feb 1989 11:30:00 GMT"},
+			wantStatus: http.StatusNotModified,
+		},
+		{
+			name:        "head file",
+			method:      "HEAD",
+			wantStatus:  http.StatusOK,
+			wantHeaders: map[string]string{"Content-Length": "19", "ETag": `"coffee"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testBucketWithObjects := testsupport.NewTestBucketWithObjects([]string{
+				"black/lodge/1.0.1/terraform-provider-lodge_1.0.1_linux_amd64.zip",
+			}, map[string]s3.BucketObject{
+				"black/lodge/1.0.1/terraform-provider-lodge_1.0.1_linux_amd64.zip": {
+					Body:          testsupport.CreateReaderFor("315 coffee provider"),
+					ContentLength: 19,
+					ETag:          `"coffee"`,
+					LastModified:  lastModified,
+				},
+			})
+			providerData, err := providerdata.NewS3Backend(testBucketWithObjects, "twin.peaks")
+			if err != nil {
+				t.Fatalf("error creating providerData: %v", err)
+			}
+			registryCache := cache.NewCache(providerData, testBucketWithObjects)
+			if err := registryCache.Refresh(); err != nil {
+				t.Fatalf("error refreshing cache: %v", err)
+			}
+			r := SetupRouter(registryCache)
+
+			req, _ := http.NewRequest(tt.method, url, nil)
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status code: got = %v, want %v", w.Code, tt.wantStatus)
+			}
+			if body := w.Body.String(); body != tt.wantBody {
+				t.Errorf("body: got = %v, want %v", body, tt.wantBody)
+			}
+			for key, value := range tt.wantHeaders {
+				if got := w.Header().Get(key); got != value {
+					t.Errorf("header %s: got = %v, want %v", key, got, value)
+				}
+			}
+		})
+	}
+}
+
+func TestProxyRedirectsToPresignedURL(t *testing.T) {
+	logger.Logger, _ = zap.NewDevelopment()
+	logger.Sugar = logger.Logger.Sugar()
+
+	testBucketWithObjects := testsupport.NewTestBucketWithObjects([]string{
+		"black/lodge/1.0.1/terraform-provider-lodge_1.0.1_linux_amd64.zip",
+	}, nil)
+	providerData, err := providerdata.NewS3Backend(testBucketWithObjects, "twin.peaks", providerdata.WithPresignedDownloads(testsupport.TestPresigner{}, time.Minute))
+	if err != nil {
+		t.Fatalf("error creating providerData: %v", err)
+	}
+
+	registryCache := cache.NewCache(providerData, testBucketWithObjects)
+	if err := registryCache.Refresh(); err != nil {
+		t.Fatalf("error refreshing cache: %v", err)
+	}
+	r := SetupRouter(registryCache)
+
+	req, _ := http.NewRequest("GET", "/proxy/black/lodge/1.0.1/terraform-provider-lodge_1.0.1_linux_amd64.zip", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusTemporaryRedirect {
+		t.Errorf("status code: got = %v, want %v", w.Code, http.StatusTemporaryRedirect)
+	}
+	const wantedLocation = "https://s3.twin.peaks/black/lodge/1.0.1/terraform-provider-lodge_1.0.1_linux_amd64.zip?expires=60"
+	if location := w.Header().Get("Location"); location != wantedLocation {
+		t.Errorf("location: got = %v, want %v", location, wantedLocation)
+	}
+}
+
+func TestHiddenVersionsAreNotServed(t *testing.T) {
+	logger.Logger, _ = zap.NewDevelopment()
+	logger.Sugar = logger.Logger.Sugar()
+
+	key := testsupport.NewSigningKey("Dale Cooper")
+	otherKey := testsupport.NewSigningKey("Windom Earle")
+	objects := make(map[string]string)
+	for version, signingKey := range map[string]testsupport.SigningKey{"1.0.0": key, "1.0.1": otherKey} {
+		archiveName := fmt.Sprintf("terraform-provider-lodge_%s_linux_amd64.zip", version)
+		shaSums := fmt.Sprintf("caf90169eefa5f807d577486b9f795ab86ae2983c5c20806cff959117e90af18  %s\n", archiveName)
+		objects["black/lodge/"+version+"/"+archiveName] = "315 coffee provider"
+		objects["black/lodge/"+version+"/shasum"] = shaSums
+		objects["black/lodge/"+version+"/shasum.sig"] = string(signingKey.Sign([]byte(shaSums)))
+		objects["black/lodge/"+version+"/keyfile"] = key.ArmoredPublicKey()
+		objects["black/lodge/"+version+"/key_id"] = key.KeyID()
+	}
+	bucket := testsupport.NewMemoryBucket(objects)
+	providerData, err := providerdata.NewS3Backend(bucket, "twin.peaks", providerdata.WithSignatureVerification(providerdata.VerifySignaturesHide))
+	if err != nil {
+		t.Fatalf("error creating providerData: %v", err)
+	}
+	registryCache := cache.NewCache(providerData, bucket)
+	if err := registryCache.Refresh(); err != nil {
+		t.Fatalf("error refreshing cache: %v", err)
+	}
+	r := SetupRouter(registryCache)
+
+	tests := []struct {
+		url        string
+		wantStatus int
+	}{
+		{url: "/v1/providers/black/lodge/1.0.0/download/linux/amd64", wantStatus: http.StatusOK},
+		{url: "/v1/providers/black/lodge/1.0.1/download/linux/amd64", wantStatus: http.StatusNotFound},
+		{url: "/mirror/registry.terraform.io/black/lodge/1.0.0.json", wantStatus: http.StatusOK},
+		{url: "/mirror/registry.terraform.io/black/lodge/1.0.1.json", wantStatus: http.StatusNotFound},
+		{url: "/proxy/black/lodge/1.0.1/terraform-provider-lodge_1.0.1_linux_amd64.zip", wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", tt.url, nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status code: got = %v, want %v, body %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+		})
+	}
+}
+
 func TestRefresh(t *testing.T) {
 	logger.Logger, _ = zap.NewDevelopment()
 	logger.Sugar = logger.Logger.Sugar()
@@ -201,16 +444,39 @@ func TestRefresh(t *testing.T) {
 		t.Errorf("expected error here as cache is empty")
 	}
 
-	r := SetupRouter(cache)
-	req, _ := http.NewRequest("GET", "/refresh", nil)
+	tokens, err := auth.ParseTokens("owl-cave api,download\nred-room admin\n")
+	if err != nil {
+		t.Fatalf("error parsing tokens: %v", err)
+	}
+	r := SetupRouter(cache, WithAuthentication(tokens))
+
+	for authorization, wantStatus := range map[string]int{"": http.StatusUnauthorized, "Bearer owl-cave": http.StatusForbidden} {
+		req, _ := http.NewRequest("POST", "/admin/refresh", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != wantStatus {
+			t.Errorf("refreshing cache with authorization %q: got status %d, want %d", authorization, w.Code, wantStatus)
+		}
+	}
+
+	req, _ := http.NewRequest("POST", "/admin/refresh", nil)
+	req.Header.Set("Authorization", "Bearer red-room")
 
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
 
-	responseData := w.Body.String()
-	const wantedResponse = "refreshed"
-	if responseData != wantedResponse {
-		t.Errorf("refreshing cache: got = %v, want %v", responseData, wantedResponse)
+	if w.Code != http.StatusOK {
+		t.Fatalf("refreshing cache: got status %d, want %d", w.Code, http.StatusOK)
+	}
+	summary := schema.RefreshSummary{}
+	if err := json.Unmarshal(w.Body.Bytes(), &summary); err != nil {
+		t.Fatalf("error unmarshalling summary: %v", err)
+	}
+	if summary.Providers != 1 || summary.Generation != 1 || len(summary.Errors) != 0 || summary.Duration == "" {
+		t.Errorf("refreshing cache: got summary %+v", summary)
 	}
 
 	versions, err := cache.ListVersions("black", "lodge")
@@ -223,3 +489,836 @@ func TestRefresh(t *testing.T) {
 		t.Errorf("fetching cached data: got = %v, want %v", versions.ID, wantedVersionsID)
 	}
 }
+
+// blockingCache counts refreshes and blocks them until release is closed.
+type blockingCache struct {
+	cache.CacheableProviderData
+	started   chan struct{}
+	release   chan struct{}
+	refreshes atomic.Int64
+}
+
+func (blockingCache *blockingCache) Refresh() error {
+	if blockingCache.refreshes.Add(1) == 1 {
+		close(blockingCache.started)
+	}
+	<-blockingCache.release
+	return errors.New("bucket unavailable")
+}
+
+func TestRefreshIsCoalesced(t *testing.T) {
+	logger.Logger, _ = zap.NewDevelopment()
+	logger.Sugar = logger.Logger.Sugar()
+
+	registryCache := &blockingCache{
+		CacheableProviderData: cache.NewCache(testsupport.NewTestProviderData(), testsupport.NewTestBucket(nil)),
+		started:               make(chan struct{}),
+		release:               make(chan struct{}),
+	}
+	coalescer := newRefreshCoalescer(registryCache)
+
+	const requests = 5
+	var wg sync.WaitGroup
+	errs := make(chan error, requests)
+	refresh := func() {
+		defer wg.Done()
+		_, err := coalescer.refresh()
+		errs <- err
+	}
+
+	wg.Add(1)
+	go refresh()
+	<-registryCache.started
+	for i := 1; i < requests; i++ {
+		wg.Add(1)
+		go refresh()
+	}
+	// give the other requests time to join the running refresh
+	time.Sleep(100 * time.Millisecond)
+	close(registryCache.release)
+	wg.Wait()
+	close(errs)
+
+	if refreshes := registryCache.refreshes.Load(); refreshes != 1 {
+		t.Errorf("refreshes = %d, want 1", refreshes)
+	}
+	for err := range errs {
+		if err == nil {
+			t.Errorf("expected every request to receive the error of the refresh")
+		}
+	}
+}
+
+// panickingCache panics on its first refresh.
+type panickingCache struct {
+	cache.CacheableProviderData
+	refreshes atomic.Int64
+}
+
+func (panickingCache *panickingCache) Refresh() error {
+	if panickingCache.refreshes.Add(1) == 1 {
+		panic("refresh failed")
+	}
+	return nil
+}
+
+func TestRefreshAfterPanic(t *testing.T) {
+	logger.Logger, _ = zap.NewDevelopment()
+	logger.Sugar = logger.Logger.Sugar()
+
+	registryCache := &panickingCache{
+		CacheableProviderData: cache.NewCache(testsupport.NewTestProviderData(), testsupport.NewTestBucket(nil)),
+	}
+	tokens, err := auth.ParseTokens("red-room admin\n")
+	if err != nil {
+		t.Fatalf("error parsing tokens: %v", err)
+	}
+	r := SetupRouter(registryCache, WithAuthentication(tokens))
+
+	for _, wantStatus := range []int{http.StatusInternalServerError, http.StatusOK} {
+		done := make(chan int)
+		go func() {
+			req, _ := http.NewRequest("POST", "/admin/refresh", nil)
+			req.Header.Set("Authorization", "Bearer red-room")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			done <- w.Code
+		}()
+
+		select {
+		case status := <-done:
+			if status != wantStatus {
+				t.Errorf("refreshing cache: got status %d, want %d", status, wantStatus)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("refreshing cache after a panicking refresh blocked")
+		}
+	}
+}
+
+func TestS3Events(t *testing.T) {
+	logger.Logger, _ = zap.NewDevelopment()
+	logger.Sugar = logger.Logger.Sugar()
+
+	testBucketWithObjects := testsupport.NewTestBucketWithObjects([]string{
+		"black/lodge/1.0.0/terraform-provider-lodge_1.0.0_linux_amd64.zip",
+	}, nil)
+	providerData, err := providerdata.NewS3Backend(testBucketWithObjects, "twin.peaks")
+	if err != nil {
+		t.Fatalf("error creating providerData: %v", err)
+	}
+	registryCache := cache.NewCache(providerData, testBucketWithObjects)
+
+	tokens, err := auth.ParseTokens("owl-cave\nred-room events\n")
+	if err != nil {
+		t.Fatalf("error parsing tokens: %v", err)
+	}
+	r := SetupRouter(registryCache, WithAuthentication(tokens), WithBucketName("registry"))
+	const notification = `{"Records":[{"eventName":"ObjectCreated:Put","s3":{"bucket":{"name":"registry"},"object":{"key":"black/lodge/1.0.0/terraform-provider-lodge_1.0.0_linux_amd64.zip"}}}]}`
+
+	for authorization, wantStatus := range map[string]int{"": http.StatusUnauthorized, "Bearer owl-cave": http.StatusForbidden} {
+		req, _ := http.NewRequest("POST", "/events/s3", strings.NewReader(notification))
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != wantStatus {
+			t.Errorf("handling event with authorization %q: got status %d, want %d", authorization, w.Code, wantStatus)
+		}
+	}
+
+	// SNS sends the token as password of basic credentials
+	req, _ := http.NewRequest("POST", "/events/s3", strings.NewReader(notification))
+	req.SetBasicAuth("sns", "red-room")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	refreshed := schema.Refreshed{}
+	err = json.Unmarshal(w.Body.Bytes(), &refreshed)
+	if err != nil {
+		t.Fatalf("error umarshalling: %v", err)
+	}
+	wantedRefreshed := schema.Refreshed{Providers: []string{"black/lodge"}, Modules: []string{}}
+	if !reflect.DeepEqual(refreshed, wantedRefreshed) {
+		t.Errorf("handling event: got = %v, want %v", refreshed, wantedRefreshed)
+	}
+
+	versions, err := registryCache.ListVersions("black", "lodge")
+	if err != nil {
+		t.Fatalf("error listing versions: %v", err)
+	}
+	if len(versions.Versions) != 1 {
+		t.Errorf("fetching cached data: got = %v, want one version", versions.Versions)
+	}
+
+	for _, payload := range []string{"{}", strings.Replace(notification, `"registry"`, `"other"`, 1)} {
+		req, _ = http.NewRequest("POST", "/events/s3", strings.NewReader(payload))
+		req.Header.Set("Authorization", "Bearer red-room")
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("status code of %s: got = %v, want %v", payload, w.Code, http.StatusBadRequest)
+		}
+	}
+
+	largeNotification := strings.Replace(notification, `"Records"`, `"padding":"`+strings.Repeat("x", maxEventSize)+`","Records"`, 1)
+	req, _ = http.NewRequest("POST", "/events/s3", strings.NewReader(largeNotification))
+	req.Header.Set("Authorization", "Bearer red-room")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status code of large event: got = %v, want %v", w.Code, http.StatusRequestEntityTooLarge)
+	}
+}
+
+func TestMirror(t *testing.T) {
+	logger.Logger, _ = zap.NewDevelopment()
+	logger.Sugar = logger.Logger.Sugar()
+
+	testBucketWithObjects := testsupport.NewTestBucketWithObjects([]string{
+		"black/lodge/1.0.1/terraform-provider-lodge_1.0.1_linux_amd64.zip",
+	}, map[string]s3.BucketObject{
+		"black/lodge/1.0.1/shasum": {
+			Body: testsupport.CreateReaderFor("caf90169eefa5f807d577486b9f795ab86ae2983c5c20806cff959117e90af18  terraform-provider-lodge_1.0.1_linux_amd64.zip\n"),
+		},
+		"black/lodge/1.0.1/key_id": {
+			Body: testsupport.CreateReaderFor("315"),
+		},
+		"black/lodge/1.0.1/keyfile": {
+			Body: testsupport.CreateReaderFor("KEY"),
+		},
+	})
+	providerData, err := providerdata.NewS3Backend(testBucketWithObjects, "twin.peaks")
+	if err != nil {
+		t.Fatalf("error creating providerData: %v", err)
+	}
+	registryCache := cache.NewCache(providerData, testBucketWithObjects)
+	err = registryCache.Refresh()
+	if err != nil {
+		t.Fatalf("error refreshing cache: %v", err)
+	}
+
+	r := SetupRouter(registryCache)
+
+	req, _ := http.NewRequest("GET", "/mirror/registry.terraform.io/black/lodge/index.json", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	mirrorVersions := schema.MirrorVersions{}
+	err = json.Unmarshal(w.Body.Bytes(), &mirrorVersions)
+	if err != nil {
+		t.Fatalf("error umarshalling: %v", err)
+	}
+	wantedMirrorVersions := schema.MirrorVersions{Versions: map[string]schema.MirrorVersion{"1.0.1": {}}}
+	if !reflect.DeepEqual(mirrorVersions, wantedMirrorVersions) {
+		t.Errorf("fetching mirror versions: got = %v, want %v", mirrorVersions, wantedMirrorVersions)
+	}
+
+	req, _ = http.NewRequest("GET", "/mirror/registry.terraform.io/black/lodge/1.0.1.json", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	mirrorArchives := schema.MirrorArchives{}
+	err = json.Unmarshal(w.Body.Bytes(), &mirrorArchives)
+	if err != nil {
+		t.Fatalf("error umarshalling: %v", err)
+	}
+	wantedMirrorArchives := schema.MirrorArchives{Archives: map[string]schema.MirrorArchive{
+		"linux_amd64": {
+			URL:    "https://twin.peaks/proxy/black/lodge/1.0.1/terraform-provider-lodge_1.0.1_linux_amd64.zip",
+			Hashes: []string{"zh:caf90169eefa5f807d577486b9f795ab86ae2983c5c20806cff959117e90af18"},
+		},
+	}}
+	if !reflect.DeepEqual(mirrorArchives, wantedMirrorArchives) {
+		t.Errorf("fetching mirror archives: got = %v, want %v", mirrorArchives, wantedMirrorArchives)
+	}
+
+	req, _ = http.NewRequest("GET", "/mirror/registry.terraform.io/black/lodge/1.0.1.zip", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status code: got = %v, want %v", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestModules(t *testing.T) {
+	logger.Logger, _ = zap.NewDevelopment()
+	logger.Sugar = logger.Logger.Sugar()
+
+	testBucketWithObjects := testsupport.NewTestBucketWithObjects([]string{
+		"modules/black/lodge/aws/1.0.0/lodge.tar.gz",
+	}, nil)
+	providerData, err := providerdata.NewS3Backend(testBucketWithObjects, "twin.peaks")
+	if err != nil {
+		t.Fatalf("error creating providerData: %v", err)
+	}
+	moduleData, err := moduledata.NewS3Backend(testBucketWithObjects, "twin.peaks")
+	if err != nil {
+		t.Fatalf("error creating moduleData: %v", err)
+	}
+	registryCache := cache.NewCache(providerData, testBucketWithObjects, cache.WithModules(moduleData))
+	err = registryCache.Refresh()
+	if err != nil {
+		t.Fatalf("error refreshing cache: %v", err)
+	}
+
+	r := SetupRouter(registryCache)
+
+	req, _ := http.NewRequest("GET", "/.well-known/terraform.json", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	discovery := schema.Discovery{}
+	err = json.Unmarshal(w.Body.Bytes(), &discovery)
+	if err != nil {
+		t.Fatalf("error umarshalling: %v", err)
+	}
+	if discovery.ModulesV1 != "/v1/modules/" {
+		t.Errorf("discovery: got = %v, want /v1/modules/", discovery.ModulesV1)
+	}
+
+	req, _ = http.NewRequest("GET", "/v1/modules/black/lodge/aws/versions", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	moduleVersions := schema.ModuleVersions{}
+	err = json.Unmarshal(w.Body.Bytes(), &moduleVersions)
+	if err != nil {
+		t.Fatalf("error umarshalling: %v", err)
+	}
+	wantedModuleVersions := schema.ModuleVersions{Modules: []schema.ModuleVersionList{{Versions: []schema.ModuleVersion{{Version: "1.0.0"}}}}}
+	if !reflect.DeepEqual(moduleVersions, wantedModuleVersions) {
+		t.Errorf("fetching module versions: got = %v, want %v", moduleVersions, wantedModuleVersions)
+	}
+
+	req, _ = http.NewRequest("GET", "/v1/modules/black/lodge/aws/1.0.0/download", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("status code: got = %v, want %v", w.Code, http.StatusNoContent)
+	}
+	const wantedDownloadURL = "https://twin.peaks/v1/modules/black/lodge/aws/1.0.0/archive/lodge.tar.gz"
+	if downloadURL := w.Header().Get("X-Terraform-Get"); downloadURL != wantedDownloadURL {
+		t.Errorf("download URL: got = %v, want %v", downloadURL, wantedDownloadURL)
+	}
+
+	req, _ = http.NewRequest("GET", "/v1/modules/black/lodge/aws/1.0.0/archive/lodge.tar.gz", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	const wantedArchive = "Object Data for: modules/black/lodge/aws/1.0.0/lodge.tar.gz"
+	if archive := w.Body.String(); archive != wantedArchive {
+		t.Errorf("proxying module archive: got = %v, want %v", archive, wantedArchive)
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	logger.Logger, _ = zap.NewDevelopment()
+	logger.Sugar = logger.Logger.Sugar()
+
+	testBucketWithObjects := testsupport.NewTestBucket([]string{
+		"black/lodge/1.0.0/terraform-provider-lodge_1.0.0_linux_amd64.zip",
+	})
+	registryCache := cache.NewCache(testsupport.NewTestProviderData(), testBucketWithObjects)
+	err := registryCache.Refresh()
+	if err != nil {
+		t.Fatalf("error refreshing cache: %v", err)
+	}
+
+	tokens, err := auth.ParseTokens("owl-cave api\nred-room download\n")
+	if err != nil {
+		t.Fatalf("error parsing tokens: %v", err)
+	}
+	r := SetupRouter(registryCache, WithAuthentication(tokens, auth.ScopeAPI))
+
+	tests := []struct {
+		name          string
+		url           string
+		authorization string
+		wantStatus    int
+		wantErrors    schema.Errors
+	}{
+		{
+			name:       "discovery is public",
+			url:        "/.well-known/terraform.json",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "missing token",
+			url:        "/v1/providers/black/lodge/versions",
+			wantStatus: http.StatusUnauthorized,
+			wantErrors: schema.Errors{Errors: []string{"a bearer token is required"}},
+		},
+		{
+			name:          "invalid token",
+			url:           "/v1/providers/black/lodge/versions",
+			authorization: "Bearer white-lodge",
+			wantStatus:    http.StatusUnauthorized,
+			wantErrors:    schema.Errors{Errors: []string{"the bearer token is invalid"}},
+		},
+		{
+			name:          "token without scope",
+			url:           "/v1/providers/black/lodge/versions",
+			authorization: "Bearer red-room",
+			wantStatus:    http.StatusForbidden,
+			wantErrors:    schema.Errors{Errors: []string{"the bearer token does not grant api access"}},
+		},
+		{
+			name:          "token with scope",
+			url:           "/v1/providers/black/lodge/versions",
+			authorization: "Bearer owl-cave",
+			wantStatus:    http.StatusOK,
+		},
+		{
+			name:       "unprotected scope",
+			url:        "/proxy/black/lodge/1.0.0/terraform-provider-lodge_1.0.0_linux_amd64.zip",
+			wantStatus: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", tt.url, nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status code: got = %v, want %v", w.Code, tt.wantStatus)
+			}
+			if tt.wantErrors.Errors == nil {
+				return
+			}
+
+			errors := schema.Errors{}
+			err = json.Unmarshal(w.Body.Bytes(), &errors)
+			if err != nil {
+				t.Fatalf("error umarshalling: %v", err)
+			}
+			if !reflect.DeepEqual(errors, tt.wantErrors) {
+				t.Errorf("errors: got = %v, want %v", errors, tt.wantErrors)
+			}
+		})
+	}
+}
+
+// formPart is a part of a multipart form. It is sent as file if it has a file name.
+type formPart struct {
+	field    string
+	filename string
+	content  []byte
+}
+
+// releaseParts returns the parts uploading a release of black/lodge 1.0.0. The release is signed by key unless it is
+// nil.
+func releaseParts(key *testsupport.SigningKey, archive string) []formPart {
+	archiveName := "terraform-provider-lodge_1.0.0_linux_amd64.zip"
+	hash := sha256.Sum256(testsupport.ZipArchive("315 coffee provider"))
+	shaSums := []byte(fmt.Sprintf("%s  %s\n", hex.EncodeToString(hash[:]), archiveName))
+
+	parts := []formPart{
+		{field: "archives", filename: archiveName, content: testsupport.ZipArchive(archive)},
+		{field: "shasums", filename: "SHA256SUMS", content: shaSums},
+	}
+	if key != nil {
+		parts = append(parts,
+			formPart{field: "signature", filename: "SHA256SUMS.sig", content: key.Sign(shaSums)},
+			formPart{field: "public_key", filename: "key.asc", content: []byte(key.ArmoredPublicKey())},
+		)
+	}
+	return parts
+}
+
+// multipartForm creates a multipart form of parts.
+func multipartForm(t *testing.T, parts []formPart) (*bytes.Buffer, string) {
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	for _, formPart := range parts {
+		var part io.Writer
+		var err error
+		if formPart.filename != "" {
+			part, err = writer.CreateFormFile(formPart.field, formPart.filename)
+		} else {
+			part, err = writer.CreateFormField(formPart.field)
+		}
+		if err != nil {
+			t.Fatalf("error creating form: %v", err)
+		}
+		_, _ = part.Write(formPart.content)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("error creating form: %v", err)
+	}
+	return body, writer.FormDataContentType()
+}
+
+// publishForm creates the form uploading a release of black/lodge 1.0.0. The release is signed by key unless it is nil.
+func publishForm(t *testing.T, key *testsupport.SigningKey, archive string) (*bytes.Buffer, string) {
+	return multipartForm(t, releaseParts(key, archive))
+}
+
+func TestPublish(t *testing.T) {
+	logger.Logger, _ = zap.NewDevelopment()
+	logger.Sugar = logger.Logger.Sugar()
+
+	bucket := testsupport.NewMemoryBucket(nil)
+	providerData, err := providerdata.NewS3Backend(bucket, "twin.peaks")
+	if err != nil {
+		t.Fatalf("error creating providerData: %v", err)
+	}
+	registryCache := cache.NewCache(providerData, bucket)
+
+	tokens, err := auth.ParseTokens("owl-cave\nred-room publish\n")
+	if err != nil {
+		t.Fatalf("error parsing tokens: %v", err)
+	}
+	r := SetupRouter(registryCache, WithAuthentication(tokens), WithPublishing(publish.NewPublisher(bucket, registryCache)))
+	key := testsupport.NewSigningKey("Dale Cooper")
+
+	tests := []struct {
+		name          string
+		authorization string
+		archive       string
+		wantStatus    int
+	}{
+		{
+			name:          "token without publish scope",
+			authorization: "Bearer owl-cave",
+			archive:       "315 coffee provider",
+			wantStatus:    http.StatusForbidden,
+		},
+		{
+			name:          "archive not matching the shasum file",
+			authorization: "Bearer red-room",
+			archive:       "bob",
+			wantStatus:    http.StatusBadRequest,
+		},
+		{
+			name:          "publish release",
+			authorization: "Bearer red-room",
+			archive:       "315 coffee provider",
+			wantStatus:    http.StatusCreated,
+		},
+		{
+			name:          "publish existing release",
+			authorization: "Bearer red-room",
+			archive:       "315 coffee provider",
+			wantStatus:    http.StatusConflict,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, contentType := publishForm(t, &key, tt.archive)
+			req, _ := http.NewRequest("POST", "/publish/providers/black/lodge/1.0.0", body)
+			req.Header.Set("Content-Type", contentType)
+			req.Header.Set("Authorization", tt.authorization)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status code: got = %v, want %v, body %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+		})
+	}
+
+	req, _ := http.NewRequest("GET", "/v1/providers/black/lodge/1.0.0/download/linux/amd64", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	downloadData := schema.DownloadData{}
+	if err := json.Unmarshal(w.Body.Bytes(), &downloadData); err != nil {
+		t.Fatalf("error unmarshalling download data: %v", err)
+	}
+	if len(downloadData.SigningKeys.GpgPublicKeys) != 1 || downloadData.SigningKeys.GpgPublicKeys[0].KeyID != key.KeyID() {
+		t.Errorf("download data of published release: got = %+v", downloadData)
+	}
+}
+
+func TestPublishFormFields(t *testing.T) {
+	logger.Logger, _ = zap.NewDevelopment()
+	logger.Sugar = logger.Logger.Sugar()
+
+	tokens, err := auth.ParseTokens("red-room publish\n")
+	if err != nil {
+		t.Fatalf("error parsing tokens: %v", err)
+	}
+	key := testsupport.NewSigningKey("Dale Cooper")
+	withPlainValues := func(parts []formPart) []formPart {
+		for i := range parts {
+			if parts[i].field != "archives" {
+				parts[i].filename = ""
+			}
+		}
+		return parts
+	}
+
+	tests := []struct {
+		name       string
+		parts      []formPart
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "fields as plain values",
+			parts:      withPlainValues(releaseParts(&key, "315 coffee provider")),
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "field uploaded twice",
+			parts:      append(releaseParts(&key, "315 coffee provider"), formPart{field: "shasums", content: []byte("other shasums")}),
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"errors":["the field shasums is uploaded twice"]}`,
+		},
+		{
+			name:       "unknown field",
+			parts:      append(releaseParts(&key, "315 coffee provider"), formPart{field: "shasum", content: []byte("typo")}),
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"errors":["unknown field shasum"]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bucket := testsupport.NewMemoryBucket(nil)
+			providerData, err := providerdata.NewS3Backend(bucket, "twin.peaks")
+			if err != nil {
+				t.Fatalf("error creating providerData: %v", err)
+			}
+			registryCache := cache.NewCache(providerData, bucket)
+			r := SetupRouter(registryCache, WithAuthentication(tokens), WithPublishing(publish.NewPublisher(bucket, registryCache)))
+
+			body, contentType := multipartForm(t, tt.parts)
+			req, _ := http.NewRequest("POST", "/publish/providers/black/lodge/1.0.0", body)
+			req.Header.Set("Content-Type", contentType)
+			req.Header.Set("Authorization", "Bearer red-room")
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status code: got = %v, want %v, body %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("body: got = %v, want %v", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestPublishRejectsLargeUploads(t *testing.T) {
+	logger.Logger, _ = zap.NewDevelopment()
+	logger.Sugar = logger.Logger.Sugar()
+
+	bucket := testsupport.NewMemoryBucket(nil)
+	providerData, err := providerdata.NewS3Backend(bucket, "twin.peaks")
+	if err != nil {
+		t.Fatalf("error creating providerData: %v", err)
+	}
+	registryCache := cache.NewCache(providerData, bucket)
+
+	tokens, err := auth.ParseTokens("red-room publish\n")
+	if err != nil {
+		t.Fatalf("error parsing tokens: %v", err)
+	}
+	key := testsupport.NewSigningKey("Dale Cooper")
+	body, contentType := publishForm(t, &key, "315 coffee provider")
+	r := SetupRouter(registryCache,
+		WithAuthentication(tokens),
+		WithPublishing(publish.NewPublisher(bucket, registryCache)),
+		WithMaxUploadSize(int64(body.Len()-1)),
+	)
+
+	req, _ := http.NewRequest("POST", "/publish/providers/black/lodge/1.0.0", body)
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer red-room")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status code: got = %v, want %v, body %s", w.Code, http.StatusRequestEntityTooLarge, w.Body.String())
+	}
+	if objects := bucket.Objects(); len(objects) != 0 {
+		t.Errorf("publishing a large upload wrote %v", objects)
+	}
+}
+
+func TestPublishSignsUnsignedReleases(t *testing.T) {
+	logger.Logger, _ = zap.NewDevelopment()
+	logger.Sugar = logger.Logger.Sugar()
+
+	bucket := testsupport.NewMemoryBucket(nil)
+	providerData, err := providerdata.NewS3Backend(bucket, "twin.peaks")
+	if err != nil {
+		t.Fatalf("error creating providerData: %v", err)
+	}
+	registryCache := cache.NewCache(providerData, bucket)
+
+	tokens, err := auth.ParseTokens("red-room publish\n")
+	if err != nil {
+		t.Fatalf("error parsing tokens: %v", err)
+	}
+	registryKey := testsupport.NewSigningKey("Gordon Cole")
+	signer, err := pgp.ReadSigner(registryKey.ArmoredPrivateKey(""), nil)
+	if err != nil {
+		t.Fatalf("error reading signing key: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		publisher  publish.Publisher
+		wantStatus int
+	}{
+		{
+			name:       "without signing key",
+			publisher:  publish.NewPublisher(bucket, registryCache),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "with signing key",
+			publisher:  publish.NewPublisher(bucket, registryCache, publish.WithSigner(signer)),
+			wantStatus: http.StatusCreated,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := SetupRouter(registryCache, WithAuthentication(tokens), WithPublishing(tt.publisher))
+
+			body, contentType := publishForm(t, nil, "315 coffee provider")
+			req, _ := http.NewRequest("POST", "/publish/providers/black/lodge/1.0.0", body)
+			req.Header.Set("Content-Type", contentType)
+			req.Header.Set("Authorization", "Bearer red-room")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status code: got = %v, want %v, body %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+		})
+	}
+
+	r := SetupRouter(registryCache)
+	req, _ := http.NewRequest("GET", "/v1/providers/black/lodge/1.0.0/download/linux/amd64", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	downloadData := schema.DownloadData{}
+	if err := json.Unmarshal(w.Body.Bytes(), &downloadData); err != nil {
+		t.Fatalf("error unmarshalling download data: %v", err)
+	}
+	if len(downloadData.SigningKeys.GpgPublicKeys) != 1 || downloadData.SigningKeys.GpgPublicKeys[0].KeyID != registryKey.KeyID() {
+		t.Errorf("download data of signed release: got = %+v", downloadData)
+	}
+}
+
+func TestErrorResponses(t *testing.T) {
+	logger.Logger, _ = zap.NewDevelopment()
+	logger.Sugar = logger.Logger.Sugar()
+
+	testBucketWithObjects := testsupport.NewTestBucketWithObjects([]string{
+		"black/lodge/",
+		"black/lodge/1.0.1/",
+		"black/lodge/1.0.1/keyfile",
+		"black/lodge/1.0.1/terraform-provider-lodge_1.0.1_linux_amd64.zip",
+	}, nil)
+	providerData, err := providerdata.NewS3Backend(testBucketWithObjects, "twin.peaks")
+	if err != nil {
+		t.Fatalf("error creating providerData: %v", err)
+	}
+	registryCache := cache.NewCache(providerData, testBucketWithObjects)
+	err = registryCache.Refresh()
+	if err != nil {
+		t.Fatalf("error refreshing cache: %v", err)
+	}
+
+	upstreamBucket := testsupport.NewTestBucket([]string{"UPSTREAM_ERROR_PROVIDER/lodge/1.0.0/terraform-provider-lodge_1.0.0_linux_amd64.zip"})
+	upstreamCache := cache.NewCache(testsupport.NewTestProviderData(), upstreamBucket)
+	if err := upstreamCache.Refresh(); err != nil {
+		t.Fatalf("error refreshing cache: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		cache      cache.CacheableProviderData
+		url        string
+		wantStatus int
+		wantErrors schema.Errors
+	}{
+		{
+			name:       "unknown namespace",
+			cache:      registryCache,
+			url:        "/v1/providers/twin/peaks/versions",
+			wantStatus: http.StatusNotFound,
+			wantErrors: schema.Errors{Errors: []string{"namespace twin does not exist"}},
+		},
+		{
+			name:       "unknown type",
+			cache:      registryCache,
+			url:        "/v1/providers/black/owl/versions",
+			wantStatus: http.StatusNotFound,
+			wantErrors: schema.Errors{Errors: []string{"provider black/owl does not exist"}},
+		},
+		{
+			name:       "download data of missing version",
+			cache:      registryCache,
+			url:        "/v1/providers/black/lodge/1.0.2/download/linux/amd64",
+			wantStatus: http.StatusNotFound,
+			wantErrors: schema.Errors{Errors: []string{"version 1.0.2 of provider black/lodge does not exist"}},
+		},
+		{
+			name:       "download data of invalid version",
+			cache:      registryCache,
+			url:        "/v1/providers/black/lodge/latest/download/linux/amd64",
+			wantStatus: http.StatusNotFound,
+			wantErrors: schema.Errors{Errors: []string{"version latest of provider black/lodge does not exist"}},
+		},
+		{
+			name:       "download data with failing storage",
+			cache:      upstreamCache,
+			url:        "/v1/providers/UPSTREAM_ERROR_PROVIDER/lodge/1.0.0/download/linux/amd64",
+			wantStatus: http.StatusBadGateway,
+			wantErrors: schema.Errors{Errors: []string{"unable to get shasum"}},
+		},
+		{
+			name:       "proxy file which is not downloadable",
+			cache:      registryCache,
+			url:        "/proxy/black/lodge/1.0.1/keyfile",
+			wantStatus: http.StatusNotFound,
+			wantErrors: schema.Errors{Errors: []string{"keyfile is not a downloadable file of black/lodge/1.0.1"}},
+		},
+		{
+			name:       "proxy missing file",
+			cache:      registryCache,
+			url:        "/proxy/black/lodge/1.0.1/shasum.sig",
+			wantStatus: http.StatusNotFound,
+			wantErrors: schema.Errors{Errors: []string{"object black/lodge/1.0.1/shasum.sig does not exist"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := SetupRouter(tt.cache)
+
+			req, _ := http.NewRequest("GET", tt.url, nil)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status code: got = %v, want %v", w.Code, tt.wantStatus)
+			}
+
+			errors := schema.Errors{}
+			err = json.Unmarshal(w.Body.Bytes(), &errors)
+			if err != nil {
+				t.Fatalf("error umarshalling: %v", err)
+			}
+			if !reflect.DeepEqual(errors, tt.wantErrors) {
+				t.Errorf("errors: got = %v, want %v", errors, tt.wantErrors)
+			}
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/mdreem/s3_terraform_registry/logger"
+	"github.com/mdreem/s3_terraform_registry/moduledata"
+	"github.com/mdreem/s3_terraform_registry/providerdata"
+	"github.com/mdreem/s3_terraform_registry/registryerror"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// ObjectEvent is a change of an object in the bucket.
+type ObjectEvent struct {
+	Bucket string
+	Key    string
+}
+
+// Provider identifies a provider affected by object events.
+type Provider struct {
+	Namespace string
+	Type      string
+}
+
+func (provider Provider) String() string {
+	return fmt.Sprintf("%s/%s", provider.Namespace, provider.Type)
+}
+
+// Module identifies a module affected by object events.
+type Module struct {
+	Namespace string
+	Name      string
+	System    string
+}
+
+func (module Module) String() string {
+	return fmt.Sprintf("%s/%s/%s", module.Namespace, module.Name, module.System)
+}
+
+// Changes are the providers and modules affected by object events.
+type Changes struct {
+	Providers []Provider
+	Modules   []Module
+}
+
+// ProviderNames returns the names of the changed providers.
+func (changes Changes) ProviderNames() []string {
+	names := make([]string, 0, len(changes.Providers))
+	for _, provider := range changes.Providers {
+		names = append(names, provider.String())
+	}
+	return names
+}
+
+// ModuleNames returns the names of the changed modules.
+func (changes Changes) ModuleNames() []string {
+	names := make([]string, 0, len(changes.Modules))
+	for _, module := range changes.Modules {
+		names = append(names, module.String())
+	}
+	return names
+}
+
+// Refresher is implemented by caches which can refresh single providers and modules.
+type Refresher interface {
+	RefreshProvider(namespace string, providerType string) error
+	RefreshModule(namespace string, name string, system string) error
+}
+
+// IndexRefresher can refresh single providers and modules as well as the whole index.
+type IndexRefresher interface {
+	Refresher
+	Refresh() error
+}
+
+// notification contains the fields of all supported payloads: S3 event notifications, S3 event notifications
+// wrapped in SNS messages and S3 events delivered by EventBridge.
+type notification struct {
+	Records []s3Record `json:"Records"`
+
+	Type         string `json:"Type"`
+	Message      string `json:"Message"`
+	SubscribeURL string `json:"SubscribeURL"`
+
+	Source     string `json:"source"`
+	DetailType string `json:"detail-type"`
+	Detail     struct {
+		Bucket struct {
+			Name string `json:"name"`
+		} `json:"bucket"`
+		Object struct {
+			Key string `json:"key"`
+		} `json:"object"`
+	} `json:"detail"`
+
+	Event string `json:"Event"`
+}
+
+type s3Record struct {
+	EventSource string `json:"eventSource"`
+	EventName   string `json:"eventName"`
+	S3          struct {
+		Bucket struct {
+			Name string `json:"name"`
+		} `json:"bucket"`
+		Object struct {
+			Key string `json:"key"`
+		} `json:"object"`
+	} `json:"s3"`
+}
+
+// Parse extracts the events about created and removed objects from an S3 event notification, which may be sent
+// directly, wrapped into an SNS message or delivered by EventBridge. Other events are ignored.
+func Parse(payload []byte) ([]ObjectEvent, error) {
+	message := notification{}
+	if err := json.Unmarshal(payload, &message); err != nil {
+		return nil, registryerror.BadRequest(err, "unable to parse event notification")
+	}
+
+	switch {
+	case message.Type == "Notification":
+		return Parse([]byte(message.Message))
+	case message.Type == "SubscriptionConfirmation":
+		logger.Sugar.Warnw("received SNS subscription confirmation, confirm it by visiting the subscribe URL", "subscribeURL", message.SubscribeURL)
+		return nil, nil
+	case message.Event == "s3:TestEvent":
+		logger.Sugar.Infow("received S3 test event")
+		return nil, nil
+	case message.Source == "aws.s3":
+		if message.DetailType != "Object Created" && message.DetailType != "Object Deleted" {
+			return nil, nil
+		}
+		return []ObjectEvent{{Bucket: message.Detail.Bucket.Name, Key: message.Detail.Object.Key}}, nil
+	case message.Records != nil:
+		return parseRecords(message.Records)
+	default:
+		return nil, registryerror.BadRequest(nil, "unsupported event notification")
+	}
+}
+
+func parseRecords(records []s3Record) ([]ObjectEvent, error) {
+	objectEvents := make([]ObjectEvent, 0)
+	for _, record := range records {
+		if !strings.HasPrefix(record.EventName, "ObjectCreated:") && !strings.HasPrefix(record.EventName, "ObjectRemoved:") {
+			continue
+		}
+
+		// keys in S3 event notifications are URL encoded
+		key, err := url.QueryUnescape(record.S3.Object.Key)
+		if err != nil {
+			return nil, registryerror.BadRequest(err, "unable to decode key %s", record.S3.Object.Key)
+		}
+		objectEvents = append(objectEvents, ObjectEvent{Bucket: record.S3.Bucket.Name, Key: key})
+	}
+	return objectEvents, nil
+}
+
+// parseForBucket parses payload and checks that all events belong to the bucket bucketName.
+func parseForBucket(payload []byte, bucketName string) ([]ObjectEvent, error) {
+	objectEvents, err := Parse(payload)
+	if err != nil {
+		return nil, err
+	}
+	for _, objectEvent := range objectEvents {
+		if objectEvent.Bucket != bucketName {
+			return nil, registryerror.BadRequest(nil, "event notification of bucket %q, expected %q", objectEvent.Bucket, bucketName)
+		}
+	}
+	return objectEvents, nil
+}
+
+// AffectedProviders returns the providers whose objects changed, ignoring objects outside of <namespace>/<type>/. Keys
+// of a namespace or the bucket root affect no single provider, changes of them refresh the whole index.
+func AffectedProviders(objectEvents []ObjectEvent) []Provider {
+	seen := make(map[Provider]bool)
+	providers := make([]Provider, 0)
+	for _, objectEvent := range objectEvents {
+		if strings.HasPrefix(objectEvent.Key, moduledata.Prefix) {
+			continue
+		}
+
+		parts := strings.SplitN(objectEvent.Key, "/", 3)
+		if len(parts) < 3 || parts[0] == "" || parts[1] == "" {
+			continue
+		}
+		if refreshesIndex(objectEvent.Key) {
+			continue
+		}
+
+		provider := Provider{Namespace: parts[0], Type: parts[1]}
+		if !seen[provider] {
+			seen[provider] = true
+			providers = append(providers, provider)
+		}
+	}
+
+	sort.Slice(providers, func(i, j int) bool {
+		return providers[i].String() < providers[j].String()
+	})
+	return providers
+}
+
+// AffectedModules returns the modules whose objects changed, ignoring objects outside of
+// modules/<namespace>/<name>/<system>/.
+func AffectedModules(objectEvents []ObjectEvent) []Module {
+	seen := make(map[Module]bool)
+	modules := make([]Module, 0)
+	for _, objectEvent := range objectEvents {
+		if !strings.HasPrefix(objectEvent.Key, moduledata.Prefix) {
+			continue
+		}
+
+		parts := strings.SplitN(strings.TrimPrefix(objectEvent.Key, moduledata.Prefix), "/", 4)
+		if len(parts) < 4 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			continue
+		}
+
+		module := Module{Namespace: parts[0], Name: parts[1], System: parts[2]}
+		if !seen[module] {
+			seen[module] = true
+			modules = append(modules, module)
+		}
+	}
+
+	sort.Slice(modules, func(i, j int) bool {
+		return modules[i].String() < modules[j].String()
+	})
+	return modules
+}
+
+// Handle refreshes all providers and modules affected by the event notification in payload and returns them.
+// Notifications containing events of other buckets than bucketName are rejected.
+func Handle(refresher IndexRefresher, payload []byte, bucketName string) (Changes, error) {
+	objectEvents, err := parseForBucket(payload, bucketName)
+	if err != nil {
+		return Changes{}, err
+	}
+	return refreshChanges(refresher, objectEvents)
+}
+
+// refreshesIndex returns whether key is a key inherited by a whole namespace or the registry, which may affect every
+// provider below it.
+func refreshesIndex(key string) bool {
+	return providerdata.IsInheritedKeyFile(key) && strings.Count(key, "/") < 3
+}
+
+// refreshChanges refreshes the providers and modules affected by objectEvents and returns them. Changes of keys
+// inherited by whole namespaces or the registry refresh the whole index instead.
+func refreshChanges(refresher IndexRefresher, objectEvents []ObjectEvent) (Changes, error) {
+	changes := Changes{
+		Providers: AffectedProviders(objectEvents),
+		Modules:   AffectedModules(objectEvents),
+	}
+
+	for _, objectEvent := range objectEvents {
+		if refreshesIndex(objectEvent.Key) {
+			logger.Sugar.Infow("refreshing index after change of inherited keys", "key", objectEvent.Key)
+			if err := refresher.Refresh(); err != nil {
+				return Changes{}, fmt.Errorf("unable to refresh the index: %w", err)
+			}
+			return changes, nil
+		}
+	}
+
+	if err := refresh(refresher, changes); err != nil {
+		return Changes{}, err
+	}
+	return changes, nil
+}
+
+func refresh(refresher Refresher, changes Changes) error {
+	for _, provider := range changes.Providers {
+		logger.Sugar.Infow("refreshing provider after object event", "namespace", provider.Namespace, "type", provider.Type)
+		if err := refresher.RefreshProvider(provider.Namespace, provider.Type); err != nil {
+			return fmt.Errorf("unable to refresh %s: %w", provider, err)
+		}
+	}
+	for _, module := range changes.Modules {
+		logger.Sugar.Infow("refreshing module after object event", "namespace", module.Namespace, "name", module.Name, "system", module.System)
+		if err := refresher.RefreshModule(module.Namespace, module.Name, module.System); err != nil {
+			return fmt.Errorf("unable to refresh %s: %w", module, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package events
+
+import (
+	"errors"
+	"github.com/mdreem/s3_terraform_registry/registryerror"
+	"reflect"
+	"testing"
+)
+
+const s3Notification = `{"Records":[
+	{"eventSource":"aws:s3","eventName":"ObjectCreated:Put","s3":{"bucket":{"name":"registry"},"object":{"key":"black/lodge/1.0.0/terraform-provider-lodge_1.0.0_linux_amd64.zip"}}},
+	{"eventSource":"aws:s3","eventName":"ObjectRemoved:Delete","s3":{"bucket":{"name":"registry"},"object":{"key":"white/lodge/1.0.0/some+file%3A1"}}},
+	{"eventSource":"aws:s3","eventName":"ObjectRestore:Completed","s3":{"bucket":{"name":"registry"},"object":{"key":"red/room/1.0.0/shasum"}}}
+]}`
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    []ObjectEvent
+		wantErr bool
+	}{
+		{
+			name:    "S3 notification",
+			payload: s3Notification,
+			want: []ObjectEvent{
+				{Bucket: "registry", Key: "black/lodge/1.0.0/terraform-provider-lodge_1.0.0_linux_amd64.zip"},
+				{Bucket: "registry", Key: "white/lodge/1.0.0/some file:1"},
+			},
+		},
+		{
+			name:    "SNS notification",
+			payload: `{"Type":"Notification","Message":"{\"Records\":[{\"eventName\":\"ObjectCreated:Put\",\"s3\":{\"bucket\":{\"name\":\"registry\"},\"object\":{\"key\":\"black/lodge/shasum\"}}}]}"}`,
+			want:    []ObjectEvent{{Bucket: "registry", Key: "black/lodge/shasum"}},
+		},
+		{
+			name:    "SNS subscription confirmation",
+			payload: `{"Type":"SubscriptionConfirmation","SubscribeURL":"https://sns.example.com/confirm"}`,
+			want:    nil,
+		},
+		{
+			name:    "EventBridge object created",
+			payload: `{"source":"aws.s3","detail-type":"Object Created","detail":{"bucket":{"name":"registry"},"object":{"key":"black/lodge/1.0.0/shasum"}}}`,
+			want:    []ObjectEvent{{Bucket: "registry", Key: "black/lodge/1.0.0/shasum"}},
+		},
+		{
+			name:    "EventBridge other event",
+			payload: `{"source":"aws.s3","detail-type":"Object Restore Completed","detail":{"bucket":{"name":"registry"},"object":{"key":"black/lodge/1.0.0/shasum"}}}`,
+			want:    nil,
+		},
+		{
+			name:    "S3 test event",
+			payload: `{"Service":"Amazon S3","Event":"s3:TestEvent","Bucket":"registry"}`,
+			want:    nil,
+		},
+		{
+			name:    "unsupported payload",
+			payload: `{"some":"thing"}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid JSON",
+			payload: `{`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.payload))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAffectedProviders(t *testing.T) {
+	objectEvents := []ObjectEvent{
+		{Key: "white/lodge/1.0.0/shasum"},
+		{Key: "black/lodge/1.0.0/shasum"},
+		{Key: "black/lodge/1.0.1/shasum"},
+		{Key: "black/keyfile"},
+		{Key: "black/keys/cooper.asc"},
+		{Key: "keys/cooper.asc"},
+		{Key: "owl/cave/keys/cooper.asc"},
+		{Key: "README.md"},
+		{Key: "modules/black/lodge/aws/1.0.0/lodge.tar.gz"},
+		{Key: "modules/black/lodge/aws"},
+	}
+
+	want := []Provider{{Namespace: "black", Type: "lodge"}, {Namespace: "owl", Type: "cave"}, {Namespace: "white", Type: "lodge"}}
+	if got := AffectedProviders(objectEvents); !reflect.DeepEqual(got, want) {
+		t.Errorf("AffectedProviders() got = %v, want %v", got, want)
+	}
+
+	wantModules := []Module{{Namespace: "black", Name: "lodge", System: "aws"}}
+	if got := AffectedModules(objectEvents); !reflect.DeepEqual(got, wantModules) {
+		t.Errorf("AffectedModules() got = %v, want %v", got, wantModules)
+	}
+}
+
+// recordingRefresher records the refreshed providers and modules, counts the refreshes of the whole index and fails
+// for the namespace ERROR_PROVIDER.
+type recordingRefresher struct {
+	refreshed        []Provider
+	refreshedModules []Module
+	refreshes        int
+}
+
+func (refresher *recordingRefresher) Refresh() error {
+	refresher.refreshes++
+	return nil
+}
+
+func (refresher *recordingRefresher) RefreshProvider(namespace string, providerType string) error {
+	if namespace == "ERROR_PROVIDER" {
+		return errors.New("unable to list objects")
+	}
+	refresher.refreshed = append(refresher.refreshed, Provider{Namespace: namespace, Type: providerType})
+	return nil
+}
+
+func (refresher *recordingRefresher) RefreshModule(namespace string, name string, system string) error {
+	refresher.refreshedModules = append(refresher.refreshedModules, Module{Namespace: namespace, Name: name, System: system})
+	return nil
+}
+
+func TestHandle(t *testing.T) {
+	refresher := &recordingRefresher{}
+
+	changes, err := Handle(refresher, []byte(s3Notification), "registry")
+	if err != nil {
+		t.Fatalf("Handle() error = %v", err)
+	}
+
+	want := []Provider{{Namespace: "black", Type: "lodge"}, {Namespace: "white", Type: "lodge"}}
+	if !reflect.DeepEqual(changes.Providers, want) {
+		t.Errorf("Handle() got = %v, want %v", changes.Providers, want)
+	}
+	if !reflect.DeepEqual(refresher.refreshed, want) {
+		t.Errorf("Handle() refreshed = %v, want %v", refresher.refreshed, want)
+	}
+}
+
+func TestHandle_OtherBucket(t *testing.T) {
+	refresher := &recordingRefresher{}
+
+	_, err := Handle(refresher, []byte(s3Notification), "other")
+	if !errors.Is(err, registryerror.ErrBadRequest) {
+		t.Errorf("Handle() error = %v, want %v", err, registryerror.ErrBadRequest)
+	}
+	if len(refresher.refreshed) != 0 {
+		t.Errorf("Handle() refreshed = %v, want nothing", refresher.refreshed)
+	}
+}
+
+func TestHandle_InheritedKeys(t *testing.T) {
+	tests := []struct {
+		name          string
+		key           string
+		wantRefreshes int
+		wantRefreshed []Provider
+	}{
+		{
+			name:          "keys of the registry",
+			key:           "keys/cooper.asc",
+			wantRefreshes: 1,
+		},
+		{
+			name:          "keys of a namespace",
+			key:           "black/keys/cooper.asc",
+			wantRefreshes: 1,
+		},
+		{
+			name:          "keys of a provider",
+			key:           "black/lodge/keys/cooper.asc",
+			wantRefreshed: []Provider{{Namespace: "black", Type: "lodge"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			refresher := &recordingRefresher{}
+			payload := `{"Records":[{"eventName":"ObjectCreated:Put","s3":{"bucket":{"name":"registry"},"object":{"key":"` + tt.key + `"}}}]}`
+
+			if _, err := Handle(refresher, []byte(payload), "registry"); err != nil {
+				t.Fatalf("Handle() error = %v", err)
+			}
+			if refresher.refreshes != tt.wantRefreshes {
+				t.Errorf("Handle() refreshed the index %d times, want %d", refresher.refreshes, tt.wantRefreshes)
+			}
+			if !reflect.DeepEqual(refresher.refreshed, tt.wantRefreshed) {
+				t.Errorf("Handle() refreshed = %v, want %v", refresher.refreshed, tt.wantRefreshed)
+			}
+		})
+	}
+}
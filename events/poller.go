@@ -0,0 +1,88 @@
+package events
+
+import (
+	"context"
+	"github.com/mdreem/s3_terraform_registry/logger"
+	"github.com/mdreem/s3_terraform_registry/s3"
+	"sort"
+	"time"
+)
+
+// Poller detects changes of a storage which sends no event notifications by comparing the fingerprints of its
+// objects, and refreshes the affected providers and modules like event notifications do.
+type Poller struct {
+	storage      s3.ListFingerprints
+	refresher    IndexRefresher
+	fingerprints map[string]string
+}
+
+func NewPoller(storage s3.ListFingerprints, refresher IndexRefresher) *Poller {
+	return &Poller{storage: storage, refresher: refresher}
+}
+
+// Run polls every interval until ctx is done.
+func (poller *Poller) Run(ctx context.Context, interval time.Duration) {
+	logger.Sugar.Infow("polling for changes", "interval", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if _, err := poller.PollOnce(); err != nil {
+			logger.Sugar.Errorw("unable to pick up changes", "error", err)
+		}
+	}
+}
+
+// PollOnce lists the fingerprints of the storage and refreshes what changed since the previous poll. The first poll
+// only records the fingerprints. Changes of keys inherited by whole namespaces or the registry refresh the whole
+// index. If refreshing fails, the changes are picked up again by the next poll.
+func (poller *Poller) PollOnce() (Changes, error) {
+	fingerprints, err := poller.storage.ListFingerprints()
+	if err != nil {
+		return Changes{}, err
+	}
+	if poller.fingerprints == nil {
+		poller.fingerprints = fingerprints
+		return Changes{}, nil
+	}
+
+	changedKeys := changedKeys(poller.fingerprints, fingerprints)
+	if len(changedKeys) == 0 {
+		return Changes{}, nil
+	}
+
+	objectEvents := make([]ObjectEvent, 0, len(changedKeys))
+	for _, key := range changedKeys {
+		objectEvents = append(objectEvents, ObjectEvent{Key: key})
+	}
+	changes, err := refreshChanges(poller.refresher, objectEvents)
+	if err != nil {
+		return Changes{}, err
+	}
+
+	poller.fingerprints = fingerprints
+	return changes, nil
+}
+
+// changedKeys returns the sorted keys which were added, removed or whose fingerprint changed.
+func changedKeys(previous map[string]string, current map[string]string) []string {
+	keys := make([]string, 0)
+	for key, fingerprint := range current {
+		if previousFingerprint, ok := previous[key]; !ok || previousFingerprint != fingerprint {
+			keys = append(keys, key)
+		}
+	}
+	for key := range previous {
+		if _, ok := current[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
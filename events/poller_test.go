@@ -0,0 +1,103 @@
+package events
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// fakeStorage returns the fingerprints set by the test.
+type fakeStorage struct {
+	fingerprints map[string]string
+}
+
+func (storage *fakeStorage) ListFingerprints() (map[string]string, error) {
+	if storage.fingerprints == nil {
+		return nil, errors.New("unable to list objects")
+	}
+	return storage.fingerprints, nil
+}
+
+func TestPoller_PollOnce(t *testing.T) {
+	storage := &fakeStorage{fingerprints: map[string]string{
+		"black/lodge/1.0.0/shasum":                   "1",
+		"white/lodge/1.0.0/shasum":                   "1",
+		"red/room/1.0.0/shasum":                      "1",
+		"modules/black/lodge/aws/1.0.0/lodge.tar.gz": "1",
+	}}
+	refresher := &recordingRefresher{}
+	poller := NewPoller(storage, refresher)
+
+	changes, err := poller.PollOnce()
+	if err != nil {
+		t.Fatalf("PollOnce() error = %v", err)
+	}
+	if len(changes.Providers) != 0 || len(refresher.refreshed) != 0 {
+		t.Errorf("PollOnce() refreshed on the first poll: %v", changes)
+	}
+
+	storage.fingerprints = map[string]string{
+		"black/lodge/1.0.0/shasum":                   "2",
+		"white/lodge/1.0.0/shasum":                   "1",
+		"owl/cave/1.0.0/shasum":                      "1",
+		"modules/black/lodge/aws/1.0.0/lodge.tar.gz": "1",
+	}
+	changes, err = poller.PollOnce()
+	if err != nil {
+		t.Fatalf("PollOnce() error = %v", err)
+	}
+	want := []Provider{{Namespace: "black", Type: "lodge"}, {Namespace: "owl", Type: "cave"}, {Namespace: "red", Type: "room"}}
+	if !reflect.DeepEqual(changes.Providers, want) {
+		t.Errorf("PollOnce() got = %v, want %v", changes.Providers, want)
+	}
+	if !reflect.DeepEqual(refresher.refreshed, want) {
+		t.Errorf("PollOnce() refreshed = %v, want %v", refresher.refreshed, want)
+	}
+
+	refresher.refreshed = nil
+	if changes, err = poller.PollOnce(); err != nil || len(changes.Providers) != 0 || len(refresher.refreshed) != 0 {
+		t.Errorf("PollOnce() without changes got = %v, error = %v", changes, err)
+	}
+}
+
+func TestPoller_PollOnce_InheritedKeys(t *testing.T) {
+	storage := &fakeStorage{fingerprints: map[string]string{"black/lodge/1.0.0/shasum": "1"}}
+	refresher := &recordingRefresher{}
+	poller := NewPoller(storage, refresher)
+	if _, err := poller.PollOnce(); err != nil {
+		t.Fatalf("PollOnce() error = %v", err)
+	}
+
+	storage.fingerprints = map[string]string{"black/lodge/1.0.0/shasum": "1", "black/keys/cooper.asc": "1"}
+	if _, err := poller.PollOnce(); err != nil {
+		t.Fatalf("PollOnce() error = %v", err)
+	}
+	if refresher.refreshes != 1 || len(refresher.refreshed) != 0 {
+		t.Errorf("PollOnce() refreshes = %d, refreshed = %v, want a refresh of the index", refresher.refreshes, refresher.refreshed)
+	}
+}
+
+func TestPoller_PollOnce_RetriesFailedRefresh(t *testing.T) {
+	storage := &fakeStorage{fingerprints: map[string]string{}}
+	refresher := &recordingRefresher{}
+	poller := NewPoller(storage, refresher)
+	if _, err := poller.PollOnce(); err != nil {
+		t.Fatalf("PollOnce() error = %v", err)
+	}
+
+	storage.fingerprints = map[string]string{"ERROR_PROVIDER/lodge/1.0.0/shasum": "1"}
+	if _, err := poller.PollOnce(); err == nil {
+		t.Fatalf("PollOnce() succeeded although refreshing failed")
+	}
+
+	storage.fingerprints = nil
+	if _, err := poller.PollOnce(); err == nil {
+		t.Fatalf("PollOnce() succeeded although listing failed")
+	}
+
+	// the failed changes are not recorded, so they are refreshed again
+	storage.fingerprints = map[string]string{"ERROR_PROVIDER/lodge/1.0.0/shasum": "1"}
+	if _, err := poller.PollOnce(); err == nil {
+		t.Errorf("PollOnce() did not refresh the failed changes again")
+	}
+}
@@ -0,0 +1,100 @@
+package events
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/mdreem/s3_terraform_registry/logger"
+	"github.com/mdreem/s3_terraform_registry/s3"
+	"time"
+)
+
+const sqsWaitTimeSeconds = 20
+
+// retryDelay is the time waited after receiving messages failed before trying again.
+const retryDelay = 5 * time.Second
+
+// SQSWorker receives S3 event notifications from an SQS queue and refreshes the affected providers.
+type SQSWorker struct {
+	client     sqsiface.SQSAPI
+	queueURL   string
+	bucketName string
+	refresher  IndexRefresher
+}
+
+var CreateSQSClient = func(region string) sqsiface.SQSAPI {
+	return sqs.New(s3.CreateSession(region))
+}
+
+// NewSQSWorker creates a worker handling the event notifications of the bucket bucketName. Notifications of other
+// buckets are dropped.
+func NewSQSWorker(region string, queueURL string, bucketName string, refresher IndexRefresher) SQSWorker {
+	return SQSWorker{
+		client:     CreateSQSClient(region),
+		queueURL:   queueURL,
+		bucketName: bucketName,
+		refresher:  refresher,
+	}
+}
+
+// Run receives messages until ctx is done.
+func (worker SQSWorker) Run(ctx context.Context) {
+	logger.Sugar.Infow("receiving event notifications", "queueURL", worker.queueURL)
+	for {
+		if err := worker.ReceiveOnce(ctx); err != nil {
+			logger.Sugar.Errorw("unable to receive event notifications", "queueURL", worker.queueURL, "error", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(retryDelay):
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+	}
+}
+
+// ReceiveOnce long polls the queue once and handles the received messages. Messages are deleted when they have been
+// handled or cannot be parsed. Messages whose refresh failed are kept to be delivered again.
+func (worker SQSWorker) ReceiveOnce(ctx context.Context) error {
+	output, err := worker.client.ReceiveMessageWithContext(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:            aws.String(worker.queueURL),
+		MaxNumberOfMessages: aws.Int64(10),
+		WaitTimeSeconds:     aws.Int64(sqsWaitTimeSeconds),
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return err
+	}
+
+	for _, message := range output.Messages {
+		messageID := aws.StringValue(message.MessageId)
+		objectEvents, err := parseForBucket([]byte(aws.StringValue(message.Body)), worker.bucketName)
+		if err != nil {
+			logger.Sugar.Warnw("dropping invalid event notification", "messageId", messageID, "error", err)
+		} else {
+			changes, err := refreshChanges(worker.refresher, objectEvents)
+			if err != nil {
+				logger.Sugar.Errorw("unable to refresh changes, keeping message", "messageId", messageID, "error", err)
+				continue
+			}
+			logger.Sugar.Infow("handled event notification", "messageId", messageID, "providers", changes.ProviderNames(), "modules", changes.ModuleNames())
+		}
+
+		_, err = worker.client.DeleteMessageWithContext(ctx, &sqs.DeleteMessageInput{
+			QueueUrl:      aws.String(worker.queueURL),
+			ReceiptHandle: message.ReceiptHandle,
+		})
+		if err != nil {
+			logger.Sugar.Errorw("unable to delete message", "messageId", messageID, "error", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/mdreem/s3_terraform_registry/logger"
+	"go.uber.org/zap"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// testSQSClient returns the messages once and records which of them were deleted.
+type testSQSClient struct {
+	sqsiface.SQSAPI
+	messages []*sqs.Message
+	deleted  []string
+}
+
+func (client *testSQSClient) ReceiveMessageWithContext(_ aws.Context, _ *sqs.ReceiveMessageInput, _ ...request.Option) (*sqs.ReceiveMessageOutput, error) {
+	if client.messages == nil {
+		return nil, errors.New("no more messages")
+	}
+	messages := client.messages
+	client.messages = nil
+	return &sqs.ReceiveMessageOutput{Messages: messages}, nil
+}
+
+func (client *testSQSClient) DeleteMessageWithContext(_ aws.Context, input *sqs.DeleteMessageInput, _ ...request.Option) (*sqs.DeleteMessageOutput, error) {
+	client.deleted = append(client.deleted, aws.StringValue(input.ReceiptHandle))
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func message(receiptHandle string, body string) *sqs.Message {
+	return &sqs.Message{
+		MessageId:     aws.String(receiptHandle),
+		ReceiptHandle: aws.String(receiptHandle),
+		Body:          aws.String(body),
+	}
+}
+
+func TestSQSWorker_ReceiveOnce(t *testing.T) {
+	logger.Logger, _ = zap.NewDevelopment()
+	logger.Sugar = logger.Logger.Sugar()
+
+	client := &testSQSClient{messages: []*sqs.Message{
+		message("handled", s3Notification),
+		message("invalid", "{"),
+		message("failed", `{"Records":[{"eventName":"ObjectCreated:Put","s3":{"bucket":{"name":"registry"},"object":{"key":"ERROR_PROVIDER/lodge/shasum"}}}]}`),
+		message("other-bucket", `{"Records":[{"eventName":"ObjectCreated:Put","s3":{"bucket":{"name":"other"},"object":{"key":"owl/cave/shasum"}}}]}`),
+		message("namespace-keys", `{"Records":[{"eventName":"ObjectCreated:Put","s3":{"bucket":{"name":"registry"},"object":{"key":"black/keys/cooper.asc"}}}]}`),
+	}}
+	refresher := &recordingRefresher{}
+	worker := SQSWorker{client: client, queueURL: "queue", bucketName: "registry", refresher: refresher}
+
+	if err := worker.ReceiveOnce(context.Background()); err != nil {
+		t.Fatalf("ReceiveOnce() error = %v", err)
+	}
+	if err := worker.ReceiveOnce(context.Background()); err == nil {
+		t.Errorf("ReceiveOnce() expected error")
+	}
+
+	sort.Strings(client.deleted)
+	wantDeleted := []string{"handled", "invalid", "namespace-keys", "other-bucket"}
+	if !reflect.DeepEqual(client.deleted, wantDeleted) {
+		t.Errorf("ReceiveOnce() deleted = %v, want %v", client.deleted, wantDeleted)
+	}
+	if len(refresher.refreshed) != 2 {
+		t.Errorf("ReceiveOnce() refreshed = %v, want 2 providers", refresher.refreshed)
+	}
+	if refresher.refreshes != 1 {
+		t.Errorf("ReceiveOnce() refreshed the index %d times, want once", refresher.refreshes)
+	}
+}
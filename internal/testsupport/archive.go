@@ -0,0 +1,26 @@
+//go:build testing
+
+package testsupport
+
+import (
+	"archive/zip"
+	"bytes"
+)
+
+// ZipArchive returns a zip-file containing the provider binary terraform-provider with content, as archives of
+// providers have to be valid zip-files.
+func ZipArchive(content string) []byte {
+	var archive bytes.Buffer
+	writer := zip.NewWriter(&archive)
+	file, err := writer.Create("terraform-provider")
+	if err != nil {
+		panic(err)
+	}
+	if _, err := file.Write([]byte(content)); err != nil {
+		panic(err)
+	}
+	if err := writer.Close(); err != nil {
+		panic(err)
+	}
+	return archive.Bytes()
+}
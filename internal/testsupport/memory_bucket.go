@@ -0,0 +1,121 @@
+//go:build testing
+
+package testsupport
+
+import (
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"github.com/mdreem/s3_terraform_registry/registryerror"
+	"github.com/mdreem/s3_terraform_registry/s3"
+	"io"
+	"strings"
+	"sync"
+)
+
+// MemoryBucket is a bucket keeping its objects in memory, which supports writes.
+type MemoryBucket struct {
+	lock    sync.Mutex
+	objects map[string][]byte
+	// FailPutFor makes PutObject fail for the given key.
+	FailPutFor string
+}
+
+func NewMemoryBucket(objects map[string]string) *MemoryBucket {
+	bucket := &MemoryBucket{objects: make(map[string][]byte)}
+	for key, content := range objects {
+		bucket.objects[key] = []byte(content)
+	}
+	return bucket
+}
+
+// Objects returns a copy of the objects in the bucket.
+func (bucket *MemoryBucket) Objects() map[string]string {
+	bucket.lock.Lock()
+	defer bucket.lock.Unlock()
+
+	objects := make(map[string]string, len(bucket.objects))
+	for key, content := range bucket.objects {
+		objects[key] = string(content)
+	}
+	return objects
+}
+
+func (bucket *MemoryBucket) keys() []string {
+	bucket.lock.Lock()
+	defer bucket.lock.Unlock()
+
+	keys := make([]string, 0, len(bucket.objects))
+	for key := range bucket.objects {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (bucket *MemoryBucket) ListObjects() ([]string, error) {
+	return bucket.ListObjectsWithPrefix("", "")
+}
+
+func (bucket *MemoryBucket) ListObjectsWithPrefix(prefix string, delimiter string) ([]string, error) {
+	objects := make([]string, 0)
+	for _, entry := range listEntries(bucket.keys(), prefix, delimiter) {
+		objects = append(objects, entry.key)
+	}
+	return objects, nil
+}
+
+// ListETagsWithPrefix returns the MD5 hashes of the objects as ETags, like S3 does for objects uploaded in one part.
+func (bucket *MemoryBucket) ListETagsWithPrefix(prefix string) (map[string]string, error) {
+	bucket.lock.Lock()
+	defer bucket.lock.Unlock()
+
+	eTags := make(map[string]string)
+	for key, content := range bucket.objects {
+		if strings.HasPrefix(key, prefix) {
+			eTags[key] = fmt.Sprintf(`"%x"`, md5.Sum(content))
+		}
+	}
+	return eTags, nil
+}
+
+func (bucket *MemoryBucket) GetObject(key string) (s3.BucketObject, error) {
+	bucket.lock.Lock()
+	defer bucket.lock.Unlock()
+
+	content, ok := bucket.objects[key]
+	if !ok {
+		return s3.BucketObject{}, registryerror.NotFound(nil, "object %s does not exist", key)
+	}
+	return s3.BucketObject{
+		Body:          io.NopCloser(bytes.NewReader(content)),
+		ContentLength: int64(len(content)),
+	}, nil
+}
+
+// GetObjectWithOptions ignores options and returns the whole object.
+func (bucket *MemoryBucket) GetObjectWithOptions(key string, _ s3.GetObjectOptions) (s3.BucketObject, error) {
+	return bucket.GetObject(key)
+}
+
+func (bucket *MemoryBucket) PutObject(key string, content io.ReadSeeker) error {
+	bucket.lock.Lock()
+	defer bucket.lock.Unlock()
+
+	if key == bucket.FailPutFor {
+		return registryerror.Upstream(nil, "unable to put %s", key)
+	}
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return registryerror.Upstream(err, "unable to put %s", key)
+	}
+	bucket.objects[key] = data
+	return nil
+}
+
+func (bucket *MemoryBucket) DeleteObject(key string) error {
+	bucket.lock.Lock()
+	defer bucket.lock.Unlock()
+
+	delete(bucket.objects, key)
+	return nil
+}
@@ -0,0 +1,20 @@
+//go:build testing
+
+package testsupport
+
+import (
+	"fmt"
+	"time"
+)
+
+// TestPresigner presigns keys as URLs of a fake S3 host, or fails with Err if set.
+type TestPresigner struct {
+	Err error
+}
+
+func (presigner TestPresigner) PresignGetObject(key string, expiry time.Duration) (string, error) {
+	if presigner.Err != nil {
+		return "", presigner.Err
+	}
+	return fmt.Sprintf("https://s3.twin.peaks/%s?expires=%d", key, int(expiry.Seconds())), nil
+}
@@ -4,7 +4,9 @@ package testsupport
 
 import (
 	"errors"
+	"fmt"
 	"github.com/mdreem/s3_terraform_registry/logger"
+	"github.com/mdreem/s3_terraform_registry/registryerror"
 	"github.com/mdreem/s3_terraform_registry/s3"
 	"github.com/mdreem/s3_terraform_registry/schema"
 	"io"
@@ -24,12 +26,24 @@ func (bucket TestBucket) ListObjects() ([]string, error) {
 	return bucket.entries, nil
 }
 
+func (bucket TestBucket) ListObjectsWithPrefix(prefix string, delimiter string) ([]string, error) {
+	objects := make([]string, 0)
+	for _, entry := range listEntries(bucket.entries, prefix, delimiter) {
+		objects = append(objects, entry.key)
+	}
+	return objects, nil
+}
+
 func (bucket TestBucket) GetObject(key string) (s3.BucketObject, error) {
 	object, ok := bucket.objects[key]
 	if ok {
 		return object, nil
 	}
 
+	if !bucket.containsEntry(key) {
+		return s3.BucketObject{}, registryerror.NotFound(nil, "object %s does not exist", key)
+	}
+
 	stringReader := strings.NewReader("Object Data for: " + key)
 	stringReadCloser := io.NopCloser(stringReader)
 
@@ -40,6 +54,81 @@ func (bucket TestBucket) GetObject(key string) (s3.BucketObject, error) {
 	}, nil
 }
 
+// GetObjectWithOptions supports conditions on ETag and LastModified and single ranges of the form bytes=<start>-<end>
+// or bytes=<start>-.
+func (bucket TestBucket) GetObjectWithOptions(key string, options s3.GetObjectOptions) (s3.BucketObject, error) {
+	object, err := bucket.GetObject(key)
+	if err != nil {
+		return s3.BucketObject{}, err
+	}
+
+	notModified := options.IfNoneMatch != "" && options.IfNoneMatch == object.ETag
+	if options.IfNoneMatch == "" && !options.IfModifiedSince.IsZero() && !object.LastModified.IsZero() {
+		notModified = !object.LastModified.After(options.IfModifiedSince)
+	}
+	if notModified {
+		return s3.BucketObject{NotModified: true}, nil
+	}
+
+	if options.HeadOnly {
+		object.Body = nil
+		return object, nil
+	}
+	if options.Range == "" {
+		return object, nil
+	}
+
+	content, err := io.ReadAll(object.Body)
+	if err != nil {
+		return s3.BucketObject{}, err
+	}
+	start, end, ok := parseRange(options.Range, int64(len(content)))
+	if !ok {
+		return s3.BucketObject{}, registryerror.RangeNotSatisfiable(nil, "range %s of %s is not satisfiable", options.Range, key)
+	}
+
+	object.Body = CreateReaderFor(string(content[start : end+1]))
+	object.ContentLength = end - start + 1
+	object.ContentRange = fmt.Sprintf("bytes %d-%d/%d", start, end, len(content))
+	return object, nil
+}
+
+func parseRange(byteRange string, size int64) (int64, int64, bool) {
+	var start, end int64
+	if _, err := fmt.Sscanf(byteRange, "bytes=%d-%d", &start, &end); err != nil {
+		if _, err := fmt.Sscanf(byteRange, "bytes=%d-", &start); err != nil {
+			return 0, 0, false
+		}
+		end = size - 1
+	}
+	if end >= size {
+		end = size - 1
+	}
+	if start > end {
+		return 0, 0, false
+	}
+	return start, end, true
+}
+
+// PutObject fails as TestBucket is read only. Use MemoryBucket to test writes.
+func (bucket TestBucket) PutObject(key string, _ io.ReadSeeker) error {
+	return registryerror.Upstream(nil, "unable to put %s: test bucket is read only", key)
+}
+
+// DeleteObject fails as TestBucket is read only. Use MemoryBucket to test writes.
+func (bucket TestBucket) DeleteObject(key string) error {
+	return registryerror.Upstream(nil, "unable to delete %s: test bucket is read only", key)
+}
+
+func (bucket TestBucket) containsEntry(key string) bool {
+	for _, entry := range bucket.entries {
+		if entry == key {
+			return true
+		}
+	}
+	return false
+}
+
 func NewTestBucketWithObjects(entries []string, objects map[string]s3.BucketObject) TestBucket {
 	return TestBucket{entries: entries, objects: objects}
 }
@@ -79,10 +168,45 @@ func (t TestProviderData) ListVersions(namespace string, providerType string) (s
 	}, nil
 }
 
+func (t TestProviderData) VersionsFromObjects(namespace string, providerType string, _ []string, _ map[string]string) (schema.ProviderVersions, error) {
+	return t.ListVersions(namespace, providerType)
+}
+
 func (t TestProviderData) GetDownloadData(namespace string, providerType string, version string, os string, arch string) (schema.DownloadData, error) {
+	metadata, err := t.GetVersionMetadata(namespace, providerType, version)
+	if err != nil {
+		return schema.DownloadData{}, err
+	}
+	return t.DownloadData(namespace, providerType, version, os, arch, metadata)
+}
+
+func (t TestProviderData) GetVersionMetadata(namespace string, providerType string, version string) (schema.VersionMetadata, error) {
+	if namespace == "UPSTREAM_ERROR_PROVIDER" {
+		return schema.VersionMetadata{}, registryerror.Upstream(errors.New("connection reset"), "unable to get shasum")
+	}
+	return schema.VersionMetadata{}, nil
+}
+
+func (t TestProviderData) GetMirrorMetadata(namespace string, providerType string, version string) (schema.VersionMetadata, error) {
+	return t.GetVersionMetadata(namespace, providerType, version)
+}
+
+func (t TestProviderData) DownloadData(namespace string, providerType string, version string, os string, arch string, metadata schema.VersionMetadata) (schema.DownloadData, error) {
 	return schema.DownloadData{}, nil
 }
 
-func (t TestProviderData) Proxy(namespace string, providerType string, version string, filename string) (schema.ProxyResponse, error) {
-	return schema.ProxyResponse{}, nil
+func (t TestProviderData) GetMirrorArchives(namespace string, providerType string, version string) (schema.MirrorArchives, error) {
+	metadata, err := t.GetMirrorMetadata(namespace, providerType, version)
+	if err != nil {
+		return schema.MirrorArchives{}, err
+	}
+	return t.MirrorArchives(namespace, providerType, version, metadata)
+}
+
+func (t TestProviderData) MirrorArchives(namespace string, providerType string, version string, metadata schema.VersionMetadata) (schema.MirrorArchives, error) {
+	return schema.MirrorArchives{}, nil
+}
+
+func (t TestProviderData) Proxy(namespace string, providerType string, version string, filename string, options s3.GetObjectOptions) (schema.ProxyResponse, error) {
+	return schema.ProxyResponse{Body: CreateReaderFor(filename), ContentLength: int64(len(filename))}, nil
 }
@@ -0,0 +1,97 @@
+//go:build testing
+
+package testsupport
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TestS3Client is a fake S3 API which only answers ListObjectsV2. It returns at most pageSize entries per call and
+// hands out continuation tokens like S3 does for truncated listings.
+type TestS3Client struct {
+	s3iface.S3API
+	keys      []string
+	pageSize  int
+	ListCalls int
+}
+
+func NewTestS3Client(keys []string, pageSize int) *TestS3Client {
+	return &TestS3Client{keys: keys, pageSize: pageSize}
+}
+
+func (client *TestS3Client) ListObjectsV2(input *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error) {
+	client.ListCalls++
+
+	entries := listEntries(client.keys, aws.StringValue(input.Prefix), aws.StringValue(input.Delimiter))
+
+	start := 0
+	if input.ContinuationToken != nil {
+		var err error
+		start, err = strconv.Atoi(*input.ContinuationToken)
+		if err != nil || start > len(entries) {
+			return nil, fmt.Errorf("invalid continuation token %s", *input.ContinuationToken)
+		}
+	}
+
+	end := start + client.pageSize
+	if end > len(entries) {
+		end = len(entries)
+	}
+
+	output := &s3.ListObjectsV2Output{
+		IsTruncated: aws.Bool(end < len(entries)),
+	}
+	for _, entry := range entries[start:end] {
+		if entry.commonPrefix {
+			output.CommonPrefixes = append(output.CommonPrefixes, &s3.CommonPrefix{Prefix: aws.String(entry.key)})
+		} else {
+			output.Contents = append(output.Contents, &s3.Object{Key: aws.String(entry.key), ETag: aws.String(fmt.Sprintf("%q", entry.key))})
+		}
+	}
+	if end < len(entries) {
+		output.NextContinuationToken = aws.String(strconv.Itoa(end))
+	}
+
+	return output, nil
+}
+
+type listEntry struct {
+	key          string
+	commonPrefix bool
+}
+
+// listEntries filters keys by prefix and rolls them up at the delimiter, returning them in lexicographical order.
+func listEntries(keys []string, prefix string, delimiter string) []listEntry {
+	sortedKeys := make([]string, len(keys))
+	copy(sortedKeys, keys)
+	sort.Strings(sortedKeys)
+
+	entries := make([]listEntry, 0)
+	seenPrefixes := make(map[string]bool)
+	for _, key := range sortedKeys {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		if delimiter != "" {
+			rest := strings.TrimPrefix(key, prefix)
+			if index := strings.Index(rest, delimiter); index >= 0 {
+				commonPrefix := prefix + rest[:index+len(delimiter)]
+				if !seenPrefixes[commonPrefix] {
+					seenPrefixes[commonPrefix] = true
+					entries = append(entries, listEntry{key: commonPrefix, commonPrefix: true})
+				}
+				continue
+			}
+		}
+
+		entries = append(entries, listEntry{key: key})
+	}
+	return entries
+}
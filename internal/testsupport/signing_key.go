@@ -0,0 +1,91 @@
+//go:build testing
+
+package testsupport
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+// SigningKey is an OpenPGP key generated for a test.
+type SigningKey struct {
+	entity *openpgp.Entity
+}
+
+// NewSigningKey generates an ed25519 key, which is fast enough to be generated by every test.
+func NewSigningKey(name string) SigningKey {
+	entity, err := openpgp.NewEntity(name, "", name+"@twin.peaks", &packet.Config{Algorithm: packet.PubKeyAlgoEdDSA})
+	if err != nil {
+		panic(fmt.Sprintf("unable to generate key: %v", err))
+	}
+	return SigningKey{entity: entity}
+}
+
+func (key SigningKey) KeyID() string {
+	return fmt.Sprintf("%016X", key.entity.PrimaryKey.KeyId)
+}
+
+func (key SigningKey) ArmoredPublicKey() string {
+	buffer := new(bytes.Buffer)
+	writer, err := armor.Encode(buffer, openpgp.PublicKeyType, nil)
+	if err != nil {
+		panic(fmt.Sprintf("unable to armor key: %v", err))
+	}
+	if err := key.entity.Serialize(writer); err != nil {
+		panic(fmt.Sprintf("unable to serialize key: %v", err))
+	}
+	if err := writer.Close(); err != nil {
+		panic(fmt.Sprintf("unable to armor key: %v", err))
+	}
+	return buffer.String()
+}
+
+// Sign returns a binary detached signature of content.
+func (key SigningKey) Sign(content []byte) []byte {
+	buffer := new(bytes.Buffer)
+	if err := openpgp.DetachSign(buffer, key.entity, bytes.NewReader(content), nil); err != nil {
+		panic(fmt.Sprintf("unable to sign: %v", err))
+	}
+	return buffer.Bytes()
+}
+
+// ArmoredSign returns an ASCII armored detached signature of content.
+func (key SigningKey) ArmoredSign(content []byte) []byte {
+	buffer := new(bytes.Buffer)
+	if err := openpgp.ArmoredDetachSign(buffer, key.entity, bytes.NewReader(content), nil); err != nil {
+		panic(fmt.Sprintf("unable to sign: %v", err))
+	}
+	return buffer.Bytes()
+}
+
+// ArmoredPrivateKey returns the ASCII armored private key, encrypted with passphrase unless it is empty.
+func (key SigningKey) ArmoredPrivateKey(passphrase string) string {
+	entity := key.entity
+	if passphrase != "" {
+		copied := *key.entity
+		privateKey := *key.entity.PrivateKey
+		copied.PrivateKey = &privateKey
+		// the subkeys share their private keys with key, encrypting them would break key
+		copied.Subkeys = nil
+		if err := copied.EncryptPrivateKeys([]byte(passphrase), nil); err != nil {
+			panic(fmt.Sprintf("unable to encrypt key: %v", err))
+		}
+		entity = &copied
+	}
+
+	buffer := new(bytes.Buffer)
+	writer, err := armor.Encode(buffer, openpgp.PrivateKeyType, nil)
+	if err != nil {
+		panic(fmt.Sprintf("unable to armor key: %v", err))
+	}
+	if err := entity.SerializePrivateWithoutSigning(writer, nil); err != nil {
+		panic(fmt.Sprintf("unable to serialize key: %v", err))
+	}
+	if err := writer.Close(); err != nil {
+		panic(fmt.Sprintf("unable to armor key: %v", err))
+	}
+	return buffer.String()
+}
@@ -0,0 +1,99 @@
+package moduledata
+
+import (
+	"fmt"
+	"github.com/mdreem/s3_terraform_registry/schema"
+	"strings"
+)
+
+// Prefix is the prefix of all keys belonging to modules. Keys with this prefix are not part of the provider index.
+const Prefix = "modules/"
+
+var archiveExtensions = []string{".tar.gz", ".tgz", ".zip"}
+
+// IsArchive checks whether filename is an archive a module can be downloaded from.
+func IsArchive(filename string) bool {
+	for _, extension := range archiveExtensions {
+		if strings.HasSuffix(filename, extension) && len(filename) > len(extension) {
+			return true
+		}
+	}
+	return false
+}
+
+// ModuleKey describes an object stored at modules/<namespace>/<name>/<system>/<version>/<filename>.
+type ModuleKey struct {
+	Namespace string
+	Name      string
+	System    string
+	Version   string
+	Filename  string
+}
+
+// ParseModuleKey parses the key of a file of a module version.
+func ParseModuleKey(key string) (ModuleKey, bool) {
+	if !strings.HasPrefix(key, Prefix) {
+		return ModuleKey{}, false
+	}
+
+	parts := strings.Split(strings.TrimPrefix(key, Prefix), "/")
+	if len(parts) != 5 {
+		return ModuleKey{}, false
+	}
+	for _, part := range parts {
+		if part == "" {
+			return ModuleKey{}, false
+		}
+	}
+
+	return ModuleKey{
+		Namespace: parts[0],
+		Name:      parts[1],
+		System:    parts[2],
+		Version:   parts[3],
+		Filename:  parts[4],
+	}, true
+}
+
+// ModulePrefix returns the prefix of all keys of a module.
+func ModulePrefix(namespace string, name string, system string) string {
+	return fmt.Sprintf("%s%s/%s/%s/", Prefix, namespace, name, system)
+}
+
+// Module is the index of a module, containing the archive of every version.
+type Module struct {
+	Versions []Version
+}
+
+type Version struct {
+	Version string
+	Archive string
+}
+
+// Find returns the indexed version.
+func (module Module) Find(version string) (Version, bool) {
+	for _, moduleVersion := range module.Versions {
+		if moduleVersion.Version == version {
+			return moduleVersion, true
+		}
+	}
+	return Version{}, false
+}
+
+// FindArchive returns the indexed version if filename is its archive.
+func (module Module) FindArchive(version string, filename string) (Version, bool) {
+	moduleVersion, ok := module.Find(version)
+	if !ok || moduleVersion.Archive != filename {
+		return Version{}, false
+	}
+	return moduleVersion, true
+}
+
+// ModuleVersions returns the versions as answered by the module registry protocol.
+func (module Module) ModuleVersions() schema.ModuleVersions {
+	versions := make([]schema.ModuleVersion, 0, len(module.Versions))
+	for _, moduleVersion := range module.Versions {
+		versions = append(versions, schema.ModuleVersion{Version: moduleVersion.Version})
+	}
+	return schema.ModuleVersions{Modules: []schema.ModuleVersionList{{Versions: versions}}}
+}
@@ -0,0 +1,188 @@
+package moduledata
+
+import (
+	"fmt"
+	"github.com/mdreem/s3_terraform_registry/logger"
+	"github.com/mdreem/s3_terraform_registry/providerdata"
+	"github.com/mdreem/s3_terraform_registry/registryerror"
+	"github.com/mdreem/s3_terraform_registry/s3"
+	"github.com/mdreem/s3_terraform_registry/schema"
+	"github.com/mdreem/s3_terraform_registry/semver"
+	"sort"
+	"time"
+)
+
+type ModuleData interface {
+	ListModuleVersions(namespace string, name string, system string) (schema.ModuleVersions, error)
+	// GetModuleDownloadURL returns the URL of the archive of the version, which is answered in X-Terraform-Get.
+	GetModuleDownloadURL(namespace string, name string, system string, version string) (string, error)
+	ProxyModule(namespace string, name string, system string, version string, filename string, options s3.GetObjectOptions) (schema.ProxyResponse, error)
+}
+
+// ModuleIndexer builds the index of a module from objects which have already been listed.
+type ModuleIndexer interface {
+	ModuleFromObjects(namespace string, name string, system string, objects []string) (Module, error)
+}
+
+// Backend is the module data read from storage, which the cache is built upon.
+type Backend interface {
+	ModuleData
+	ModuleIndexer
+	ArchiveURL(namespace string, name string, system string, version Version) string
+	// ProxyArchive gets the archive of an indexed version, which has already been looked up in the index.
+	ProxyArchive(namespace string, name string, system string, version Version, options s3.GetObjectOptions) (schema.ProxyResponse, error)
+}
+
+type RegistryClient struct {
+	bucket        s3.BucketReaderWriter
+	hostname      string
+	presigner     s3.Presigner
+	presignExpiry time.Duration
+}
+
+type Option func(client *RegistryClient)
+
+// WithPresignedDownloads redirects archive downloads to URLs presigned by presigner which expire after expiry instead
+// of proxying the archives. Archives are proxied if presigning fails.
+func WithPresignedDownloads(presigner s3.Presigner, expiry time.Duration) Option {
+	return func(client *RegistryClient) {
+		client.presigner = presigner
+		client.presignExpiry = expiry
+	}
+}
+
+func NewS3Backend(bucket s3.BucketReaderWriter, hostname string, options ...Option) (RegistryClient, error) {
+	client := RegistryClient{bucket: bucket, hostname: hostname}
+	for _, option := range options {
+		option(&client)
+	}
+	return client, nil
+}
+
+func (client RegistryClient) ListModuleVersions(namespace string, name string, system string) (schema.ModuleVersions, error) {
+	module, err := client.listModule(namespace, name, system)
+	if err != nil {
+		return schema.ModuleVersions{}, err
+	}
+	return module.ModuleVersions(), nil
+}
+
+func (client RegistryClient) GetModuleDownloadURL(namespace string, name string, system string, version string) (string, error) {
+	if _, err := semver.Parse(version); err != nil {
+		return "", registryerror.BadRequest(err, "%s is not a valid version", version)
+	}
+
+	module, err := client.listModule(namespace, name, system)
+	if err != nil {
+		return "", err
+	}
+
+	moduleVersion, ok := module.Find(version)
+	if !ok {
+		return "", registryerror.NotFound(nil, "version %s of module %s/%s/%s does not exist", version, namespace, name, system)
+	}
+	return client.ArchiveURL(namespace, name, system, moduleVersion), nil
+}
+
+func (client RegistryClient) listModule(namespace string, name string, system string) (Module, error) {
+	prefix := ModulePrefix(namespace, name, system)
+	objects, err := client.bucket.ListObjectsWithPrefix(prefix, "")
+	if err != nil {
+		logger.Sugar.Errorw("an error occurred when listing objects in S3", "error", err)
+		return Module{}, err
+	}
+	if len(objects) == 0 {
+		return Module{}, registryerror.NotFound(nil, "module %s/%s/%s does not exist", namespace, name, system)
+	}
+
+	return client.ModuleFromObjects(namespace, name, system, objects)
+}
+
+// ModuleFromObjects builds the index of the module from the keys in objects. Keys of other modules are ignored. If a
+// version contains several archives, the first one in lexical order is used.
+func (client RegistryClient) ModuleFromObjects(namespace string, name string, system string, objects []string) (Module, error) {
+	sortedObjects := append([]string(nil), objects...)
+	sort.Strings(sortedObjects)
+
+	archives := make(map[string]string)
+	parsedVersions := make(map[string]semver.Version)
+	for _, item := range sortedObjects {
+		moduleKey, ok := ParseModuleKey(item)
+		if !ok || moduleKey.Namespace != namespace || moduleKey.Name != name || moduleKey.System != system {
+			continue
+		}
+		if !IsArchive(moduleKey.Filename) {
+			logger.Sugar.Debugw("list module versions: ignoring", "item", item)
+			continue
+		}
+		if _, ok := archives[moduleKey.Version]; ok {
+			logger.Sugar.Warnw("list module versions: ignoring additional archive", "item", item)
+			continue
+		}
+
+		parsedVersion, err := semver.Parse(moduleKey.Version)
+		if err != nil {
+			logger.Sugar.Warnw("list module versions: skipping invalid version", "item", item, "error", err)
+			continue
+		}
+		parsedVersions[moduleKey.Version] = parsedVersion
+		archives[moduleKey.Version] = moduleKey.Filename
+	}
+
+	versions := make([]Version, 0, len(archives))
+	for version, archive := range archives {
+		versions = append(versions, Version{Version: version, Archive: archive})
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		result := parsedVersions[versions[i].Version].Compare(parsedVersions[versions[j].Version])
+		if result == 0 {
+			return versions[i].Version < versions[j].Version
+		}
+		return result < 0
+	})
+
+	return Module{Versions: versions}, nil
+}
+
+func (client RegistryClient) ArchiveURL(namespace string, name string, system string, version Version) string {
+	return fmt.Sprintf("https://%s/v1/modules/%s/%s/%s/%s/archive/%s", client.hostname, namespace, name, system, version.Version, version.Archive)
+}
+
+// ProxyModule gets the archive of the version if it is the archive the module index contains for the version.
+func (client RegistryClient) ProxyModule(namespace string, name string, system string, version string, filename string, options s3.GetObjectOptions) (schema.ProxyResponse, error) {
+	module, err := client.listModule(namespace, name, system)
+	if err != nil {
+		return schema.ProxyResponse{}, err
+	}
+
+	moduleVersion, ok := module.FindArchive(version, filename)
+	if !ok {
+		return schema.ProxyResponse{}, ArchiveNotFound(namespace, name, system, version, filename)
+	}
+	return client.ProxyArchive(namespace, name, system, moduleVersion, options)
+}
+
+func (client RegistryClient) ProxyArchive(namespace string, name string, system string, version Version, options s3.GetObjectOptions) (schema.ProxyResponse, error) {
+	key := fmt.Sprintf("%s%s/%s", ModulePrefix(namespace, name, system), version.Version, version.Archive)
+	logger.Sugar.Infow("proxying module archive", "file", key)
+
+	if client.presigner != nil {
+		presignedURL, err := client.presigner.PresignGetObject(key, client.presignExpiry)
+		if err == nil {
+			return schema.ProxyResponse{RedirectURL: presignedURL}, nil
+		}
+		logger.Sugar.Warnw("unable to presign module archive, proxying it instead", "file", key, "error", err)
+	}
+
+	object, err := client.bucket.GetObjectWithOptions(key, options)
+	if err != nil {
+		return schema.ProxyResponse{}, err
+	}
+
+	return providerdata.ProxyResponse(object), nil
+}
+
+// ArchiveNotFound reports that filename is not the archive of an indexed version of the module.
+func ArchiveNotFound(namespace string, name string, system string, version string, filename string) error {
+	return registryerror.NotFound(nil, "%s is not the archive of version %s of module %s/%s/%s", filename, version, namespace, name, system)
+}
@@ -0,0 +1,125 @@
+package moduledata
+
+import (
+	"errors"
+	"github.com/mdreem/s3_terraform_registry/internal/testsupport"
+	"github.com/mdreem/s3_terraform_registry/registryerror"
+	"github.com/mdreem/s3_terraform_registry/s3"
+	"github.com/mdreem/s3_terraform_registry/schema"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func moduleBucketContent() []string {
+	return []string{
+		"modules/black/lodge/aws/1.10.0/lodge.tar.gz",
+		"modules/black/lodge/aws/1.2.0/lodge.zip",
+		"modules/black/lodge/aws/1.2.0/README.md",
+		"modules/black/lodge/aws/1.2.0-rc.1/lodge.tgz",
+		"modules/black/lodge/aws/latest/lodge.tgz",
+		"modules/black/lodge/aws/2.0.0/",
+		"modules/black/lodge/gcp/1.0.0/lodge.tar.gz",
+		"black/lodge/1.0.0/terraform-provider-lodge_1.0.0_linux_amd64.zip",
+	}
+}
+
+func TestRegistryClient_ListModuleVersions(t *testing.T) {
+	client, _ := NewS3Backend(testsupport.NewTestBucket(moduleBucketContent()), "twin.peaks")
+
+	got, err := client.ListModuleVersions("black", "lodge", "aws")
+	if err != nil {
+		t.Fatalf("ListModuleVersions() error = %v", err)
+	}
+
+	want := schema.ModuleVersions{Modules: []schema.ModuleVersionList{{Versions: []schema.ModuleVersion{
+		{Version: "1.2.0-rc.1"},
+		{Version: "1.2.0"},
+		{Version: "1.10.0"},
+	}}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ListModuleVersions() got = %v, want %v", got, want)
+	}
+
+	if _, err := client.ListModuleVersions("black", "owl", "aws"); !errors.Is(err, registryerror.ErrNotFound) {
+		t.Errorf("ListModuleVersions() of missing module error = %v, want not found", err)
+	}
+}
+
+func TestRegistryClient_GetModuleDownloadURL(t *testing.T) {
+	client, _ := NewS3Backend(testsupport.NewTestBucket(moduleBucketContent()), "twin.peaks")
+
+	tests := []struct {
+		name    string
+		version string
+		want    string
+		wantErr error
+	}{
+		{
+			name:    "download existing version",
+			version: "1.2.0",
+			want:    "https://twin.peaks/v1/modules/black/lodge/aws/1.2.0/archive/lodge.zip",
+		},
+		{
+			name:    "download missing version",
+			version: "2.0.0",
+			wantErr: registryerror.ErrNotFound,
+		},
+		{
+			name:    "download invalid version",
+			version: "latest",
+			wantErr: registryerror.ErrBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := client.GetModuleDownloadURL("black", "lodge", "aws", tt.version)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("GetModuleDownloadURL() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("GetModuleDownloadURL() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegistryClient_ProxyModule(t *testing.T) {
+	client, _ := NewS3Backend(testsupport.NewTestBucket(moduleBucketContent()), "twin.peaks")
+
+	if _, err := client.ProxyModule("black", "lodge", "aws", "1.2.0", "lodge.zip", s3.GetObjectOptions{}); err != nil {
+		t.Errorf("ProxyModule() error = %v", err)
+	}
+	tests := []struct {
+		name     string
+		version  string
+		filename string
+	}{
+		{name: "file which is no archive", version: "1.2.0", filename: "README.md"},
+		{name: "version which is no semantic version", version: "latest", filename: "lodge.tgz"},
+		{name: "version which does not exist", version: "3.0.0", filename: "lodge.zip"},
+		{name: "archive of another version", version: "1.2.0", filename: "lodge.tar.gz"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := client.ProxyModule("black", "lodge", "aws", tt.version, tt.filename, s3.GetObjectOptions{}); !errors.Is(err, registryerror.ErrNotFound) {
+				t.Errorf("ProxyModule() error = %v, want not found", err)
+			}
+		})
+	}
+}
+
+func TestRegistryClient_ProxyModulePresigned(t *testing.T) {
+	client, _ := NewS3Backend(testsupport.NewTestBucket(moduleBucketContent()), "twin.peaks", WithPresignedDownloads(testsupport.TestPresigner{}, time.Minute))
+
+	got, err := client.ProxyModule("black", "lodge", "aws", "1.2.0", "lodge.zip", s3.GetObjectOptions{})
+	if err != nil {
+		t.Fatalf("ProxyModule() error = %v", err)
+	}
+
+	const wantedURL = "https://s3.twin.peaks/modules/black/lodge/aws/1.2.0/lodge.zip?expires=60"
+	if got.RedirectURL != wantedURL {
+		t.Errorf("ProxyModule() redirect URL = %v, want %v", got.RedirectURL, wantedURL)
+	}
+}
@@ -0,0 +1,70 @@
+package moduledata
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseModuleKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		key    string
+		want   ModuleKey
+		wantOk bool
+	}{
+		{
+			name:   "parse archive key",
+			key:    "modules/black/lodge/aws/1.0.0/lodge.tar.gz",
+			want:   ModuleKey{Namespace: "black", Name: "lodge", System: "aws", Version: "1.0.0", Filename: "lodge.tar.gz"},
+			wantOk: true,
+		},
+		{
+			name:   "ignore provider key",
+			key:    "black/lodge/1.0.0/terraform-provider-lodge_1.0.0_linux_amd64.zip",
+			wantOk: false,
+		},
+		{
+			name:   "ignore version folder",
+			key:    "modules/black/lodge/aws/1.0.0/",
+			wantOk: false,
+		},
+		{
+			name:   "ignore nested file",
+			key:    "modules/black/lodge/aws/1.0.0/nested/lodge.zip",
+			wantOk: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseModuleKey(tt.key)
+			if ok != tt.wantOk {
+				t.Errorf("ParseModuleKey() ok = %v, wantOk %v", ok, tt.wantOk)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseModuleKey() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsArchive(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     bool
+	}{
+		{filename: "lodge.tar.gz", want: true},
+		{filename: "lodge.tgz", want: true},
+		{filename: "lodge.zip", want: true},
+		{filename: ".zip", want: false},
+		{filename: "README.md", want: false},
+		{filename: "lodge.tar", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			if got := IsArchive(tt.filename); got != tt.want {
+				t.Errorf("IsArchive() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package pgp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"io"
+	"strings"
+)
+
+const armorHeader = "-----BEGIN "
+
+// ReadPublicKeys parses one or more ASCII armored public keys, as Terraform only accepts armored keys. Several keys
+// may be concatenated, each in its own armored block.
+func ReadPublicKeys(armoredKeys string) (openpgp.EntityList, error) {
+	if !strings.HasPrefix(strings.TrimSpace(armoredKeys), armorHeader) {
+		return nil, errors.New("the public key is not ASCII armored")
+	}
+
+	keys := make(openpgp.EntityList, 0)
+	// armor.Decode may read past the end of a block, so every block is decoded separately
+	for _, armoredKey := range splitArmoredBlocks(armoredKeys) {
+		block, err := armor.Decode(strings.NewReader(armoredKey))
+		if err != nil {
+			return nil, fmt.Errorf("unable to read public key: %v", err)
+		}
+		if block.Type != openpgp.PublicKeyType {
+			return nil, fmt.Errorf("expected a public key, got %s", block.Type)
+		}
+
+		blockKeys, err := openpgp.ReadKeyRing(block.Body)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read public key: %v", err)
+		}
+		keys = append(keys, blockKeys...)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no public key found")
+	}
+	return keys, nil
+}
+
+func splitArmoredBlocks(armored string) []string {
+	blocks := make([]string, 0)
+	for {
+		start := strings.Index(armored, armorHeader)
+		if start < 0 {
+			return blocks
+		}
+		next := strings.Index(armored[start+len(armorHeader):], armorHeader)
+		if next < 0 {
+			return append(blocks, armored[start:])
+		}
+		end := start + len(armorHeader) + next
+		blocks = append(blocks, armored[start:end])
+		armored = armored[end:]
+	}
+}
+
+// KeyID returns the ID of key in the form announced to Terraform, the long key ID as upper case hex.
+func KeyID(key *openpgp.Entity) string {
+	return fmt.Sprintf("%016X", key.PrimaryKey.KeyId)
+}
+
+// ArmorPublicKey returns the ASCII armored public key of key.
+func ArmorPublicKey(key *openpgp.Entity) (string, error) {
+	var buffer bytes.Buffer
+	writer, err := armor.Encode(&buffer, openpgp.PublicKeyType, nil)
+	if err != nil {
+		return "", err
+	}
+	if err := key.Serialize(writer); err != nil {
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
+
+// Dearmor returns the binary form of an ASCII armored signature, as Terraform expects binary signatures. Binary
+// signatures are returned unchanged.
+func Dearmor(signature []byte) ([]byte, error) {
+	if !bytes.HasPrefix(bytes.TrimSpace(signature), []byte(armorHeader)) {
+		return signature, nil
+	}
+
+	block, err := armor.Decode(bytes.NewReader(signature))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read armored signature: %v", err)
+	}
+	return io.ReadAll(block.Body)
+}
+
+// VerifyDetached checks that signature is a valid detached signature of content made by one of keys and returns the
+// ID of the key which made it. signature may be binary or ASCII armored.
+func VerifyDetached(keys openpgp.EntityList, content []byte, signature []byte) (string, error) {
+	binarySignature, err := Dearmor(signature)
+	if err != nil {
+		return "", err
+	}
+
+	signer, err := openpgp.CheckDetachedSignature(keys, bytes.NewReader(content), bytes.NewReader(binarySignature), nil)
+	if err != nil {
+		return "", fmt.Errorf("the signature does not verify: %v", err)
+	}
+	return KeyID(signer), nil
+}
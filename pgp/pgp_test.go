@@ -0,0 +1,79 @@
+package pgp
+
+import (
+	"github.com/mdreem/s3_terraform_registry/internal/testsupport"
+	"testing"
+)
+
+func TestVerifyDetached(t *testing.T) {
+	key := testsupport.NewSigningKey("Dale Cooper")
+	otherKey := testsupport.NewSigningKey("Windom Earle")
+	content := []byte("315 coffee")
+
+	tests := []struct {
+		name      string
+		publicKey string
+		content   []byte
+		signature []byte
+		wantKeyID string
+		wantErr   bool
+	}{
+		{
+			name:      "binary signature",
+			publicKey: key.ArmoredPublicKey(),
+			content:   content,
+			signature: key.Sign(content),
+			wantKeyID: key.KeyID(),
+		},
+		{
+			name:      "armored signature",
+			publicKey: key.ArmoredPublicKey(),
+			content:   content,
+			signature: key.ArmoredSign(content),
+			wantKeyID: key.KeyID(),
+		},
+		{
+			name:      "one of several keys",
+			publicKey: otherKey.ArmoredPublicKey() + key.ArmoredPublicKey(),
+			content:   content,
+			signature: key.Sign(content),
+			wantKeyID: key.KeyID(),
+		},
+		{
+			name:      "modified content",
+			publicKey: key.ArmoredPublicKey(),
+			content:   []byte("316 coffee"),
+			signature: key.Sign(content),
+			wantErr:   true,
+		},
+		{
+			name:      "signature of another key",
+			publicKey: key.ArmoredPublicKey(),
+			content:   content,
+			signature: otherKey.Sign(content),
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := ReadPublicKeys(tt.publicKey)
+			if err != nil {
+				t.Fatalf("ReadPublicKeys() error = %v", err)
+			}
+
+			keyID, err := VerifyDetached(keys, tt.content, tt.signature)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyDetached() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if keyID != tt.wantKeyID {
+				t.Errorf("VerifyDetached() keyID = %v, want %v", keyID, tt.wantKeyID)
+			}
+		})
+	}
+}
+
+func TestReadPublicKeys_RequiresArmor(t *testing.T) {
+	if _, err := ReadPublicKeys("mQINBF..."); err == nil {
+		t.Errorf("ReadPublicKeys() expected error for key which is not armored")
+	}
+}
@@ -0,0 +1,55 @@
+package pgp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"strings"
+)
+
+// Signer signs shasum files with a private key held by the registry.
+type Signer struct {
+	entity *openpgp.Entity
+}
+
+// ReadSigner parses an ASCII armored private key. An encrypted key is decrypted with passphrase.
+func ReadSigner(armoredKey string, passphrase []byte) (Signer, error) {
+	keys, err := openpgp.ReadArmoredKeyRing(strings.NewReader(armoredKey))
+	if err != nil {
+		return Signer{}, fmt.Errorf("unable to read private key: %v", err)
+	}
+	if len(keys) == 0 || keys[0].PrivateKey == nil {
+		return Signer{}, errors.New("no private key found")
+	}
+
+	entity := keys[0]
+	if entity.PrivateKey.Encrypted {
+		if len(passphrase) == 0 {
+			return Signer{}, errors.New("the private key is encrypted and no passphrase is given")
+		}
+		if err := entity.DecryptPrivateKeys(passphrase); err != nil {
+			return Signer{}, fmt.Errorf("unable to decrypt private key: %v", err)
+		}
+	}
+	return Signer{entity: entity}, nil
+}
+
+// KeyID returns the ID of the signing key in the form announced to Terraform.
+func (signer Signer) KeyID() string {
+	return KeyID(signer.entity)
+}
+
+// ArmoredPublicKey returns the ASCII armored public key matching the signatures.
+func (signer Signer) ArmoredPublicKey() (string, error) {
+	return ArmorPublicKey(signer.entity)
+}
+
+// Sign returns a binary detached signature of content.
+func (signer Signer) Sign(content []byte) ([]byte, error) {
+	var signature bytes.Buffer
+	if err := openpgp.DetachSign(&signature, signer.entity, bytes.NewReader(content), nil); err != nil {
+		return nil, fmt.Errorf("unable to sign: %v", err)
+	}
+	return signature.Bytes(), nil
+}
@@ -0,0 +1,79 @@
+package pgp
+
+import (
+	"github.com/mdreem/s3_terraform_registry/internal/testsupport"
+	"testing"
+)
+
+func TestReadSigner(t *testing.T) {
+	key := testsupport.NewSigningKey("Dale Cooper")
+	content := []byte("315 coffee")
+
+	tests := []struct {
+		name       string
+		armoredKey string
+		passphrase string
+		wantErr    bool
+	}{
+		{
+			name:       "unencrypted key",
+			armoredKey: key.ArmoredPrivateKey(""),
+		},
+		{
+			name:       "encrypted key",
+			armoredKey: key.ArmoredPrivateKey("fire walk with me"),
+			passphrase: "fire walk with me",
+		},
+		{
+			name:       "encrypted key without passphrase",
+			armoredKey: key.ArmoredPrivateKey("fire walk with me"),
+			wantErr:    true,
+		},
+		{
+			name:       "encrypted key with wrong passphrase",
+			armoredKey: key.ArmoredPrivateKey("fire walk with me"),
+			passphrase: "the owls are not what they seem",
+			wantErr:    true,
+		},
+		{
+			name:       "public key",
+			armoredKey: key.ArmoredPublicKey(),
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, err := ReadSigner(tt.armoredKey, []byte(tt.passphrase))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadSigner() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if signer.KeyID() != key.KeyID() {
+				t.Errorf("KeyID() got = %v, want %v", signer.KeyID(), key.KeyID())
+			}
+			publicKey, err := signer.ArmoredPublicKey()
+			if err != nil {
+				t.Fatalf("ArmoredPublicKey() error = %v", err)
+			}
+			signature, err := signer.Sign(content)
+			if err != nil {
+				t.Fatalf("Sign() error = %v", err)
+			}
+
+			keys, err := ReadPublicKeys(publicKey)
+			if err != nil {
+				t.Fatalf("ReadPublicKeys() error = %v", err)
+			}
+			keyID, err := VerifyDetached(keys, content, signature)
+			if err != nil {
+				t.Fatalf("VerifyDetached() error = %v", err)
+			}
+			if keyID != key.KeyID() {
+				t.Errorf("VerifyDetached() got = %v, want %v", keyID, key.KeyID())
+			}
+		})
+	}
+}
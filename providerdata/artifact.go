@@ -0,0 +1,74 @@
+package providerdata
+
+import (
+	"fmt"
+	"strings"
+)
+
+const artifactPrefix = "terraform-provider-"
+const artifactExtension = ".zip"
+
+// Artifact describes a provider archive stored at
+// <namespace>/<type>/<version>/terraform-provider-<type>_<version>_<os>_<arch>.zip
+type Artifact struct {
+	Namespace string
+	Type      string
+	Version   string
+	Os        string
+	Arch      string
+}
+
+func ArtifactFilename(providerType string, version string, os string, arch string) string {
+	return fmt.Sprintf("%s%s_%s_%s_%s%s", artifactPrefix, providerType, version, os, arch, artifactExtension)
+}
+
+func (artifact Artifact) Filename() string {
+	return ArtifactFilename(artifact.Type, artifact.Version, artifact.Os, artifact.Arch)
+}
+
+func (artifact Artifact) Key() string {
+	return fmt.Sprintf("%s/%s/%s/%s", artifact.Namespace, artifact.Type, artifact.Version, artifact.Filename())
+}
+
+// ParseArtifactKey parses the key of a provider archive. As type and version are known from the path, the file name
+// is matched against them instead of splitting it at underscores, so types containing underscores are supported.
+func ParseArtifactKey(key string) (Artifact, bool) {
+	parts := strings.Split(key, "/")
+	if len(parts) != 4 {
+		return Artifact{}, false
+	}
+
+	namespace, providerType, version, filename := parts[0], parts[1], parts[2], parts[3]
+	if namespace == "" || providerType == "" || version == "" {
+		return Artifact{}, false
+	}
+
+	os, arch, ok := ParseArtifactFilename(providerType, version, filename)
+	if !ok {
+		return Artifact{}, false
+	}
+
+	return Artifact{
+		Namespace: namespace,
+		Type:      providerType,
+		Version:   version,
+		Os:        os,
+		Arch:      arch,
+	}, true
+}
+
+// ParseArtifactFilename extracts os and arch from the file name of an archive of the given provider type and version.
+func ParseArtifactFilename(providerType string, version string, filename string) (string, string, bool) {
+	prefix := fmt.Sprintf("%s%s_%s_", artifactPrefix, providerType, version)
+	if !strings.HasPrefix(filename, prefix) || !strings.HasSuffix(filename, artifactExtension) {
+		return "", "", false
+	}
+
+	platform := strings.TrimSuffix(strings.TrimPrefix(filename, prefix), artifactExtension)
+	platformParts := strings.Split(platform, "_")
+	if len(platformParts) != 2 || platformParts[0] == "" || platformParts[1] == "" {
+		return "", "", false
+	}
+
+	return platformParts[0], platformParts[1], true
+}
@@ -0,0 +1,79 @@
+package providerdata
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseArtifactKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		key    string
+		want   Artifact
+		wantOk bool
+	}{
+		{
+			name:   "parse archive key",
+			key:    "black/lodge/1.0.0/terraform-provider-lodge_1.0.0_linux_amd64.zip",
+			want:   Artifact{Namespace: "black", Type: "lodge", Version: "1.0.0", Os: "linux", Arch: "amd64"},
+			wantOk: true,
+		},
+		{
+			name:   "parse type containing underscores",
+			key:    "black/red_room_curtain/1.0.0/terraform-provider-red_room_curtain_1.0.0_darwin_arm64.zip",
+			want:   Artifact{Namespace: "black", Type: "red_room_curtain", Version: "1.0.0", Os: "darwin", Arch: "arm64"},
+			wantOk: true,
+		},
+		{
+			name:   "parse type and version containing hyphens",
+			key:    "black/red-room/1.0.0-rc.1/terraform-provider-red-room_1.0.0-rc.1_windows_386.zip",
+			want:   Artifact{Namespace: "black", Type: "red-room", Version: "1.0.0-rc.1", Os: "windows", Arch: "386"},
+			wantOk: true,
+		},
+		{
+			name:   "ignore archive of other type",
+			key:    "black/lodge/1.0.0/terraform-provider-owl_1.0.0_linux_amd64.zip",
+			wantOk: false,
+		},
+		{
+			name:   "ignore archive of other version",
+			key:    "black/lodge/1.0.0/terraform-provider-lodge_1.0.1_linux_amd64.zip",
+			wantOk: false,
+		},
+		{
+			name:   "ignore archive without terraform-provider prefix",
+			key:    "black/lodge/1.0.0/lodge_1.0.0_linux_amd64.zip",
+			wantOk: false,
+		},
+		{
+			name:   "ignore archive without arch",
+			key:    "black/lodge/1.0.0/terraform-provider-lodge_1.0.0_linux.zip",
+			wantOk: false,
+		},
+		{
+			name:   "ignore files in nested folders",
+			key:    "black/lodge/1.0.0/linux/terraform-provider-lodge_1.0.0_linux_amd64.zip",
+			wantOk: false,
+		},
+		{
+			name:   "ignore other files",
+			key:    "black/lodge/1.0.0/shasum",
+			wantOk: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseArtifactKey(tt.key)
+			if ok != tt.wantOk {
+				t.Errorf("ParseArtifactKey() ok = %v, wantOk %v", ok, tt.wantOk)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseArtifactKey() got = %v, want %v", got, tt.want)
+			}
+			if ok && got.Key() != tt.key {
+				t.Errorf("Key() got = %v, want %v", got.Key(), tt.key)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package providerdata
+
+import "sync"
+
+// cachedResult is a result computed from files of the bucket and the fingerprint of these files.
+type cachedResult[T any] struct {
+	fingerprint string
+	value       T
+}
+
+// cachedResults remembers results by provider and item, so items are only computed again once the fingerprint of
+// their files changes. It is used for the signature verifications and the manifests of versions.
+type cachedResults[T any] struct {
+	lock    sync.Mutex
+	results map[string]map[string]cachedResult[T]
+}
+
+func newCachedResults[T any]() *cachedResults[T] {
+	return &cachedResults[T]{results: make(map[string]map[string]cachedResult[T])}
+}
+
+// lookup returns the result of the item if it was computed with the same fingerprint.
+func (cache *cachedResults[T]) lookup(provider string, item string, fingerprint string) (T, bool) {
+	var empty T
+	if cache == nil {
+		return empty, false
+	}
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	result, ok := cache.results[provider][item]
+	if !ok || result.fingerprint != fingerprint {
+		return empty, false
+	}
+	return result.value, true
+}
+
+// replace stores the results of the current items of provider, dropping the ones of removed items.
+func (cache *cachedResults[T]) replace(provider string, results map[string]cachedResult[T]) {
+	if cache == nil {
+		return
+	}
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	cache.results[provider] = results
+}
@@ -0,0 +1,67 @@
+package providerdata
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"golang.org/x/mod/sumdb/dirhash"
+	"sort"
+	"strings"
+)
+
+// HashesFilename is the optional file of a version listing further hashes of its archives besides the shasum file.
+// The registry writes the h1: hashes of the archives into it when publishing a release. Terraform records h1: hashes
+// in its lock file and cannot compute them from the shasum file.
+const HashesFilename = "hashes"
+
+// HashArchives returns the content of a hashes file listing the h1: hashes of archives, which maps the file names of
+// the archives to the paths of the files containing them. Like Terraform, the hashes are computed from the files
+// contained in the zip-files.
+func HashArchives(archives map[string]string) ([]byte, error) {
+	filenames := make([]string, 0, len(archives))
+	for filename := range archives {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	var content bytes.Buffer
+	for _, filename := range filenames {
+		hash, err := dirhash.HashZip(archives[filename], dirhash.Hash1)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a valid zip-file: %v", filename, err)
+		}
+		fmt.Fprintf(&content, "%s  %s\n", hash, filename)
+	}
+	return content.Bytes(), nil
+}
+
+// ParseHashes parses the content of a hashes file. Every line consists of a hash including its scheme, e.g. h1:,
+// followed by the file name. The result maps each file name to its hashes.
+func ParseHashes(content string) (map[string][]string, error) {
+	hashes := make(map[string][]string)
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d of %s is not of the form '<scheme>:<hash>  <filename>'", lineNumber, HashesFilename)
+		}
+		if scheme, hash, ok := strings.Cut(fields[0], ":"); !ok || scheme == "" || hash == "" {
+			return nil, fmt.Errorf("line %d of %s does not contain a hash with scheme", lineNumber, HashesFilename)
+		}
+
+		hashes[fields[1]] = append(hashes[fields[1]], fields[0])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return hashes, nil
+}
@@ -0,0 +1,97 @@
+package providerdata
+
+import (
+	test_support "github.com/mdreem/s3_terraform_registry/internal/testsupport"
+	"golang.org/x/mod/sumdb/dirhash"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseHashes(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    map[string][]string
+		wantErr bool
+	}{
+		{
+			name:    "parse one line per hash",
+			content: "h1:tJxD0RVk2gAwR1UcUxr4d4s5dUbXSCQv0lTTexHGjRY=  terraform-provider-lodge_1.0.1_linux_amd64.zip\n\nh1:47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=  terraform-provider-lodge_1.0.1_windows_amd64.zip\n",
+			want: map[string][]string{
+				"terraform-provider-lodge_1.0.1_linux_amd64.zip":   {"h1:tJxD0RVk2gAwR1UcUxr4d4s5dUbXSCQv0lTTexHGjRY="},
+				"terraform-provider-lodge_1.0.1_windows_amd64.zip": {"h1:47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="},
+			},
+			wantErr: false,
+		},
+		{
+			name:    "fail on lines without file name",
+			content: "h1:tJxD0RVk2gAwR1UcUxr4d4s5dUbXSCQv0lTTexHGjRY=\n",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "fail on hashes without scheme",
+			content: "caf90169eefa5f807d577486b9f795ab86ae2983c5c20806cff959117e90af18  terraform-provider-lodge_1.0.1_linux_amd64.zip\n",
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseHashes(tt.content)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseHashes() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseHashes() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHashArchives(t *testing.T) {
+	directory := t.TempDir()
+	archives := map[string]string{
+		"terraform-provider-lodge_1.0.1_linux_amd64.zip":   filepath.Join(directory, "linux.zip"),
+		"terraform-provider-lodge_1.0.1_windows_amd64.zip": filepath.Join(directory, "windows.zip"),
+	}
+	contents := map[string]string{
+		"terraform-provider-lodge_1.0.1_linux_amd64.zip":   "linux provider",
+		"terraform-provider-lodge_1.0.1_windows_amd64.zip": "windows provider",
+	}
+	for filename, path := range archives {
+		if err := os.WriteFile(path, test_support.ZipArchive(contents[filename]), 0o600); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+	}
+
+	got, err := HashArchives(archives)
+	if err != nil {
+		t.Fatalf("HashArchives() error = %v", err)
+	}
+	hashes, err := ParseHashes(string(got))
+	if err != nil {
+		t.Fatalf("ParseHashes() of hashed archives error = %v", err)
+	}
+	for filename, content := range contents {
+		content := content
+		want, _ := dirhash.Hash1([]string{"terraform-provider"}, func(string) (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader(content)), nil
+		})
+		if !reflect.DeepEqual(hashes[filename], []string{want}) {
+			t.Errorf("HashArchives() hashes of %s = %v, want %v", filename, hashes[filename], want)
+		}
+	}
+
+	if err := os.WriteFile(archives["terraform-provider-lodge_1.0.1_linux_amd64.zip"], []byte("no zip"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if _, err := HashArchives(archives); err == nil {
+		t.Errorf("HashArchives() of invalid zip-file expected error")
+	}
+}
@@ -0,0 +1,31 @@
+package providerdata
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ManifestFilename is the name of the manifest goreleaser publishes alongside the provider archives.
+const ManifestFilename = "terraform-registry-manifest.json"
+
+// DefaultProtocols are announced for provider versions without a manifest if nothing else is configured.
+var DefaultProtocols = []string{"4.0", "5.0"}
+
+type Manifest struct {
+	Version  int `json:"version"`
+	Metadata struct {
+		ProtocolVersions []string `json:"protocol_versions"`
+	} `json:"metadata"`
+}
+
+func ParseManifest(content string) (Manifest, error) {
+	manifest := Manifest{}
+	if err := json.Unmarshal([]byte(content), &manifest); err != nil {
+		return Manifest{}, fmt.Errorf("unable to parse manifest: %v", err)
+	}
+
+	if len(manifest.Metadata.ProtocolVersions) == 0 {
+		return Manifest{}, fmt.Errorf("manifest does not contain any protocol versions")
+	}
+	return manifest, nil
+}
@@ -0,0 +1,46 @@
+package providerdata
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseManifest(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "parse manifest written by goreleaser",
+			content: `{"version": 1, "metadata": {"protocol_versions": ["5.0", "6.0"]}}`,
+			want:    []string{"5.0", "6.0"},
+			wantErr: false,
+		},
+		{
+			name:    "fail on manifest without protocol versions",
+			content: `{"version": 1, "metadata": {}}`,
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "fail on invalid json",
+			content: `protocol_versions: 6.0`,
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseManifest(tt.content)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseManifest() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got.Metadata.ProtocolVersions, tt.want) {
+				t.Errorf("ParseManifest() got = %v, want %v", got.Metadata.ProtocolVersions, tt.want)
+			}
+		})
+	}
+}
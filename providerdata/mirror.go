@@ -0,0 +1,45 @@
+package providerdata
+
+import (
+	"fmt"
+	"github.com/mdreem/s3_terraform_registry/schema"
+)
+
+// MirrorArchives builds the archives of a version as answered by the provider network mirror protocol from the
+// metadata of the version. Every zip-file listed in the shasum file is an archive, its shasum is announced as zh: hash
+// after the hashes from the hashes file. Without hashes file the h1: hashes are missing, Terraform then records only
+// the zh: hashes in its lock file.
+func (client RegistryClient) MirrorArchives(namespace string, providerType string, version string, metadata schema.VersionMetadata) (schema.MirrorArchives, error) {
+	baseURL := fmt.Sprintf("https://%s/proxy/%s/%s/%s", client.hostname, namespace, providerType, version)
+
+	archives := make(map[string]schema.MirrorArchive)
+	for filename, shaSum := range metadata.ShaSums {
+		os, arch, ok := ParseArtifactFilename(providerType, version, filename)
+		if !ok {
+			continue
+		}
+		archives[fmt.Sprintf("%s_%s", os, arch)] = schema.MirrorArchive{
+			URL:    fmt.Sprintf("%s/%s", baseURL, filename),
+			Hashes: append(append([]string{}, metadata.Hashes[filename]...), "zh:"+shaSum),
+		}
+	}
+	return schema.MirrorArchives{Archives: archives}, nil
+}
+
+func (client RegistryClient) GetMirrorArchives(namespace string, providerType string, version string) (schema.MirrorArchives, error) {
+	metadata, err := client.GetMirrorMetadata(namespace, providerType, version)
+	if err != nil {
+		return schema.MirrorArchives{}, err
+	}
+	return client.MirrorArchives(namespace, providerType, version, metadata)
+}
+
+// MirrorVersions converts the versions of a provider into the versions answered by the provider network mirror
+// protocol.
+func MirrorVersions(providerVersions schema.ProviderVersions) schema.MirrorVersions {
+	versions := make(map[string]schema.MirrorVersion, len(providerVersions.Versions))
+	for _, providerVersion := range providerVersions.Versions {
+		versions[providerVersion.Version] = schema.MirrorVersion{}
+	}
+	return schema.MirrorVersions{Versions: versions}
+}
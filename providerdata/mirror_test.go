@@ -0,0 +1,71 @@
+package providerdata
+
+import (
+	test_support "github.com/mdreem/s3_terraform_registry/internal/testsupport"
+	"github.com/mdreem/s3_terraform_registry/schema"
+	"reflect"
+	"testing"
+)
+
+func TestRegistryClient_MirrorArchives(t *testing.T) {
+	client := RegistryClient{hostname: "twin.peaks"}
+	shaSums, err := ParseShaSums(shaSumFileContent + "0000000000000000000000000000000000000000000000000000000000000000  terraform-provider-lodge_1.0.1_SHA256SUMS\n")
+	if err != nil {
+		t.Fatalf("ParseShaSums() error = %v", err)
+	}
+
+	hashes := map[string][]string{"terraform-provider-lodge_1.0.1_linux_amd64.zip": {"h1:tJxD0RVk2gAwR1UcUxr4d4s5dUbXSCQv0lTTexHGjRY="}}
+
+	got, err := client.MirrorArchives("black", "lodge", "1.0.1", schema.VersionMetadata{ShaSums: shaSums, Hashes: hashes})
+	if err != nil {
+		t.Fatalf("MirrorArchives() error = %v", err)
+	}
+
+	want := schema.MirrorArchives{Archives: map[string]schema.MirrorArchive{
+		"windows_amd64": {
+			URL:    "https://twin.peaks/proxy/black/lodge/1.0.1/terraform-provider-lodge_1.0.1_windows_amd64.zip",
+			Hashes: []string{"zh:340d600392818df2413382dc7d8325c360d83ea49a262d31760348484bbc10b5"},
+		},
+		"linux_amd64": {
+			URL:    "https://twin.peaks/proxy/black/lodge/1.0.1/terraform-provider-lodge_1.0.1_linux_amd64.zip",
+			Hashes: []string{"h1:tJxD0RVk2gAwR1UcUxr4d4s5dUbXSCQv0lTTexHGjRY=", "zh:caf90169eefa5f807d577486b9f795ab86ae2983c5c20806cff959117e90af18"},
+		},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("MirrorArchives() got = %v, want %v", got, want)
+	}
+}
+
+func TestRegistryClient_GetMirrorArchivesWithoutSigningKeys(t *testing.T) {
+	bucket := test_support.NewMemoryBucket(map[string]string{
+		"black/lodge/1.0.1/terraform-provider-lodge_1.0.1_linux_amd64.zip": "archive",
+		"black/lodge/1.0.1/shasum": "caf90169eefa5f807d577486b9f795ab86ae2983c5c20806cff959117e90af18  terraform-provider-lodge_1.0.1_linux_amd64.zip\n",
+		"black/lodge/1.0.1/hashes": "h1:tJxD0RVk2gAwR1UcUxr4d4s5dUbXSCQv0lTTexHGjRY=  terraform-provider-lodge_1.0.1_linux_amd64.zip\n",
+	})
+	client, _ := NewS3Backend(bucket, "twin.peaks")
+
+	if _, err := client.GetDownloadData("black", "lodge", "1.0.1", "linux", "amd64"); err == nil {
+		t.Errorf("GetDownloadData() of a version without signing keys succeeded")
+	}
+
+	got, err := client.GetMirrorArchives("black", "lodge", "1.0.1")
+	if err != nil {
+		t.Fatalf("GetMirrorArchives() error = %v", err)
+	}
+	want := []string{"h1:tJxD0RVk2gAwR1UcUxr4d4s5dUbXSCQv0lTTexHGjRY=", "zh:caf90169eefa5f807d577486b9f795ab86ae2983c5c20806cff959117e90af18"}
+	if !reflect.DeepEqual(got.Archives["linux_amd64"].Hashes, want) {
+		t.Errorf("GetMirrorArchives() got = %v, want hashes %v", got, want)
+	}
+}
+
+func TestMirrorVersions(t *testing.T) {
+	providerVersions := schema.ProviderVersions{
+		ID:       "black/lodge",
+		Versions: []schema.ProviderVersion{{Version: "1.0.0"}, {Version: "1.0.1"}},
+	}
+
+	want := schema.MirrorVersions{Versions: map[string]schema.MirrorVersion{"1.0.0": {}, "1.0.1": {}}}
+	if got := MirrorVersions(providerVersions); !reflect.DeepEqual(got, want) {
+		t.Errorf("MirrorVersions() got = %v, want %v", got, want)
+	}
+}
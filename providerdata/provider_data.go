@@ -2,151 +2,297 @@ package providerdata
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"github.com/mdreem/s3_terraform_registry/logger"
+	"github.com/mdreem/s3_terraform_registry/registryerror"
 	"github.com/mdreem/s3_terraform_registry/s3"
 	"github.com/mdreem/s3_terraform_registry/schema"
-	"regexp"
+	"github.com/mdreem/s3_terraform_registry/semver"
 	"sort"
 	"strings"
+	"time"
 )
 
 type ProviderData interface {
 	ListVersions(namespace string, providerType string) (schema.ProviderVersions, error)
 	GetDownloadData(namespace string, providerType string, version string, os string, arch string) (schema.DownloadData, error)
-	Proxy(namespace string, providerType string, version string, os string) (schema.ProxyResponse, error)
+	// GetMirrorArchives returns the archives of a version for the provider network mirror protocol.
+	GetMirrorArchives(namespace string, providerType string, version string) (schema.MirrorArchives, error)
+	Proxy(namespace string, providerType string, version string, filename string, options s3.GetObjectOptions) (schema.ProxyResponse, error)
+}
+
+// ProviderIndexer builds the versions of a provider from objects which have already been listed, so the whole index
+// can be built from a single listing of the bucket.
+type ProviderIndexer interface {
+	// VersionsFromObjects builds the versions from the listed objects and their ETags, which are nil if the storage
+	// does not list them.
+	VersionsFromObjects(namespace string, providerType string, objects []string, eTags map[string]string) (schema.ProviderVersions, error)
+}
+
+// VersionMetadataSource splits getting download data into fetching the metadata of a version, which can be cached,
+// and building the download data of a platform from it.
+type VersionMetadataSource interface {
+	GetVersionMetadata(namespace string, providerType string, version string) (schema.VersionMetadata, error)
+	// GetMirrorMetadata returns the part of the metadata needed by MirrorArchives, which does not need signing keys.
+	GetMirrorMetadata(namespace string, providerType string, version string) (schema.VersionMetadata, error)
+	DownloadData(namespace string, providerType string, version string, os string, arch string, metadata schema.VersionMetadata) (schema.DownloadData, error)
+	MirrorArchives(namespace string, providerType string, version string, metadata schema.VersionMetadata) (schema.MirrorArchives, error)
+}
+
+// Backend is the provider data read from storage, which the cache is built upon.
+type Backend interface {
+	ProviderData
+	ProviderIndexer
+	VersionMetadataSource
 }
 
 type RegistryClient struct {
-	bucket   s3.BucketReaderWriter
-	hostname string
+	bucket                s3.BucketReaderWriter
+	hostname              string
+	defaultProtocols      []string
+	presigner             s3.Presigner
+	presignExpiry         time.Duration
+	signatureVerification SignatureVerification
+	// verifications are the results of verifying signatures, keyed by the fingerprint of the files of a version.
+	verifications *cachedResults[error]
+	// manifests are the manifests of versions, keyed by the ETags of the manifest files.
+	manifests *cachedResults[parsedManifest]
 }
 
-func NewS3Backend(bucket s3.BucketReaderWriter, hostname string) (RegistryClient, error) {
-	return RegistryClient{
-		bucket:   bucket,
-		hostname: hostname,
-	}, nil
+type Option func(client *RegistryClient)
+
+// WithDefaultProtocols sets the protocols announced for provider versions which do not contain a manifest.
+func WithDefaultProtocols(protocols []string) Option {
+	return func(client *RegistryClient) {
+		client.defaultProtocols = protocols
+	}
+}
+
+// WithSignatureVerification verifies the signature of every version while indexing it and treats versions whose
+// signature does not verify according to verification.
+func WithSignatureVerification(verification SignatureVerification) Option {
+	return func(client *RegistryClient) {
+		client.signatureVerification = verification
+	}
+}
+
+// WithPresignedDownloads redirects downloads to URLs presigned by presigner which expire after expiry instead of
+// proxying the files. Files are proxied if presigning fails.
+func WithPresignedDownloads(presigner s3.Presigner, expiry time.Duration) Option {
+	return func(client *RegistryClient) {
+		client.presigner = presigner
+		client.presignExpiry = expiry
+	}
+}
+
+func NewS3Backend(bucket s3.BucketReaderWriter, hostname string, options ...Option) (RegistryClient, error) {
+	client := RegistryClient{
+		bucket:                bucket,
+		hostname:              hostname,
+		defaultProtocols:      DefaultProtocols,
+		signatureVerification: VerifySignaturesOff,
+		verifications:         newCachedResults[error](),
+		manifests:             newCachedResults[parsedManifest](),
+	}
+	for _, option := range options {
+		option(&client)
+	}
+	return client, nil
 }
 
 func (client RegistryClient) ListVersions(namespace string, providerType string) (schema.ProviderVersions, error) {
-	objects, err := client.bucket.ListObjects()
+	prefix := fmt.Sprintf("%s/%s/", namespace, providerType)
+	objects, eTags, err := s3.ListObjectsWithETags(client.bucket, prefix)
 	if err != nil {
 		logger.Sugar.Errorw("an error occurred when listing objects in S3", "error", err)
 		return schema.ProviderVersions{}, err
 	}
+	if len(objects) == 0 {
+		return schema.ProviderVersions{}, registryerror.NotFound(nil, "provider %s/%s does not exist", namespace, providerType)
+	}
 
-	r := regexp.MustCompile(`^(?P<namespace>[^/]*)/(?P<type>[^/]*)/(?P<version>[^/]*)/(?P<name>[^_]*)_(?P<version_file>[^_]*)_(?P<os>[^_]*)_(?P<arch>[^_]*).zip$`)
-	names := r.SubexpNames()
+	return client.VersionsFromObjects(namespace, providerType, objects, eTags)
+}
 
+// VersionsFromObjects builds the versions of the provider from the keys in objects. Keys of other providers are
+// ignored. Only the manifests of the versions and, if signatures are verified, their signature files are fetched from
+// the bucket. eTags are the ETags listed along with objects, used to reuse the manifests and signature verifications
+// of unchanged versions. They are nil if the storage does not list them.
+func (client RegistryClient) VersionsFromObjects(namespace string, providerType string, objects []string, eTags map[string]string) (schema.ProviderVersions, error) {
+	prefix := fmt.Sprintf("%s/%s/", namespace, providerType)
 	versions := make(map[string][]schema.Platform)
+	parsedVersions := make(map[string]semver.Version)
+	skippedVersions := make(map[string]string)
+	manifests := make(map[string]bool)
 
 	for _, item := range objects {
-		if r.MatchString(item) {
-			result := r.FindAllStringSubmatch(item, -1)
-			matches := map[string]string{}
-			for i, n := range result[0] {
-				matches[names[i]] = n
-			}
+		if strings.HasSuffix(item, "/"+ManifestFilename) {
+			manifests[item] = true
+			continue
+		}
 
-			if matches["name"] == "" {
-				continue
-			}
-			if matches["namespace"] != namespace {
-				continue
-			}
-			if matches["type"] != providerType {
+		artifact, ok := ParseArtifactKey(item)
+		if !ok {
+			logger.Sugar.Debugw("list versions: ignoring", "item", item)
+			continue
+		}
+		if artifact.Namespace != namespace || artifact.Type != providerType {
+			continue
+		}
+
+		version := artifact.Version
+		if _, ok := parsedVersions[version]; !ok {
+			parsedVersion, err := semver.Parse(version)
+			if err != nil {
+				logger.Sugar.Warnw("list versions: skipping invalid version", "item", item, "error", err)
+				skippedVersions[version] = "not a valid semantic version"
 				continue
 			}
+			parsedVersions[version] = parsedVersion
+		}
 
-			logger.Sugar.Infow("list versions: adding", "item", item)
+		logger.Sugar.Infow("list versions: adding", "item", item)
 
-			platforms := versions[matches["version"]]
-			platforms = append(platforms, schema.Platform{
-				Os:   matches["os"],
-				Arch: matches["arch"],
-			})
-			versions[matches["version"]] = platforms
-		}
+		platforms := versions[version]
+		platforms = append(platforms, schema.Platform{
+			Os:   artifact.Os,
+			Arch: artifact.Arch,
+		})
+		versions[version] = platforms
 	}
 
+	versionNames := make([]string, 0, len(versions))
+	for version := range versions {
+		versionNames = append(versionNames, version)
+	}
+	sort.Strings(versionNames)
+	verified, signatureWarnings, err := client.verifySignatures(namespace, providerType, versionNames, eTags)
+	if err != nil {
+		return schema.ProviderVersions{}, err
+	}
+
+	manifestResults := make(map[string]cachedResult[parsedManifest])
 	providerVersions := make([]schema.ProviderVersion, 0)
 	for version, versionData := range versions {
+		if !verified[version] {
+			continue
+		}
+
+		protocols := client.fallbackProtocols()
+		if manifestLocation := prefix + version + "/" + ManifestFilename; manifests[manifestLocation] {
+			manifest, err := client.cachedManifest(namespace+"/"+providerType, manifestLocation, eTags[manifestLocation], manifestResults)
+			if errors.Is(err, registryerror.ErrUpstream) {
+				return schema.ProviderVersions{}, err
+			}
+			if err != nil {
+				logger.Sugar.Warnw("list versions: skipping version with invalid manifest", "file", manifestLocation, "error", err)
+				skippedVersions[version] = fmt.Sprintf("invalid %s: %v", ManifestFilename, err)
+				continue
+			}
+			protocols = manifest.Metadata.ProtocolVersions
+		}
+
 		providerVersion := schema.ProviderVersion{
 			Version:   version,
-			Protocols: []string{"4.0", "5.0"},
+			Protocols: protocols,
 			Platforms: versionData,
 		}
 		providerVersions = append(providerVersions, providerVersion)
 	}
+	client.manifests.replace(namespace+"/"+providerType, manifestResults)
 
 	sort.Slice(providerVersions, func(i, j int) bool {
-		return providerVersions[i].Version < providerVersions[j].Version
+		result := parsedVersions[providerVersions[i].Version].Compare(parsedVersions[providerVersions[j].Version])
+		if result == 0 {
+			// versions only differing in build metadata have the same precedence
+			return providerVersions[i].Version < providerVersions[j].Version
+		}
+		return result < 0
 	})
 
 	return schema.ProviderVersions{
 		ID:       fmt.Sprintf("%s/%s", namespace, providerType),
 		Versions: providerVersions,
-		Warnings: nil,
+		Warnings: skippedVersionWarnings(namespace, providerType, skippedVersions, signatureWarnings),
 	}, nil
 }
 
-func (client RegistryClient) GetDownloadData(namespace string, providerType string, version string, os string, arch string) (schema.DownloadData, error) {
-	basePath := fmt.Sprintf("%s/%s/%s", namespace, providerType, version)
-	baseURL := fmt.Sprintf("https://%s/proxy/%s", client.hostname, basePath)
+func skippedVersionWarnings(namespace string, providerType string, skippedVersions map[string]string, otherWarnings []string) []string {
+	if len(skippedVersions) == 0 && len(otherWarnings) == 0 {
+		return nil
+	}
 
-	logger.Sugar.Debugw("getting download data with", "basePath", basePath, "baseURL", baseURL)
+	warnings := make([]string, 0, len(skippedVersions)+len(otherWarnings))
+	warnings = append(warnings, otherWarnings...)
+	for version, reason := range skippedVersions {
+		warnings = append(warnings, fmt.Sprintf("skipped %s/%s/%s: %s", namespace, providerType, version, reason))
+	}
+	sort.Strings(warnings)
+	return warnings
+}
 
-	shaSum, err := client.fetchShaSum(basePath)
+func (client RegistryClient) GetDownloadData(namespace string, providerType string, version string, os string, arch string) (schema.DownloadData, error) {
+	metadata, err := client.GetVersionMetadata(namespace, providerType, version)
 	if err != nil {
 		return schema.DownloadData{}, err
 	}
+	return client.DownloadData(namespace, providerType, version, os, arch, metadata)
+}
 
-	keyIDFileLocation := fmt.Sprintf("%s/key_id", basePath)
-	keyID, err := client.fetchObjectAsString(keyIDFileLocation)
+// fetchProtocols reads the protocols from the manifest in basePath, falling back to the default protocols if there
+// is no manifest.
+func (client RegistryClient) fetchProtocols(basePath string) ([]string, error) {
+	manifestLocation := fmt.Sprintf("%s/%s", basePath, ManifestFilename)
+
+	manifestFile, err := client.fetchObjectAsString(manifestLocation)
+	if errors.Is(err, registryerror.ErrNotFound) {
+		logger.Sugar.Debugw("no manifest found, using default protocols", "file", manifestLocation)
+		return client.fallbackProtocols(), nil
+	}
 	if err != nil {
-		return schema.DownloadData{}, err
+		return nil, err
 	}
 
-	keyfileLocation := fmt.Sprintf("%s/keyfile", basePath)
-	gpgPublicKey, err := client.fetchObjectAsString(keyfileLocation)
+	manifest, err := ParseManifest(manifestFile)
 	if err != nil {
-		return schema.DownloadData{}, err
+		return nil, fmt.Errorf("invalid %s: %v", manifestLocation, err)
 	}
+	return manifest.Metadata.ProtocolVersions, nil
+}
 
-	filename := fmt.Sprintf("terraform-provider-%s_%s_%s_%s.zip", providerType, version, os, arch)
-	return schema.DownloadData{
-		Protocols:           []string{"4.0", "5.0"},
-		Os:                  os,
-		Arch:                arch,
-		Filename:            filename,
-		DownloadURL:         fmt.Sprintf("%s/%s", baseURL, filename),
-		ShasumsURL:          fmt.Sprintf("%s/shasum", baseURL),
-		ShasumsSignatureURL: fmt.Sprintf("%s/shasum.sig", baseURL),
-		Shasum:              shaSum,
-		SigningKeys: struct {
-			GpgPublicKeys []schema.GpgPublicKey `json:"gpg_public_keys"`
-		}{
-			[]schema.GpgPublicKey{
-				{
-					KeyID:      keyID,
-					ASCIIArmor: gpgPublicKey,
-				},
-			},
-		},
-	}, nil
+// parsedManifest is a manifest file of a version parsed with ParseManifest.
+type parsedManifest struct {
+	manifest Manifest
+	err      error
 }
 
-func (client RegistryClient) fetchShaSum(basePath string) (string, error) {
-	shaSumLocation := fmt.Sprintf("%s/shasum", basePath)
-	logger.Sugar.Debugw("fetching signature file", "file", shaSumLocation)
+// cachedManifest returns the manifest in manifestLocation. The manifest parsed by a previous refresh is reused while
+// eTag stays the same, manifests with an ETag are added to results. Failures to read the manifest are not cached.
+func (client RegistryClient) cachedManifest(provider string, manifestLocation string, eTag string, results map[string]cachedResult[parsedManifest]) (Manifest, error) {
+	parsed, ok := client.manifests.lookup(provider, manifestLocation, eTag)
+	if !ok || eTag == "" {
+		manifestFile, err := client.fetchObjectAsString(manifestLocation)
+		if err != nil {
+			return Manifest{}, err
+		}
+		manifest, err := ParseManifest(manifestFile)
+		parsed = parsedManifest{manifest: manifest, err: err}
+	} else {
+		logger.Sugar.Debugw("manifest unchanged since last refresh", "file", manifestLocation)
+	}
 
-	shaSumFile, err := client.fetchObjectAsString(shaSumLocation)
-	if err != nil {
-		return "", err
+	if eTag != "" {
+		results[manifestLocation] = cachedResult[parsedManifest]{fingerprint: eTag, value: parsed}
+	}
+	return parsed.manifest, parsed.err
+}
+
+func (client RegistryClient) fallbackProtocols() []string {
+	if len(client.defaultProtocols) > 0 {
+		return client.defaultProtocols
 	}
-	shaSum := strings.Split(shaSumFile, " ")[0]
-	return shaSum, nil
+	return DefaultProtocols
 }
 
 func (client RegistryClient) fetchObjectAsString(objectLocation string) (string, error) {
@@ -154,6 +300,11 @@ func (client RegistryClient) fetchObjectAsString(objectLocation string) (string,
 	if err != nil {
 		return "", err
 	}
+	defer func() {
+		if err := object.Body.Close(); err != nil {
+			logger.Sugar.Warnw("unable to close object", "key", objectLocation, "error", err)
+		}
+	}()
 
 	buf := new(bytes.Buffer)
 	_, err = buf.ReadFrom(object.Body)
@@ -163,18 +314,49 @@ func (client RegistryClient) fetchObjectAsString(objectLocation string) (string,
 	return buf.String(), nil
 }
 
-func (client RegistryClient) Proxy(namespace string, providerType string, version string, filename string) (schema.ProxyResponse, error) {
+func (client RegistryClient) Proxy(namespace string, providerType string, version string, filename string, options s3.GetObjectOptions) (schema.ProxyResponse, error) {
 	basePath := fmt.Sprintf("%s/%s/%s", namespace, providerType, version)
 	logger.Sugar.Infow("proxying file file", "file", fmt.Sprintf("%s/%s", basePath, filename))
 
-	object, err := client.bucket.GetObject(fmt.Sprintf("%s/%s", basePath, filename))
+	if !isDownloadableFile(providerType, version, filename) {
+		return schema.ProxyResponse{}, registryerror.NotFound(nil, "%s is not a downloadable file of %s", filename, basePath)
+	}
+
+	key := fmt.Sprintf("%s/%s", basePath, filename)
+	if client.presigner != nil {
+		presignedURL, err := client.presigner.PresignGetObject(key, client.presignExpiry)
+		if err == nil {
+			return schema.ProxyResponse{RedirectURL: presignedURL}, nil
+		}
+		logger.Sugar.Warnw("unable to presign file, proxying it instead", "file", key, "error", err)
+	}
+
+	object, err := client.bucket.GetObjectWithOptions(key, options)
 	if err != nil {
 		return schema.ProxyResponse{}, err
 	}
 
+	return ProxyResponse(object), nil
+}
+
+// ProxyResponse converts an object of the bucket into the response of a proxy request.
+func ProxyResponse(object s3.BucketObject) schema.ProxyResponse {
 	return schema.ProxyResponse{
 		Body:          object.Body,
 		ContentLength: object.ContentLength,
 		ContentType:   object.ContentType,
-	}, nil
+		ETag:          object.ETag,
+		LastModified:  object.LastModified,
+		ContentRange:  object.ContentRange,
+		NotModified:   object.NotModified,
+	}
+}
+
+// isDownloadableFile checks whether filename is one of the files the download data points to.
+func isDownloadableFile(providerType string, version string, filename string) bool {
+	if filename == "shasum" || filename == "shasum.sig" {
+		return true
+	}
+	_, _, ok := ParseArtifactFilename(providerType, version, filename)
+	return ok
 }
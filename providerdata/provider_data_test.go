@@ -1,13 +1,21 @@
 package providerdata
 
 import (
+	"errors"
 	test_support "github.com/mdreem/s3_terraform_registry/internal/testsupport"
+	"github.com/mdreem/s3_terraform_registry/registryerror"
 	"github.com/mdreem/s3_terraform_registry/s3"
 	"github.com/mdreem/s3_terraform_registry/schema"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
+const shaSumFileContent = `340d600392818df2413382dc7d8325c360d83ea49a262d31760348484bbc10b5  terraform-provider-lodge_1.0.1_windows_amd64.zip
+caf90169eefa5f807d577486b9f795ab86ae2983c5c20806cff959117e90af18  terraform-provider-lodge_1.0.1_linux_amd64.zip
+`
+
 func TestRegistryClient_GetDownloadData(t *testing.T) {
 	type fields struct {
 		bucket       s3.BucketReaderWriter
@@ -34,7 +42,7 @@ func TestRegistryClient_GetDownloadData(t *testing.T) {
 			fields: fields{
 				bucket: test_support.NewTestBucketWithObjects([]string{}, map[string]s3.BucketObject{
 					"black/lodge/1.0.1/shasum": {
-						Body:          test_support.CreateReaderFor("315 coffee"),
+						Body:          test_support.CreateReaderFor(shaSumFileContent),
 						ContentLength: 0,
 						ContentType:   "",
 					},
@@ -68,7 +76,7 @@ func TestRegistryClient_GetDownloadData(t *testing.T) {
 				DownloadURL:         "https://twin.peaks/proxy/black/lodge/1.0.1/terraform-provider-lodge_1.0.1_linux_amd64.zip",
 				ShasumsURL:          "https://twin.peaks/proxy/black/lodge/1.0.1/shasum",
 				ShasumsSignatureURL: "https://twin.peaks/proxy/black/lodge/1.0.1/shasum.sig",
-				Shasum:              "315",
+				Shasum:              "caf90169eefa5f807d577486b9f795ab86ae2983c5c20806cff959117e90af18",
 				SigningKeys: struct {
 					GpgPublicKeys []schema.GpgPublicKey `json:"gpg_public_keys"`
 				}{
@@ -85,6 +93,76 @@ func TestRegistryClient_GetDownloadData(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "get download data with protocols from manifest",
+			fields: fields{
+				bucket: test_support.NewTestBucketWithObjects([]string{}, map[string]s3.BucketObject{
+					"black/lodge/1.0.1/shasum": {
+						Body: test_support.CreateReaderFor(shaSumFileContent),
+					},
+					"black/lodge/1.0.1/key_id": {
+						Body: test_support.CreateReaderFor("315"),
+					},
+					"black/lodge/1.0.1/keyfile": {
+						Body: test_support.CreateReaderFor("Great Northern Hotel Room Key"),
+					},
+					"black/lodge/1.0.1/terraform-registry-manifest.json": {
+						Body: test_support.CreateReaderFor(`{"version": 1, "metadata": {"protocol_versions": ["6.0"]}}`),
+					},
+				}),
+				hostname: "twin.peaks",
+			},
+			args: args{
+				namespace:    "black",
+				providerType: "lodge",
+				version:      "1.0.1",
+				os:           "linux",
+				arch:         "amd64",
+			},
+			want: schema.DownloadData{
+				Protocols:           []string{"6.0"},
+				Os:                  "linux",
+				Arch:                "amd64",
+				Filename:            "terraform-provider-lodge_1.0.1_linux_amd64.zip",
+				DownloadURL:         "https://twin.peaks/proxy/black/lodge/1.0.1/terraform-provider-lodge_1.0.1_linux_amd64.zip",
+				ShasumsURL:          "https://twin.peaks/proxy/black/lodge/1.0.1/shasum",
+				ShasumsSignatureURL: "https://twin.peaks/proxy/black/lodge/1.0.1/shasum.sig",
+				Shasum:              "caf90169eefa5f807d577486b9f795ab86ae2983c5c20806cff959117e90af18",
+				SigningKeys: struct {
+					GpgPublicKeys []schema.GpgPublicKey `json:"gpg_public_keys"`
+				}{
+					GpgPublicKeys: []schema.GpgPublicKey{
+						{
+							KeyID:      "315",
+							ASCIIArmor: "Great Northern Hotel Room Key",
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "fail if shasum file contains no entry for platform",
+			fields: fields{
+				bucket: test_support.NewTestBucketWithObjects([]string{}, map[string]s3.BucketObject{
+					"black/lodge/1.0.1/shasum": {
+						Body:          test_support.CreateReaderFor(shaSumFileContent),
+						ContentLength: 0,
+						ContentType:   "",
+					},
+				}),
+				hostname: "twin.peaks",
+			},
+			args: args{
+				namespace:    "black",
+				providerType: "lodge",
+				version:      "1.0.1",
+				os:           "darwin",
+				arch:         "arm64",
+			},
+			want:    schema.DownloadData{},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -124,9 +202,9 @@ func TestRegistryClient_ListVersions(t *testing.T) {
 			name: "list versions based on S3 content",
 			fields: fields{
 				bucket: test_support.NewTestBucket([]string{
-					"black/lodge/1.0.0/provider_1.0.0_linux_amd64.zip",
-					"black/lodge/1.0.1/provider_1.0.1_linux_amd64.zip",
-					"black/lodge/1.0.1/provider_1.0.1_windows_amd64.zip",
+					"black/lodge/1.0.0/terraform-provider-lodge_1.0.0_linux_amd64.zip",
+					"black/lodge/1.0.1/terraform-provider-lodge_1.0.1_linux_amd64.zip",
+					"black/lodge/1.0.1/terraform-provider-lodge_1.0.1_windows_amd64.zip",
 				}),
 				hostname: "twin.peaks",
 			},
@@ -163,6 +241,112 @@ func TestRegistryClient_ListVersions(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "order versions by semantic version precedence and skip invalid versions",
+			fields: fields{
+				bucket: test_support.NewTestBucket([]string{
+					"black/lodge/1.10.0/terraform-provider-lodge_1.10.0_linux_amd64.zip",
+					"black/lodge/1.9.0/terraform-provider-lodge_1.9.0_linux_amd64.zip",
+					"black/lodge/1.10.0-rc.1/terraform-provider-lodge_1.10.0-rc.1_linux_amd64.zip",
+					"black/lodge/latest/terraform-provider-lodge_latest_linux_amd64.zip",
+					"black/lodge/latest/terraform-provider-lodge_latest_windows_amd64.zip",
+				}),
+				hostname: "twin.peaks",
+			},
+			args: args{
+				namespace:    "black",
+				providerType: "lodge",
+			},
+			want: schema.ProviderVersions{
+				ID: "black/lodge",
+				Versions: []schema.ProviderVersion{
+					{
+						Version:   "1.9.0",
+						Protocols: []string{"4.0", "5.0"},
+						Platforms: []schema.Platform{{Os: "linux", Arch: "amd64"}},
+					},
+					{
+						Version:   "1.10.0-rc.1",
+						Protocols: []string{"4.0", "5.0"},
+						Platforms: []schema.Platform{{Os: "linux", Arch: "amd64"}},
+					},
+					{
+						Version:   "1.10.0",
+						Protocols: []string{"4.0", "5.0"},
+						Platforms: []schema.Platform{{Os: "linux", Arch: "amd64"}},
+					},
+				},
+				Warnings: []string{"skipped black/lodge/latest: not a valid semantic version"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "list versions of type containing underscores",
+			fields: fields{
+				bucket: test_support.NewTestBucket([]string{
+					"black/red_room/1.0.0/terraform-provider-red_room_1.0.0_linux_amd64.zip",
+					"black/red_room/1.0.0/terraform-provider-red_room_1.0.0_darwin_arm64.zip",
+				}),
+				hostname: "twin.peaks",
+			},
+			args: args{
+				namespace:    "black",
+				providerType: "red_room",
+			},
+			want: schema.ProviderVersions{
+				ID: "black/red_room",
+				Versions: []schema.ProviderVersion{
+					{
+						Version:   "1.0.0",
+						Protocols: []string{"4.0", "5.0"},
+						Platforms: []schema.Platform{{Os: "darwin", Arch: "arm64"}, {Os: "linux", Arch: "amd64"}},
+					},
+				},
+				Warnings: nil,
+			},
+			wantErr: false,
+		},
+		{
+			name: "read protocols from manifest",
+			fields: fields{
+				bucket: test_support.NewTestBucketWithObjects([]string{
+					"black/lodge/1.0.0/terraform-provider-lodge_1.0.0_linux_amd64.zip",
+					"black/lodge/2.0.0/terraform-provider-lodge_2.0.0_linux_amd64.zip",
+					"black/lodge/2.0.0/terraform-registry-manifest.json",
+					"black/lodge/2.0.1/terraform-provider-lodge_2.0.1_linux_amd64.zip",
+					"black/lodge/2.0.1/terraform-registry-manifest.json",
+				}, map[string]s3.BucketObject{
+					"black/lodge/2.0.0/terraform-registry-manifest.json": {
+						Body: test_support.CreateReaderFor(`{"version": 1, "metadata": {"protocol_versions": ["6.0"]}}`),
+					},
+					"black/lodge/2.0.1/terraform-registry-manifest.json": {
+						Body: test_support.CreateReaderFor(`{"version": 1}`),
+					},
+				}),
+				hostname: "twin.peaks",
+			},
+			args: args{
+				namespace:    "black",
+				providerType: "lodge",
+			},
+			want: schema.ProviderVersions{
+				ID: "black/lodge",
+				Versions: []schema.ProviderVersion{
+					{
+						Version:   "1.0.0",
+						Protocols: []string{"4.0", "5.0"},
+						Platforms: []schema.Platform{{Os: "linux", Arch: "amd64"}},
+					},
+					{
+						Version:   "2.0.0",
+						Protocols: []string{"6.0"},
+						Platforms: []schema.Platform{{Os: "linux", Arch: "amd64"}},
+					},
+				},
+				Warnings: []string{"skipped black/lodge/2.0.1: invalid terraform-registry-manifest.json: manifest does not contain any protocol versions"},
+			},
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -182,12 +366,79 @@ func TestRegistryClient_ListVersions(t *testing.T) {
 	}
 }
 
+func TestRegistryClient_GetMirrorMetadataReportsInvalidFilesAsUpstreamErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		objects map[string]string
+	}{
+		{
+			name:    "invalid shasum file",
+			objects: map[string]string{"black/lodge/1.0.1/shasum": "not a shasum file"},
+		},
+		{
+			name: "invalid hashes file",
+			objects: map[string]string{
+				"black/lodge/1.0.1/shasum": shaSumFileContent,
+				"black/lodge/1.0.1/hashes": "no hash scheme  terraform-provider-lodge_1.0.1_linux_amd64.zip",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, _ := NewS3Backend(test_support.NewMemoryBucket(tt.objects), "twin.peaks")
+
+			_, err := client.GetMirrorMetadata("black", "lodge", "1.0.1")
+			if !errors.Is(err, registryerror.ErrUpstream) {
+				t.Errorf("GetMirrorMetadata() error = %v, want upstream error", err)
+			}
+		})
+	}
+}
+
+func TestRegistryClient_VersionsFromObjectsReusesManifests(t *testing.T) {
+	const manifestKey = "black/lodge/1.0.0/" + ManifestFilename
+	bucket := &readCountingBucket{MemoryBucket: test_support.NewMemoryBucket(map[string]string{
+		"black/lodge/1.0.0/terraform-provider-lodge_1.0.0_linux_amd64.zip": "zip",
+		manifestKey: `{"version": 1, "metadata": {"protocol_versions": ["5.0"]}}`,
+	})}
+	client, _ := NewS3Backend(bucket, "twin.peaks")
+
+	protocols := func() []string {
+		keys, eTags, err := s3.ListObjectsWithETags(bucket, "")
+		if err != nil {
+			t.Fatalf("ListObjectsWithETags() error = %v", err)
+		}
+		bucket.reads = make(map[string]int)
+		got, err := client.VersionsFromObjects("black", "lodge", keys, eTags)
+		if err != nil {
+			t.Fatalf("VersionsFromObjects() error = %v", err)
+		}
+		if len(got.Versions) != 1 {
+			t.Fatalf("VersionsFromObjects() versions = %v, want 1.0.0", got.Versions)
+		}
+		return got.Versions[0].Protocols
+	}
+
+	if got := protocols(); !reflect.DeepEqual(got, []string{"5.0"}) {
+		t.Errorf("VersionsFromObjects() protocols = %v, want [5.0]", got)
+	}
+	if got := protocols(); !reflect.DeepEqual(got, []string{"5.0"}) || bucket.reads[manifestKey] != 0 {
+		t.Errorf("VersionsFromObjects() protocols = %v, read unchanged manifest %d times", got, bucket.reads[manifestKey])
+	}
+
+	_ = bucket.PutObject(manifestKey, strings.NewReader(`{"version": 1, "metadata": {"protocol_versions": ["6.0"]}}`))
+	if got := protocols(); !reflect.DeepEqual(got, []string{"6.0"}) || bucket.reads[manifestKey] != 1 {
+		t.Errorf("VersionsFromObjects() protocols = %v, read changed manifest %d times", got, bucket.reads[manifestKey])
+	}
+}
+
 func TestRegistryClient_Proxy(t *testing.T) {
 	type fields struct {
 		bucket       s3.BucketReaderWriter
 		hostname     string
 		gpgPublicKey string
 		keyID        string
+		presigner    s3.Presigner
 	}
 	type args struct {
 		namespace    string
@@ -208,7 +459,7 @@ func TestRegistryClient_Proxy(t *testing.T) {
 			name: "proxy returns file",
 			fields: fields{
 				bucket: test_support.NewTestBucketWithObjects([]string{}, map[string]s3.BucketObject{
-					"black/lodge/1.0.1/provider_1.0.1_linux_amd64.zip": {
+					"black/lodge/1.0.1/terraform-provider-lodge_1.0.1_linux_amd64.zip": {
 						Body:          test_support.CreateReaderFor("315 coffee"),
 						ContentLength: 253,
 						ContentType:   "Lodge Response",
@@ -224,7 +475,7 @@ func TestRegistryClient_Proxy(t *testing.T) {
 				version:      "1.0.1",
 				os:           "linux",
 				arch:         "amd64",
-				filename:     "provider_1.0.1_linux_amd64.zip",
+				filename:     "terraform-provider-lodge_1.0.1_linux_amd64.zip",
 			},
 			want: schema.ProxyResponse{
 				Body:          test_support.CreateReaderFor("315 coffee"),
@@ -233,14 +484,77 @@ func TestRegistryClient_Proxy(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "proxy refuses files which are not part of the download data",
+			fields: fields{
+				bucket: test_support.NewTestBucketWithObjects([]string{}, map[string]s3.BucketObject{
+					"black/lodge/1.0.1/keyfile": {
+						Body: test_support.CreateReaderFor("Great Northern Hotel Room Key"),
+					},
+				}),
+				hostname: "twin.peaks",
+			},
+			args: args{
+				namespace:    "black",
+				providerType: "lodge",
+				version:      "1.0.1",
+				filename:     "keyfile",
+			},
+			want:    schema.ProxyResponse{},
+			wantErr: true,
+		},
+		{
+			name: "proxy redirects to presigned URL",
+			fields: fields{
+				bucket:    test_support.NewTestBucketWithObjects([]string{}, nil),
+				hostname:  "twin.peaks",
+				presigner: test_support.TestPresigner{},
+			},
+			args: args{
+				namespace:    "black",
+				providerType: "lodge",
+				version:      "1.0.1",
+				filename:     "terraform-provider-lodge_1.0.1_linux_amd64.zip",
+			},
+			want: schema.ProxyResponse{
+				RedirectURL: "https://s3.twin.peaks/black/lodge/1.0.1/terraform-provider-lodge_1.0.1_linux_amd64.zip?expires=300",
+			},
+			wantErr: false,
+		},
+		{
+			name: "proxy returns file if presigning fails",
+			fields: fields{
+				bucket: test_support.NewTestBucketWithObjects([]string{}, map[string]s3.BucketObject{
+					"black/lodge/1.0.1/shasum": {
+						Body:          test_support.CreateReaderFor("shasums"),
+						ContentLength: 7,
+					},
+				}),
+				hostname:  "twin.peaks",
+				presigner: test_support.TestPresigner{Err: errors.New("no credentials")},
+			},
+			args: args{
+				namespace:    "black",
+				providerType: "lodge",
+				version:      "1.0.1",
+				filename:     "shasum",
+			},
+			want: schema.ProxyResponse{
+				Body:          test_support.CreateReaderFor("shasums"),
+				ContentLength: 7,
+			},
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			client := RegistryClient{
-				bucket:   tt.fields.bucket,
-				hostname: tt.fields.hostname,
+				bucket:        tt.fields.bucket,
+				hostname:      tt.fields.hostname,
+				presigner:     tt.fields.presigner,
+				presignExpiry: 5 * time.Minute,
 			}
-			got, err := client.Proxy(tt.args.namespace, tt.args.providerType, tt.args.version, tt.args.filename)
+			got, err := client.Proxy(tt.args.namespace, tt.args.providerType, tt.args.version, tt.args.filename, s3.GetObjectOptions{})
 			if (err != nil) != tt.wantErr {
 				t.Errorf("proxy() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -0,0 +1,43 @@
+package providerdata
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// ParseShaSums parses the content of a SHA256SUMS file as written by sha256sum or goreleaser. Every line consists of
+// the hex encoded hash followed by the file name. The result maps each file name to its hash.
+func ParseShaSums(content string) (map[string]string, error) {
+	shaSums := make(map[string]string)
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d of shasum file is not of the form '<hash>  <filename>'", lineNumber)
+		}
+
+		shaSum := strings.ToLower(fields[0])
+		if decoded, err := hex.DecodeString(shaSum); err != nil || len(decoded) != 32 {
+			return nil, fmt.Errorf("line %d of shasum file does not contain a valid sha256 hash", lineNumber)
+		}
+
+		// sha256sum marks files hashed in binary mode with a leading asterisk
+		filename := strings.TrimPrefix(fields[1], "*")
+		shaSums[filename] = shaSum
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return shaSums, nil
+}
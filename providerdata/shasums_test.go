@@ -0,0 +1,57 @@
+package providerdata
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseShaSums(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name:    "parse one line per file",
+			content: shaSumFileContent,
+			want: map[string]string{
+				"terraform-provider-lodge_1.0.1_windows_amd64.zip": "340d600392818df2413382dc7d8325c360d83ea49a262d31760348484bbc10b5",
+				"terraform-provider-lodge_1.0.1_linux_amd64.zip":   "caf90169eefa5f807d577486b9f795ab86ae2983c5c20806cff959117e90af18",
+			},
+			wantErr: false,
+		},
+		{
+			name:    "accept binary mode marker, upper case hashes and empty lines",
+			content: "\nCAF90169EEFA5F807D577486B9F795AB86AE2983C5C20806CFF959117E90AF18 *terraform-provider-lodge_1.0.1_linux_amd64.zip\n\n",
+			want: map[string]string{
+				"terraform-provider-lodge_1.0.1_linux_amd64.zip": "caf90169eefa5f807d577486b9f795ab86ae2983c5c20806cff959117e90af18",
+			},
+			wantErr: false,
+		},
+		{
+			name:    "fail on lines without file name",
+			content: "caf90169eefa5f807d577486b9f795ab86ae2983c5c20806cff959117e90af18\n",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "fail on invalid hashes",
+			content: "315  terraform-provider-lodge_1.0.1_linux_amd64.zip\n",
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseShaSums(tt.content)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseShaSums() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseShaSums() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
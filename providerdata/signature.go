@@ -0,0 +1,210 @@
+package providerdata
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/mdreem/s3_terraform_registry/logger"
+	"github.com/mdreem/s3_terraform_registry/pgp"
+	"github.com/mdreem/s3_terraform_registry/registryerror"
+	"github.com/mdreem/s3_terraform_registry/schema"
+	"sort"
+	"strings"
+)
+
+// SignatureVerification decides how versions whose shasum signature does not verify are treated while indexing.
+type SignatureVerification string
+
+const (
+	// VerifySignaturesOff does not verify signatures.
+	VerifySignaturesOff SignatureVerification = "off"
+	// VerifySignaturesWarn keeps versions whose signature does not verify and reports them as warnings.
+	VerifySignaturesWarn SignatureVerification = "warn"
+	// VerifySignaturesHide skips versions whose signature does not verify and reports them as warnings.
+	VerifySignaturesHide SignatureVerification = "hide"
+)
+
+// ParseSignatureVerification checks that mode is one of the supported modes.
+func ParseSignatureVerification(mode string) (SignatureVerification, error) {
+	switch verification := SignatureVerification(mode); verification {
+	case VerifySignaturesOff, VerifySignaturesWarn, VerifySignaturesHide:
+		return verification, nil
+	default:
+		return "", fmt.Errorf("unknown signature verification %s, expected %s, %s or %s", mode, VerifySignaturesOff, VerifySignaturesWarn, VerifySignaturesHide)
+	}
+}
+
+// errInvalidSignature marks verification failures caused by the files of a version rather than by the bucket.
+var errInvalidSignature = errors.New("invalid signature")
+
+// verifySignature checks that shasum.sig in basePath is a valid signature of shasum made by one of the keys of the
+// version, and that this key is announced with its own ID. inheritedKeys returns the keys used if the version has none
+// of its own. Failures caused by the files wrap errInvalidSignature, failures to read them are returned as they are.
+func (client RegistryClient) verifySignature(basePath string, inheritedKeys func(basePath string) ([]schema.GpgPublicKey, error)) error {
+	files := make(map[string]string)
+	for _, filename := range []string{"shasum", "shasum.sig"} {
+		content, err := client.fetchObjectAsString(fmt.Sprintf("%s/%s", basePath, filename))
+		if errors.Is(err, registryerror.ErrNotFound) {
+			return fmt.Errorf("%w: %s is missing", errInvalidSignature, filename)
+		}
+		if err != nil {
+			return err
+		}
+		files[filename] = content
+	}
+
+	announcedKeys, err := client.fetchVersionKeys(basePath)
+	if err == nil && len(announcedKeys) == 0 {
+		announcedKeys, err = inheritedKeys(basePath)
+		if err == nil && len(announcedKeys) == 0 {
+			err = noSigningKeys(basePath)
+		}
+	}
+	if errors.Is(err, registryerror.ErrNotFound) {
+		return fmt.Errorf("%w: %v", errInvalidSignature, err)
+	}
+	var registryError *registryerror.Error
+	if errors.Is(err, errInvalidKey) && errors.As(err, &registryError) {
+		return fmt.Errorf("%w: %s", errInvalidSignature, registryError.Message())
+	}
+	if err != nil {
+		return err
+	}
+
+	keys := make(openpgp.EntityList, 0, len(announcedKeys))
+	keyIDs := make([]string, 0, len(announcedKeys))
+	for _, announcedKey := range announcedKeys {
+		entities, err := pgp.ReadPublicKeys(announcedKey.ASCIIArmor)
+		if err != nil {
+			return fmt.Errorf("%w: keyfile: %v", errInvalidSignature, err)
+		}
+		keys = append(keys, entities...)
+		keyIDs = append(keyIDs, strings.TrimSpace(announcedKey.KeyID))
+	}
+
+	signerKeyID, err := pgp.VerifyDetached(keys, []byte(files["shasum"]), []byte(files["shasum.sig"]))
+	if err != nil {
+		return fmt.Errorf("%w: %v", errInvalidSignature, err)
+	}
+	for _, keyID := range keyIDs {
+		if strings.EqualFold(keyID, signerKeyID) {
+			return nil
+		}
+	}
+	if len(keyIDs) == 1 {
+		return fmt.Errorf("%w: key_id %s does not match the signing key %s", errInvalidSignature, keyIDs[0], signerKeyID)
+	}
+	return fmt.Errorf("%w: none of the key IDs %s matches the signing key %s", errInvalidSignature, strings.Join(keyIDs, ", "), signerKeyID)
+}
+
+// verifySignatures verifies the signatures of versions according to the configured verification. It returns the
+// versions which are kept and warnings about the versions whose signature does not verify. Results are reused while
+// the listed eTags of the files of a version and the keys it inherits stay the same. The inherited keys are read once
+// for all versions.
+func (client RegistryClient) verifySignatures(namespace string, providerType string, versions []string, eTags map[string]string) (map[string]bool, []string, error) {
+	kept := make(map[string]bool, len(versions))
+	warnings := make([]string, 0)
+	if client.signatureVerification == VerifySignaturesOff || client.signatureVerification == "" {
+		for _, version := range versions {
+			kept[version] = true
+		}
+		return kept, warnings, nil
+	}
+
+	provider := fmt.Sprintf("%s/%s", namespace, providerType)
+	inheritedKeys := client.inheritedKeysOnce()
+
+	results := make(map[string]cachedResult[error], len(versions))
+	for _, version := range versions {
+		basePath := fmt.Sprintf("%s/%s", provider, version)
+		fingerprint, cacheable := versionFingerprint(eTags, basePath, inheritedKeys)
+		verificationErr, ok := client.verifications.lookup(provider, version, fingerprint)
+		if !ok || !cacheable {
+			verificationErr = client.verifySignature(basePath, inheritedKeys)
+			if verificationErr != nil && !errors.Is(verificationErr, errInvalidSignature) {
+				return nil, nil, verificationErr
+			}
+		} else {
+			logger.Sugar.Debugw("signature unchanged since last verification", "version", basePath)
+		}
+		if cacheable {
+			results[version] = cachedResult[error]{fingerprint: fingerprint, value: verificationErr}
+		}
+
+		kept[version] = true
+		if verificationErr == nil {
+			continue
+		}
+
+		logger.Sugar.Warnw("signature does not verify", "version", basePath, "error", verificationErr)
+		reason := strings.TrimPrefix(verificationErr.Error(), errInvalidSignature.Error()+": ")
+		if client.signatureVerification == VerifySignaturesHide {
+			kept[version] = false
+			warnings = append(warnings, fmt.Sprintf("skipped %s: %s", basePath, reason))
+		} else {
+			warnings = append(warnings, fmt.Sprintf("signature of %s does not verify: %s", basePath, reason))
+		}
+	}
+	client.verifications.replace(provider, results)
+	return kept, warnings, nil
+}
+
+// inheritedKeysOnce returns a function fetching the inherited keys on its first call only, as they are the same for
+// all versions of a provider type.
+func (client RegistryClient) inheritedKeysOnce() func(basePath string) ([]schema.GpgPublicKey, error) {
+	fetched := false
+	var keys []schema.GpgPublicKey
+	var err error
+	return func(basePath string) ([]schema.GpgPublicKey, error) {
+		if !fetched {
+			keys, err = client.fetchInheritedKeys(basePath)
+			fetched = true
+		}
+		return keys, err
+	}
+}
+
+// versionFingerprint identifies the files the verification of the version in basePath depends on by their ETags. If
+// the version has no keys of its own, the keys it inherits are included by their content. It returns false if the
+// storage does not list ETags or the inherited keys cannot be read.
+func versionFingerprint(eTags map[string]string, basePath string, inheritedKeys func(basePath string) ([]schema.GpgPublicKey, error)) (string, bool) {
+	if eTags == nil {
+		return "", false
+	}
+
+	var fingerprint strings.Builder
+	for _, filename := range []string{"shasum", "shasum.sig", "keyfile", "key_id"} {
+		fmt.Fprintf(&fingerprint, "%s=%s\n", filename, eTags[basePath+"/"+filename])
+	}
+	_, hasKeys := eTags[basePath+"/keyfile"]
+
+	keysPrefix := basePath + "/" + KeysDirectory + "/"
+	keyFiles := make([]string, 0)
+	for key := range eTags {
+		if strings.HasPrefix(key, keysPrefix) {
+			keyFiles = append(keyFiles, key)
+		}
+	}
+	sort.Strings(keyFiles)
+	for _, key := range keyFiles {
+		fmt.Fprintf(&fingerprint, "%s=%s\n", strings.TrimPrefix(key, basePath+"/"), eTags[key])
+		if _, isMetadata, ok := ParseKeyFilename(strings.TrimPrefix(key, keysPrefix)); ok && !isMetadata {
+			hasKeys = true
+		}
+	}
+
+	if !hasKeys {
+		keys, err := inheritedKeys(basePath)
+		if err != nil {
+			return "", false
+		}
+		content, err := json.Marshal(keys)
+		if err != nil {
+			return "", false
+		}
+		fmt.Fprintf(&fingerprint, "inherited=%x\n", sha256.Sum256(content))
+	}
+	return fingerprint.String(), true
+}
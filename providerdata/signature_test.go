@@ -0,0 +1,188 @@
+package providerdata
+
+import (
+	test_support "github.com/mdreem/s3_terraform_registry/internal/testsupport"
+	"github.com/mdreem/s3_terraform_registry/s3"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// signedVersion returns the objects of a version of black/lodge whose shasum file is signed by signer, while keyfile
+// and key_id contain key.
+func signedVersion(version string, key test_support.SigningKey, signer test_support.SigningKey) map[string]string {
+	basePath := "black/lodge/" + version
+	return map[string]string{
+		basePath + "/terraform-provider-lodge_" + version + "_linux_amd64.zip": "archive",
+		basePath + "/shasum":     shaSumFileContent,
+		basePath + "/shasum.sig": string(signer.Sign([]byte(shaSumFileContent))),
+		basePath + "/keyfile":    key.ArmoredPublicKey(),
+		basePath + "/key_id":     key.KeyID(),
+	}
+}
+
+func TestRegistryClient_VersionsFromObjectsVerifiesSignatures(t *testing.T) {
+	key := test_support.NewSigningKey("Dale Cooper")
+	otherKey := test_support.NewSigningKey("Windom Earle")
+
+	objects := make(map[string]string)
+	for _, version := range []map[string]string{
+		signedVersion("1.0.0", key, key),
+		signedVersion("1.0.1", key, otherKey),
+		signedVersion("1.0.2", key, key),
+		signedVersion("1.0.3", key, key),
+		signedVersion("1.0.4", key, otherKey),
+	} {
+		for objectKey, content := range version {
+			objects[objectKey] = content
+		}
+	}
+	objects["black/lodge/1.0.2/key_id"] = otherKey.KeyID()
+	delete(objects, "black/lodge/1.0.3/shasum.sig")
+	// the key which signed 1.0.4 is announced in the keys directory
+	objects["black/lodge/1.0.4/keys/windom-earle.asc"] = otherKey.ArmoredPublicKey()
+	bucket := test_support.NewMemoryBucket(objects)
+	keys, _ := bucket.ListObjects()
+
+	tests := []struct {
+		name         string
+		verification SignatureVerification
+		wantVersions []string
+		wantWarnings []string
+	}{
+		{
+			name:         "verification disabled",
+			verification: VerifySignaturesOff,
+			wantVersions: []string{"1.0.0", "1.0.1", "1.0.2", "1.0.3", "1.0.4"},
+		},
+		{
+			name:         "warn about versions which do not verify",
+			verification: VerifySignaturesWarn,
+			wantVersions: []string{"1.0.0", "1.0.1", "1.0.2", "1.0.3", "1.0.4"},
+			wantWarnings: []string{
+				"signature of black/lodge/1.0.1 does not verify: the signature does not verify: openpgp: signature made by unknown entity",
+				"signature of black/lodge/1.0.2 does not verify: key_id " + otherKey.KeyID() + " does not match the signing key " + key.KeyID(),
+				"signature of black/lodge/1.0.3 does not verify: shasum.sig is missing",
+			},
+		},
+		{
+			name:         "hide versions which do not verify",
+			verification: VerifySignaturesHide,
+			wantVersions: []string{"1.0.0", "1.0.4"},
+			wantWarnings: []string{
+				"skipped black/lodge/1.0.1: the signature does not verify: openpgp: signature made by unknown entity",
+				"skipped black/lodge/1.0.2: key_id " + otherKey.KeyID() + " does not match the signing key " + key.KeyID(),
+				"skipped black/lodge/1.0.3: shasum.sig is missing",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, _ := NewS3Backend(bucket, "twin.peaks", WithSignatureVerification(tt.verification))
+
+			got, err := client.VersionsFromObjects("black", "lodge", keys, nil)
+			if err != nil {
+				t.Fatalf("VersionsFromObjects() error = %v", err)
+			}
+
+			versions := make([]string, 0)
+			for _, version := range got.Versions {
+				versions = append(versions, version.Version)
+			}
+			if !reflect.DeepEqual(versions, tt.wantVersions) {
+				t.Errorf("VersionsFromObjects() versions = %v, want %v", versions, tt.wantVersions)
+			}
+			if !reflect.DeepEqual(got.Warnings, tt.wantWarnings) {
+				t.Errorf("VersionsFromObjects() warnings = %v, want %v", got.Warnings, tt.wantWarnings)
+			}
+		})
+	}
+}
+
+// readCountingBucket counts the objects read and the listings of ETags.
+type readCountingBucket struct {
+	*test_support.MemoryBucket
+	reads        map[string]int
+	eTagListings int
+}
+
+func (bucket *readCountingBucket) ListETagsWithPrefix(prefix string) (map[string]string, error) {
+	bucket.eTagListings++
+	return bucket.MemoryBucket.ListETagsWithPrefix(prefix)
+}
+
+func (bucket *readCountingBucket) GetObject(key string) (s3.BucketObject, error) {
+	bucket.reads[key]++
+	return bucket.MemoryBucket.GetObject(key)
+}
+
+func TestRegistryClient_VersionsFromObjectsReusesVerifications(t *testing.T) {
+	key := test_support.NewSigningKey("Dale Cooper")
+	otherKey := test_support.NewSigningKey("Windom Earle")
+
+	objects := signedVersion("1.0.0", key, key)
+	for _, version := range []string{"1.0.1", "1.0.2"} {
+		for objectKey, content := range signedVersion(version, key, key) {
+			objects[objectKey] = content
+		}
+		delete(objects, "black/lodge/"+version+"/keyfile")
+		delete(objects, "black/lodge/"+version+"/key_id")
+	}
+	objects["black/keys/dale-cooper.asc"] = key.ArmoredPublicKey()
+	bucket := &readCountingBucket{MemoryBucket: test_support.NewMemoryBucket(objects)}
+	client, _ := NewS3Backend(bucket, "twin.peaks", WithSignatureVerification(VerifySignaturesHide))
+
+	versionsFromObjects := func() []string {
+		keys, eTags, err := s3.ListObjectsWithETags(bucket, "")
+		if err != nil {
+			t.Fatalf("ListObjectsWithETags() error = %v", err)
+		}
+		bucket.reads = make(map[string]int)
+		bucket.eTagListings = 0
+		got, err := client.VersionsFromObjects("black", "lodge", keys, eTags)
+		if err != nil {
+			t.Fatalf("VersionsFromObjects() error = %v", err)
+		}
+		if bucket.eTagListings != 0 {
+			t.Errorf("VersionsFromObjects() listed the bucket again instead of using the listed ETags")
+		}
+		versions := make([]string, 0)
+		for _, version := range got.Versions {
+			versions = append(versions, version.Version)
+		}
+		return versions
+	}
+
+	if got := versionsFromObjects(); !reflect.DeepEqual(got, []string{"1.0.0", "1.0.1", "1.0.2"}) {
+		t.Errorf("VersionsFromObjects() versions = %v", got)
+	}
+	if bucket.reads["black/keys/dale-cooper.asc"] != 1 {
+		t.Errorf("VersionsFromObjects() read the inherited key %d times, want once", bucket.reads["black/keys/dale-cooper.asc"])
+	}
+
+	versionsFromObjects()
+	for _, objectKey := range []string{"black/lodge/1.0.0/shasum", "black/lodge/1.0.0/shasum.sig", "black/lodge/1.0.0/keyfile", "black/lodge/1.0.1/shasum"} {
+		if bucket.reads[objectKey] != 0 {
+			t.Errorf("VersionsFromObjects() read unchanged %s again", objectKey)
+		}
+	}
+
+	// changed files and inherited keys are verified again
+	_ = bucket.PutObject("black/lodge/1.0.0/key_id", strings.NewReader(otherKey.KeyID()))
+	_ = bucket.PutObject("black/keys/dale-cooper.asc", strings.NewReader(otherKey.ArmoredPublicKey()))
+	if got := versionsFromObjects(); len(got) != 0 {
+		t.Errorf("VersionsFromObjects() versions = %v, want none", got)
+	}
+	if bucket.reads["black/lodge/1.0.0/shasum.sig"] != 1 || bucket.reads["black/lodge/1.0.2/shasum.sig"] != 1 {
+		t.Errorf("VersionsFromObjects() did not verify the changed versions again, reads %v", bucket.reads)
+	}
+}
+
+func TestParseSignatureVerification(t *testing.T) {
+	if verification, err := ParseSignatureVerification("hide"); err != nil || verification != VerifySignaturesHide {
+		t.Errorf("ParseSignatureVerification() = %v, %v", verification, err)
+	}
+	if _, err := ParseSignatureVerification("strict"); err == nil {
+		t.Errorf("ParseSignatureVerification() expected error for unknown mode")
+	}
+}
@@ -0,0 +1,260 @@
+package providerdata
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/mdreem/s3_terraform_registry/logger"
+	"github.com/mdreem/s3_terraform_registry/pgp"
+	"github.com/mdreem/s3_terraform_registry/registryerror"
+	"github.com/mdreem/s3_terraform_registry/s3"
+	"github.com/mdreem/s3_terraform_registry/schema"
+	"sort"
+	"strings"
+)
+
+// KeysDirectory is the folder of a version containing its public keys besides keyfile, one ASCII armored key file
+// <name>.asc per key with optional metadata in <name>.json.
+const KeysDirectory = "keys"
+
+// errInvalidKey marks key files of a version which cannot be read, as opposed to failures of the bucket.
+var errInvalidKey = errors.New("invalid key file")
+
+const (
+	keyFileSuffix         = ".asc"
+	keyMetadataFileSuffix = ".json"
+)
+
+// KeyMetadata is the metadata of a public key announced to Terraform along with the key.
+type KeyMetadata struct {
+	TrustSignature string `json:"trust_signature"`
+	Source         string `json:"source"`
+	SourceURL      string `json:"source_url"`
+}
+
+// ParseKeyMetadata parses the metadata file of a key.
+func ParseKeyMetadata(content string) (KeyMetadata, error) {
+	var metadata KeyMetadata
+	if err := json.Unmarshal([]byte(content), &metadata); err != nil {
+		return KeyMetadata{}, err
+	}
+	return metadata, nil
+}
+
+// ParseKeyFilename returns the name of the key for a file name within KeysDirectory and whether it is a key file or
+// its metadata.
+func ParseKeyFilename(filename string) (string, bool, bool) {
+	if strings.Contains(filename, "/") {
+		return "", false, false
+	}
+	if name := strings.TrimSuffix(filename, keyFileSuffix); name != filename && name != "" {
+		return name, false, true
+	}
+	if name := strings.TrimSuffix(filename, keyMetadataFileSuffix); name != filename && name != "" {
+		return name, true, true
+	}
+	return "", false, false
+}
+
+// IsInheritedKeyFile returns whether key lies in the KeysDirectory of a provider type, a namespace or the bucket root,
+// whose keys are inherited by the versions below.
+func IsInheritedKeyFile(key string) bool {
+	parts := strings.Split(key, "/")
+	if len(parts) < 2 || len(parts) > 4 || parts[len(parts)-2] != KeysDirectory || parts[len(parts)-1] == "" {
+		return false
+	}
+	for _, part := range parts[:len(parts)-2] {
+		if part == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// InheritedKeysDirectories returns the directories whose keys the version in basePath inherits, nearest first: the
+// ones of its provider type, its namespace and the bucket root.
+func InheritedKeysDirectories(basePath string) []string {
+	parts := strings.Split(basePath, "/")
+	directories := make([]string, 0, len(parts))
+	for i := len(parts) - 1; i > 0; i-- {
+		directories = append(directories, strings.Join(parts[:i], "/")+"/"+KeysDirectory+"/")
+	}
+	return append(directories, KeysDirectory+"/")
+}
+
+// fetchSigningKeys returns the public keys of the version in basePath. If the version has keys of its own, these are
+// returned. Otherwise the keys are inherited from the nearest KeysDirectory of its provider type, its namespace or the
+// bucket root.
+func (client RegistryClient) fetchSigningKeys(basePath string) ([]schema.GpgPublicKey, error) {
+	keys, err := client.fetchVersionKeys(basePath)
+	if err != nil || len(keys) > 0 {
+		return keys, err
+	}
+
+	keys, err = client.fetchInheritedKeys(basePath)
+	if err != nil || len(keys) > 0 {
+		return keys, err
+	}
+	return nil, noSigningKeys(basePath)
+}
+
+// SigningKeys returns the public keys announced for the version in basePath of bucket: its own keys or, if it has
+// none, the keys it inherits. It returns no keys if there are none at all.
+func SigningKeys(bucket s3.BucketReaderWriter, basePath string) ([]schema.GpgPublicKey, error) {
+	client := RegistryClient{bucket: bucket}
+	keys, err := client.fetchVersionKeys(basePath)
+	if err != nil || len(keys) > 0 {
+		return keys, err
+	}
+	return client.fetchInheritedKeys(basePath)
+}
+
+// fetchInheritedKeys returns the keys of the nearest KeysDirectory containing keys which the version in basePath
+// inherits. They are the same for all versions of a provider type.
+func (client RegistryClient) fetchInheritedKeys(basePath string) ([]schema.GpgPublicKey, error) {
+	for _, directory := range InheritedKeysDirectories(basePath) {
+		keys, err := client.fetchKeysDirectory(directory)
+		if err != nil {
+			return nil, err
+		}
+		if len(keys) > 0 {
+			logger.Sugar.Debugw("inheriting signing keys", "version", basePath, "directory", directory)
+			return keys, nil
+		}
+	}
+	return nil, nil
+}
+
+func noSigningKeys(basePath string) error {
+	return registryerror.NotFound(nil, "%s contains neither keyfile nor %s/ and there are no keys in %s", basePath, KeysDirectory, strings.Join(InheritedKeysDirectories(basePath), ", "))
+}
+
+// fetchVersionKeys returns the keys in the folder of the version in basePath. These are the keys in keyfile,
+// announced with the ID in key_id if keyfile holds a single key, followed by the keys in KeysDirectory. Keys are only
+// announced once.
+func (client RegistryClient) fetchVersionKeys(basePath string) ([]schema.GpgPublicKey, error) {
+	keys := make([]schema.GpgPublicKey, 0)
+	seen := make(map[string]bool)
+	add := func(newKeys []schema.GpgPublicKey) {
+		for _, key := range newKeys {
+			keyID := strings.ToUpper(strings.TrimSpace(key.KeyID))
+			if seen[keyID] {
+				continue
+			}
+			seen[keyID] = true
+			keys = append(keys, key)
+		}
+	}
+
+	keyfile, err := client.fetchObjectAsString(fmt.Sprintf("%s/keyfile", basePath))
+	switch {
+	case errors.Is(err, registryerror.ErrNotFound):
+	case err != nil:
+		return nil, err
+	default:
+		keyfileKeys, err := client.keyfileKeys(basePath, keyfile)
+		if err != nil {
+			return nil, err
+		}
+		add(keyfileKeys)
+	}
+
+	directoryKeys, err := client.fetchKeysDirectory(fmt.Sprintf("%s/%s/", basePath, KeysDirectory))
+	if err != nil {
+		return nil, err
+	}
+	add(directoryKeys)
+	return keys, nil
+}
+
+// keyfileKeys returns the keys in keyfile. A single key is announced unchanged with the ID in key_id, so keyfiles
+// which cannot be parsed keep being served. Several keys are announced separately with their own IDs.
+func (client RegistryClient) keyfileKeys(basePath string, keyfile string) ([]schema.GpgPublicKey, error) {
+	if entities, err := pgp.ReadPublicKeys(keyfile); err == nil && len(entities) > 1 {
+		return publicKeys(entities, keyfile, KeyMetadata{})
+	}
+
+	keyID, err := client.fetchObjectAsString(fmt.Sprintf("%s/key_id", basePath))
+	if err != nil {
+		return nil, err
+	}
+	return []schema.GpgPublicKey{{KeyID: keyID, ASCIIArmor: keyfile}}, nil
+}
+
+// fetchKeysDirectory reads the keys in the KeysDirectory prefix in the order of their names.
+func (client RegistryClient) fetchKeysDirectory(prefix string) ([]schema.GpgPublicKey, error) {
+	objects, err := client.bucket.ListObjectsWithPrefix(prefix, "/")
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0)
+	hasMetadata := make(map[string]bool)
+	for _, object := range objects {
+		name, isMetadata, ok := ParseKeyFilename(strings.TrimPrefix(object, prefix))
+		switch {
+		case !ok:
+			logger.Sugar.Debugw("signing keys: ignoring", "item", object)
+		case isMetadata:
+			hasMetadata[name] = true
+		default:
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	keys := make([]schema.GpgPublicKey, 0)
+	for _, name := range names {
+		keyLocation := prefix + name + keyFileSuffix
+		armoredKey, err := client.fetchObjectAsString(keyLocation)
+		if err != nil {
+			return nil, err
+		}
+		entities, err := pgp.ReadPublicKeys(armoredKey)
+		if err != nil {
+			return nil, registryerror.Upstream(errInvalidKey, "invalid %s: %v", keyLocation, err)
+		}
+
+		var metadata KeyMetadata
+		if hasMetadata[name] {
+			metadataLocation := prefix + name + keyMetadataFileSuffix
+			content, err := client.fetchObjectAsString(metadataLocation)
+			if err != nil {
+				return nil, err
+			}
+			if metadata, err = ParseKeyMetadata(content); err != nil {
+				return nil, registryerror.Upstream(errInvalidKey, "invalid %s: %v", metadataLocation, err)
+			}
+		}
+
+		fileKeys, err := publicKeys(entities, armoredKey, metadata)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, fileKeys...)
+	}
+	return keys, nil
+}
+
+// publicKeys announces every key in entities with metadata. A single key keeps its armored form as it was read.
+func publicKeys(entities openpgp.EntityList, armoredKeys string, metadata KeyMetadata) ([]schema.GpgPublicKey, error) {
+	keys := make([]schema.GpgPublicKey, 0, len(entities))
+	for _, entity := range entities {
+		armoredKey := armoredKeys
+		if len(entities) > 1 {
+			var err error
+			if armoredKey, err = pgp.ArmorPublicKey(entity); err != nil {
+				return nil, fmt.Errorf("unable to armor key %s: %v", pgp.KeyID(entity), err)
+			}
+		}
+		keys = append(keys, schema.GpgPublicKey{
+			KeyID:          pgp.KeyID(entity),
+			ASCIIArmor:     armoredKey,
+			TrustSignature: metadata.TrustSignature,
+			Source:         metadata.Source,
+			SourceURL:      metadata.SourceURL,
+		})
+	}
+	return keys, nil
+}
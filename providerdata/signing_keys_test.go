@@ -0,0 +1,166 @@
+package providerdata
+
+import (
+	"errors"
+	test_support "github.com/mdreem/s3_terraform_registry/internal/testsupport"
+	"github.com/mdreem/s3_terraform_registry/registryerror"
+	"github.com/mdreem/s3_terraform_registry/schema"
+	"reflect"
+	"testing"
+)
+
+func TestRegistryClient_GetVersionMetadataSigningKeys(t *testing.T) {
+	key := test_support.NewSigningKey("Dale Cooper")
+	otherKey := test_support.NewSigningKey("Gordon Cole")
+	basePath := "black/lodge/1.0.0/"
+
+	tests := []struct {
+		name    string
+		objects map[string]string
+		want    []schema.GpgPublicKey
+		wantErr error
+	}{
+		{
+			name: "keyfile with a single key",
+			objects: map[string]string{
+				basePath + "keyfile": key.ArmoredPublicKey(),
+				basePath + "key_id":  key.KeyID(),
+			},
+			want: []schema.GpgPublicKey{{KeyID: key.KeyID(), ASCIIArmor: key.ArmoredPublicKey()}},
+		},
+		{
+			name: "keyfile with several keys",
+			objects: map[string]string{
+				basePath + "keyfile": key.ArmoredPublicKey() + otherKey.ArmoredPublicKey(),
+			},
+			want: []schema.GpgPublicKey{
+				{KeyID: key.KeyID(), ASCIIArmor: key.ArmoredPublicKey()},
+				{KeyID: otherKey.KeyID(), ASCIIArmor: otherKey.ArmoredPublicKey()},
+			},
+		},
+		{
+			name: "keys directory with metadata",
+			objects: map[string]string{
+				basePath + "keyfile":           key.ArmoredPublicKey(),
+				basePath + "key_id":            key.KeyID(),
+				basePath + "keys/cooper.asc":   key.ArmoredPublicKey(),
+				basePath + "keys/cole.asc":     otherKey.ArmoredPublicKey(),
+				basePath + "keys/cole.json":    `{"trust_signature": "", "source": "FBI", "source_url": "https://fbi.twin.peaks/"}`,
+				basePath + "keys/README.md":    "not a key",
+				basePath + "keys/old/key.asc":  "not read",
+				basePath + "terraform.tfstate": "not a key",
+			},
+			want: []schema.GpgPublicKey{
+				{KeyID: key.KeyID(), ASCIIArmor: key.ArmoredPublicKey()},
+				{KeyID: otherKey.KeyID(), ASCIIArmor: otherKey.ArmoredPublicKey(), Source: "FBI", SourceURL: "https://fbi.twin.peaks/"},
+			},
+		},
+		{
+			name: "keys directory without keyfile",
+			objects: map[string]string{
+				basePath + "keys/cole.asc": otherKey.ArmoredPublicKey(),
+			},
+			want: []schema.GpgPublicKey{{KeyID: otherKey.KeyID(), ASCIIArmor: otherKey.ArmoredPublicKey()}},
+		},
+		{
+			name: "keys of the version are preferred",
+			objects: map[string]string{
+				basePath + "keys/cooper.asc": key.ArmoredPublicKey(),
+				"black/keys/cole.asc":        otherKey.ArmoredPublicKey(),
+			},
+			want: []schema.GpgPublicKey{{KeyID: key.KeyID(), ASCIIArmor: key.ArmoredPublicKey()}},
+		},
+		{
+			name: "keys of the provider type",
+			objects: map[string]string{
+				"black/lodge/keys/cooper.asc": key.ArmoredPublicKey(),
+				"black/keys/cole.asc":         otherKey.ArmoredPublicKey(),
+				"keys/cole.asc":               otherKey.ArmoredPublicKey(),
+			},
+			want: []schema.GpgPublicKey{{KeyID: key.KeyID(), ASCIIArmor: key.ArmoredPublicKey()}},
+		},
+		{
+			name: "keys of the namespace",
+			objects: map[string]string{
+				"black/keys/cooper.asc":  key.ArmoredPublicKey(),
+				"black/keys/cooper.json": `{"source": "Twin Peaks Sheriff's Department"}`,
+				"black/owl/keys/ole.asc": otherKey.ArmoredPublicKey(),
+				"white/keys/cole.asc":    otherKey.ArmoredPublicKey(),
+				"keys/cole.asc":          otherKey.ArmoredPublicKey(),
+			},
+			want: []schema.GpgPublicKey{{KeyID: key.KeyID(), ASCIIArmor: key.ArmoredPublicKey(), Source: "Twin Peaks Sheriff's Department"}},
+		},
+		{
+			name: "keys of the bucket",
+			objects: map[string]string{
+				"keys/cooper.asc": key.ArmoredPublicKey(),
+			},
+			want: []schema.GpgPublicKey{{KeyID: key.KeyID(), ASCIIArmor: key.ArmoredPublicKey()}},
+		},
+		{
+			name:    "no keys",
+			objects: map[string]string{},
+			wantErr: registryerror.ErrNotFound,
+		},
+		{
+			name: "keyfile without key_id",
+			objects: map[string]string{
+				basePath + "keyfile": key.ArmoredPublicKey(),
+			},
+			wantErr: registryerror.ErrNotFound,
+		},
+		{
+			name: "invalid metadata",
+			objects: map[string]string{
+				basePath + "keys/cole.asc":  otherKey.ArmoredPublicKey(),
+				basePath + "keys/cole.json": "FBI",
+			},
+			wantErr: registryerror.ErrUpstream,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects := map[string]string{basePath + "shasum": shaSumFileContent}
+			for objectKey, content := range tt.objects {
+				objects[objectKey] = content
+			}
+			client, _ := NewS3Backend(test_support.NewMemoryBucket(objects), "twin.peaks")
+
+			got, err := client.GetVersionMetadata("black", "lodge", "1.0.0")
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("GetVersionMetadata() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetVersionMetadata() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.GpgPublicKeys, tt.want) {
+				t.Errorf("GetVersionMetadata() keys = %+v, want %+v", got.GpgPublicKeys, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsInheritedKeyFile(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{key: "keys/cooper.asc", want: true},
+		{key: "black/keys/cooper.asc", want: true},
+		{key: "black/lodge/keys/cooper.asc", want: true},
+		{key: "black/lodge/1.0.0/keys/cooper.asc", want: false},
+		{key: "black/lodge/1.0.0/keyfile", want: false},
+		{key: "keys/", want: false},
+		{key: "/keys/cooper.asc", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := IsInheritedKeyFile(tt.key); got != tt.want {
+				t.Errorf("IsInheritedKeyFile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
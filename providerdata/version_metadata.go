@@ -0,0 +1,122 @@
+package providerdata
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mdreem/s3_terraform_registry/logger"
+	"github.com/mdreem/s3_terraform_registry/registryerror"
+	"github.com/mdreem/s3_terraform_registry/schema"
+	"github.com/mdreem/s3_terraform_registry/semver"
+	"strings"
+)
+
+func (client RegistryClient) GetVersionMetadata(namespace string, providerType string, version string) (schema.VersionMetadata, error) {
+	metadata, err := client.GetMirrorMetadata(namespace, providerType, version)
+	if err != nil {
+		return schema.VersionMetadata{}, err
+	}
+
+	basePath := fmt.Sprintf("%s/%s/%s", namespace, providerType, version)
+	logger.Sugar.Debugw("getting version metadata", "basePath", basePath)
+
+	metadata.Protocols, err = client.fetchProtocols(basePath)
+	if err != nil {
+		return schema.VersionMetadata{}, err
+	}
+
+	metadata.GpgPublicKeys, err = client.fetchSigningKeys(basePath)
+	if err != nil {
+		return schema.VersionMetadata{}, err
+	}
+	return metadata, nil
+}
+
+// GetMirrorMetadata returns the part of the metadata of a version needed by the mirror, which are the hashes of its
+// archives. Unlike the download data, the mirror needs no signing keys.
+func (client RegistryClient) GetMirrorMetadata(namespace string, providerType string, version string) (schema.VersionMetadata, error) {
+	if _, err := semver.Parse(version); err != nil {
+		return schema.VersionMetadata{}, registryerror.BadRequest(err, "%s is not a valid version", version)
+	}
+
+	basePath := fmt.Sprintf("%s/%s/%s", namespace, providerType, version)
+	shaSums, err := client.fetchShaSums(basePath)
+	if err != nil {
+		return schema.VersionMetadata{}, err
+	}
+
+	hashes, err := client.fetchHashes(basePath)
+	if err != nil {
+		return schema.VersionMetadata{}, err
+	}
+
+	return schema.VersionMetadata{
+		ShaSums: shaSums,
+		Hashes:  hashes,
+	}, nil
+}
+
+// DownloadData builds the download data of a platform from the metadata of its version.
+func (client RegistryClient) DownloadData(namespace string, providerType string, version string, os string, arch string, metadata schema.VersionMetadata) (schema.DownloadData, error) {
+	if strings.Contains(os, "_") || strings.Contains(arch, "_") {
+		return schema.DownloadData{}, registryerror.BadRequest(nil, "%s_%s is not a valid platform", os, arch)
+	}
+
+	filename := ArtifactFilename(providerType, version, os, arch)
+	shaSum, ok := metadata.ShaSums[filename]
+	if !ok {
+		return schema.DownloadData{}, registryerror.NotFound(nil, "%s is not available", filename)
+	}
+
+	baseURL := fmt.Sprintf("https://%s/proxy/%s/%s/%s", client.hostname, namespace, providerType, version)
+	logger.Sugar.Debugw("building download data", "baseURL", baseURL, "filename", filename)
+
+	return schema.DownloadData{
+		Protocols:           metadata.Protocols,
+		Os:                  os,
+		Arch:                arch,
+		Filename:            filename,
+		DownloadURL:         fmt.Sprintf("%s/%s", baseURL, filename),
+		ShasumsURL:          fmt.Sprintf("%s/shasum", baseURL),
+		ShasumsSignatureURL: fmt.Sprintf("%s/shasum.sig", baseURL),
+		Shasum:              shaSum,
+		SigningKeys: struct {
+			GpgPublicKeys []schema.GpgPublicKey `json:"gpg_public_keys"`
+		}{
+			metadata.GpgPublicKeys,
+		},
+	}, nil
+}
+
+func (client RegistryClient) fetchShaSums(basePath string) (map[string]string, error) {
+	shaSumLocation := fmt.Sprintf("%s/shasum", basePath)
+	logger.Sugar.Debugw("fetching shasum file", "file", shaSumLocation)
+
+	shaSumFile, err := client.fetchObjectAsString(shaSumLocation)
+	if err != nil {
+		return nil, err
+	}
+
+	shaSums, err := ParseShaSums(shaSumFile)
+	if err != nil {
+		return nil, registryerror.Upstream(err, "unable to parse %s: %v", shaSumLocation, err)
+	}
+	return shaSums, nil
+}
+
+// fetchHashes reads the optional hashes file of the version in basePath.
+func (client RegistryClient) fetchHashes(basePath string) (map[string][]string, error) {
+	hashesLocation := fmt.Sprintf("%s/%s", basePath, HashesFilename)
+	hashesFile, err := client.fetchObjectAsString(hashesLocation)
+	if errors.Is(err, registryerror.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	hashes, err := ParseHashes(hashesFile)
+	if err != nil {
+		return nil, registryerror.Upstream(err, "unable to parse %s: %v", hashesLocation, err)
+	}
+	return hashes, nil
+}
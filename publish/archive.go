@@ -0,0 +1,54 @@
+package publish
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Archive is a zip-file of a release. Archives stay on disk while a release is published, so releases with many
+// large archives are not held in memory.
+type Archive struct {
+	// Path is the file containing the archive.
+	Path string
+	// SHA256 is the hex encoded SHA256 hash of the archive.
+	SHA256 string
+}
+
+// ReadArchive hashes the archive in path.
+func ReadArchive(path string) (Archive, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Archive{}, err
+	}
+	defer func() { _ = file.Close() }()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return Archive{}, fmt.Errorf("unable to read %s: %v", path, err)
+	}
+	return Archive{Path: path, SHA256: hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+// SpoolArchive writes content into the file filename in directory and hashes it on the way.
+func SpoolArchive(directory string, filename string, content io.Reader) (Archive, error) {
+	path := filepath.Join(directory, filepath.Base(filename))
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return Archive{}, err
+	}
+
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(file, hash), content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		return Archive{}, err
+	}
+	return Archive{Path: path, SHA256: hex.EncodeToString(hash.Sum(nil))}, nil
+}
@@ -0,0 +1,74 @@
+package publish
+
+import (
+	"fmt"
+	"github.com/mdreem/s3_terraform_registry/providerdata"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// distPrefix is the prefix goreleaser gives the files of a provider release.
+func distPrefix(providerType string, version string) string {
+	return fmt.Sprintf("terraform-provider-%s_%s_", providerType, version)
+}
+
+// ReadDist reads a release from a dist directory created by goreleaser. It contains the archives, the shasum file
+// terraform-provider-<type>_<version>_SHA256SUMS, its signature with the suffix .sig and optionally the manifest
+// terraform-provider-<type>_<version>_manifest.json. As goreleaser does not export the public key, it is passed
+// separately. The signature may be missing if the release is to be signed with the key of the registry.
+func ReadDist(directory string, namespace string, providerType string, version string, publicKey string) (Release, error) {
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return Release{}, fmt.Errorf("unable to read %s: %v", directory, err)
+	}
+
+	release := Release{
+		Namespace: namespace,
+		Type:      providerType,
+		Version:   version,
+		Archives:  make(map[string]Archive),
+		PublicKey: publicKey,
+	}
+
+	prefix := distPrefix(providerType, version)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), prefix) {
+			continue
+		}
+
+		_, _, isArchive := providerdata.ParseArtifactFilename(providerType, version, entry.Name())
+		isReleaseFile := entry.Name() == prefix+"SHA256SUMS" || entry.Name() == prefix+"SHA256SUMS.sig" || entry.Name() == prefix+"manifest.json"
+		if !isArchive && !isReleaseFile {
+			continue
+		}
+
+		if isArchive {
+			archive, err := ReadArchive(filepath.Join(directory, entry.Name()))
+			if err != nil {
+				return Release{}, fmt.Errorf("unable to read %s: %v", entry.Name(), err)
+			}
+			release.Archives[entry.Name()] = archive
+			continue
+		}
+
+		content, err := os.ReadFile(filepath.Join(directory, entry.Name()))
+		if err != nil {
+			return Release{}, fmt.Errorf("unable to read %s: %v", entry.Name(), err)
+		}
+
+		switch {
+		case entry.Name() == prefix+"SHA256SUMS":
+			release.ShaSums = content
+		case entry.Name() == prefix+"SHA256SUMS.sig":
+			release.Signature = content
+		default:
+			release.Manifest = content
+		}
+	}
+
+	if len(release.ShaSums) == 0 {
+		return Release{}, fmt.Errorf("%s does not contain %sSHA256SUMS", directory, prefix)
+	}
+	return release, nil
+}
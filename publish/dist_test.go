@@ -0,0 +1,56 @@
+package publish
+
+import (
+	"github.com/mdreem/s3_terraform_registry/internal/testsupport"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadDist(t *testing.T) {
+	key := testsupport.NewSigningKey("Dale Cooper")
+	release := newRelease(t, key)
+	release.Manifest = []byte(`{"version": 1, "metadata": {"protocol_versions": ["5.0"]}}`)
+
+	directory := t.TempDir()
+	files := map[string][]byte{
+		"terraform-provider-lodge_1.0.0_SHA256SUMS":      release.ShaSums,
+		"terraform-provider-lodge_1.0.0_SHA256SUMS.sig":  release.Signature,
+		"terraform-provider-lodge_1.0.0_manifest.json":   release.Manifest,
+		"terraform-provider-lodge_0.9.0_linux_amd64.zip": []byte("previous release"),
+		"config.yaml": []byte("project_name: terraform-provider-lodge"),
+	}
+	for filename, content := range archiveContents {
+		files[filename] = content
+		release.Archives[filename] = Archive{Path: filepath.Join(directory, filename), SHA256: release.Archives[filename].SHA256}
+	}
+	for filename, content := range files {
+		if err := os.WriteFile(filepath.Join(directory, filename), content, 0o600); err != nil {
+			t.Fatalf("unable to write %s: %v", filename, err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(directory, "terraform-provider-lodge_1.0.0_linux_amd64"), 0o700); err != nil {
+		t.Fatalf("unable to create directory: %v", err)
+	}
+
+	got, err := ReadDist(directory, "black", "lodge", "1.0.0", key.ArmoredPublicKey())
+	if err != nil {
+		t.Fatalf("ReadDist() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, release) {
+		t.Errorf("ReadDist() got = %+v, want %+v", got, release)
+	}
+}
+
+func TestReadDist_RequiresShaSums(t *testing.T) {
+	directory := t.TempDir()
+	if err := os.WriteFile(filepath.Join(directory, "terraform-provider-lodge_1.0.0_linux_amd64.zip"), []byte("linux archive"), 0o600); err != nil {
+		t.Fatalf("unable to write archive: %v", err)
+	}
+
+	_, err := ReadDist(directory, "black", "lodge", "1.0.0", "")
+	if err == nil || err.Error() != directory+" does not contain terraform-provider-lodge_1.0.0_SHA256SUMS" {
+		t.Errorf("ReadDist() error = %v, want error about the missing shasum file", err)
+	}
+}
@@ -0,0 +1,181 @@
+package publish
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/mdreem/s3_terraform_registry/logger"
+	"github.com/mdreem/s3_terraform_registry/pgp"
+	"github.com/mdreem/s3_terraform_registry/registryerror"
+	"github.com/mdreem/s3_terraform_registry/s3"
+	"github.com/mdreem/s3_terraform_registry/schema"
+	"os"
+	"sync"
+)
+
+// Refresher is implemented by caches which can refresh single providers.
+type Refresher interface {
+	RefreshProvider(namespace string, providerType string) error
+}
+
+// Publisher writes releases into the bucket.
+type Publisher struct {
+	bucket    s3.BucketReaderWriter
+	refresher Refresher
+	signer    *pgp.Signer
+	// publishing is shared by the copies of a publisher.
+	publishing *publishingVersions
+}
+
+// publishingVersions are the version folders being published, so concurrent publishes of the same version do not
+// overwrite each other's files.
+type publishingVersions struct {
+	lock     sync.Mutex
+	versions map[string]bool
+}
+
+// start marks basePath as being published. It returns false if it is already being published.
+func (publishing *publishingVersions) start(basePath string) bool {
+	publishing.lock.Lock()
+	defer publishing.lock.Unlock()
+
+	if publishing.versions[basePath] {
+		return false
+	}
+	publishing.versions[basePath] = true
+	return true
+}
+
+func (publishing *publishingVersions) finish(basePath string) {
+	publishing.lock.Lock()
+	defer publishing.lock.Unlock()
+
+	delete(publishing.versions, basePath)
+}
+
+type Option func(publisher *Publisher)
+
+// WithSigner signs the shasum files of releases which come without a signature with signer.
+func WithSigner(signer pgp.Signer) Option {
+	return func(publisher *Publisher) {
+		publisher.signer = &signer
+	}
+}
+
+func NewPublisher(bucket s3.BucketReaderWriter, refresher Refresher, options ...Option) Publisher {
+	publisher := Publisher{
+		bucket:     bucket,
+		refresher:  refresher,
+		publishing: &publishingVersions{versions: make(map[string]bool)},
+	}
+	for _, option := range options {
+		option(&publisher)
+	}
+	return publisher
+}
+
+// Publish validates release and writes its files into the bucket. Existing versions are not overwritten. If writing
+// a file fails, the files written so far are deleted again. Afterwards the provider is refreshed, a failed refresh is
+// reported as a warning as the release has been published nevertheless.
+//
+// Concurrent publishes of the same version by this publisher are rejected. As S3 offers no conditional writes to
+// this registry, publishes of the same version by several processes, e.g. several replicas or the publish command,
+// can still overwrite each other's files.
+func (publisher Publisher) Publish(release Release) (schema.Published, error) {
+	if !publisher.publishing.start(release.String()) {
+		return schema.Published{}, registryerror.Conflict(nil, "%s is being published", release.String())
+	}
+	defer publisher.publishing.finish(release.String())
+
+	prepared, published, err := publisher.plan(release)
+	if err != nil {
+		return schema.Published{}, err
+	}
+
+	written := make([]string, 0, len(prepared.Files))
+	for i, file := range prepared.Files {
+		key := published.Files[i]
+		logger.Sugar.Infow("publishing file", "key", key)
+
+		if err := publisher.putFile(key, file); err != nil {
+			publisher.rollback(written)
+			return schema.Published{}, err
+		}
+		written = append(written, key)
+	}
+
+	if publisher.refresher != nil {
+		if err := publisher.refresher.RefreshProvider(release.Namespace, release.Type); err != nil {
+			logger.Sugar.Errorw("unable to refresh published provider", "provider", published.Provider, "error", err)
+			published.Warnings = append(published.Warnings, fmt.Sprintf("unable to refresh %s, it is listed after the next refresh", published.Provider))
+		}
+	}
+
+	logger.Sugar.Infow("published release", "release", release.String(), "keyID", prepared.KeyID)
+	return published, nil
+}
+
+// DryRun validates release and checks that it does not exist yet without writing anything. It returns the files
+// Publish would write.
+func (publisher Publisher) DryRun(release Release) (schema.Published, error) {
+	_, published, err := publisher.plan(release)
+	return published, err
+}
+
+func (publisher Publisher) plan(release Release) (Prepared, schema.Published, error) {
+	if !release.IsSigned() && publisher.signer != nil {
+		var err error
+		if release, err = release.SignWith(*publisher.signer); err != nil {
+			return Prepared{}, schema.Published{}, err
+		}
+	}
+
+	prepared, err := release.Prepare()
+	if err != nil {
+		return Prepared{}, schema.Published{}, err
+	}
+
+	basePath := release.String()
+	existing, err := publisher.bucket.ListObjectsWithPrefix(basePath+"/", "")
+	if err != nil {
+		return Prepared{}, schema.Published{}, err
+	}
+	if len(existing) > 0 {
+		return Prepared{}, schema.Published{}, registryerror.Conflict(nil, "%s already exists", basePath)
+	}
+
+	keys := make([]string, 0, len(prepared.Files))
+	for _, file := range prepared.Files {
+		keys = append(keys, fmt.Sprintf("%s/%s", basePath, file.Name))
+	}
+
+	return prepared, schema.Published{
+		Provider: fmt.Sprintf("%s/%s", release.Namespace, release.Type),
+		Version:  release.Version,
+		KeyID:    prepared.KeyID,
+		Files:    keys,
+	}, nil
+}
+
+// putFile writes file into key, streaming archives from disk.
+func (publisher Publisher) putFile(key string, file File) error {
+	if file.Path == "" {
+		return publisher.bucket.PutObject(key, bytes.NewReader(file.Content))
+	}
+
+	content, err := os.Open(file.Path)
+	if err != nil {
+		return fmt.Errorf("unable to open %s: %v", file.Name, err)
+	}
+	defer func() { _ = content.Close() }()
+	return publisher.bucket.PutObject(key, content)
+}
+
+// rollback deletes the files written so far in reverse order, so the archives which make a version visible are
+// deleted first.
+func (publisher Publisher) rollback(written []string) {
+	for i := len(written) - 1; i >= 0; i-- {
+		if err := publisher.bucket.DeleteObject(written[i]); err != nil {
+			logger.Sugar.Errorw("unable to delete file of failed release", "key", written[i], "error", err)
+		}
+	}
+}
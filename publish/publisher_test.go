@@ -0,0 +1,177 @@
+package publish
+
+import (
+	"errors"
+	"github.com/mdreem/s3_terraform_registry/internal/testsupport"
+	"github.com/mdreem/s3_terraform_registry/pgp"
+	"github.com/mdreem/s3_terraform_registry/registryerror"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordingRefresher records the providers which were refreshed and fails with err.
+type recordingRefresher struct {
+	refreshed []string
+	err       error
+}
+
+func (refresher *recordingRefresher) RefreshProvider(namespace string, providerType string) error {
+	refresher.refreshed = append(refresher.refreshed, namespace+"/"+providerType)
+	return refresher.err
+}
+
+func TestPublisher_Publish(t *testing.T) {
+	key := testsupport.NewSigningKey("Dale Cooper")
+	release := newRelease(t, key)
+	bucket := testsupport.NewMemoryBucket(nil)
+	refresher := &recordingRefresher{}
+
+	published, err := NewPublisher(bucket, refresher).Publish(release)
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	if published.Provider != "black/lodge" || published.Version != "1.0.0" || published.KeyID != key.KeyID() {
+		t.Errorf("Publish() got = %+v", published)
+	}
+	objects := bucket.Objects()
+	if len(objects) != 7 || len(published.Files) != 7 {
+		t.Errorf("Publish() wrote %v, reported %v", objects, published.Files)
+	}
+	if objects["black/lodge/1.0.0/shasum"] != string(release.ShaSums) || objects["black/lodge/1.0.0/key_id"] != key.KeyID() ||
+		objects["black/lodge/1.0.0/terraform-provider-lodge_1.0.0_linux_amd64.zip"] != string(archiveContents["terraform-provider-lodge_1.0.0_linux_amd64.zip"]) {
+		t.Errorf("Publish() wrote %v", objects)
+	}
+	if !reflect.DeepEqual(refresher.refreshed, []string{"black/lodge"}) {
+		t.Errorf("Publish() refreshed %v", refresher.refreshed)
+	}
+
+	if _, err := NewPublisher(bucket, refresher).Publish(release); !errors.Is(err, registryerror.ErrConflict) {
+		t.Errorf("Publish() of existing version error = %v, want conflict", err)
+	}
+}
+
+func TestPublisher_PublishRollsBackOnFailure(t *testing.T) {
+	release := newRelease(t, testsupport.NewSigningKey("Dale Cooper"))
+	bucket := testsupport.NewMemoryBucket(map[string]string{"black/lodge/0.9.0/shasum": "old"})
+	bucket.FailPutFor = "black/lodge/1.0.0/terraform-provider-lodge_1.0.0_linux_amd64.zip"
+	refresher := &recordingRefresher{}
+
+	if _, err := NewPublisher(bucket, refresher).Publish(release); !errors.Is(err, registryerror.ErrUpstream) {
+		t.Fatalf("Publish() error = %v, want upstream error", err)
+	}
+
+	if objects := bucket.Objects(); !reflect.DeepEqual(objects, map[string]string{"black/lodge/0.9.0/shasum": "old"}) {
+		t.Errorf("Publish() left %v", objects)
+	}
+	if len(refresher.refreshed) != 0 {
+		t.Errorf("Publish() refreshed %v after failing", refresher.refreshed)
+	}
+}
+
+// blockingBucket blocks writes until release is closed and closes started when the first write begins.
+type blockingBucket struct {
+	*testsupport.MemoryBucket
+	once    *sync.Once
+	started chan struct{}
+	release chan struct{}
+}
+
+func (bucket blockingBucket) PutObject(key string, content io.ReadSeeker) error {
+	bucket.once.Do(func() { close(bucket.started) })
+	<-bucket.release
+	return bucket.MemoryBucket.PutObject(key, content)
+}
+
+func TestPublisher_PublishRejectsConcurrentPublishes(t *testing.T) {
+	release := newRelease(t, testsupport.NewSigningKey("Dale Cooper"))
+	bucket := blockingBucket{
+		MemoryBucket: testsupport.NewMemoryBucket(nil),
+		once:         &sync.Once{},
+		started:      make(chan struct{}),
+		release:      make(chan struct{}),
+	}
+	publisher := NewPublisher(bucket, nil)
+
+	errs := make(chan error)
+	go func() {
+		_, err := publisher.Publish(release)
+		errs <- err
+	}()
+	<-bucket.started
+
+	_, err := publisher.Publish(release)
+	if !errors.Is(err, registryerror.ErrConflict) || !strings.Contains(err.Error(), "is being published") {
+		t.Errorf("Publish() of version being published error = %v, want conflict", err)
+	}
+
+	close(bucket.release)
+	if err := <-errs; err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if _, err := publisher.Publish(release); !errors.Is(err, registryerror.ErrConflict) || strings.Contains(err.Error(), "is being published") {
+		t.Errorf("Publish() of published version error = %v, want conflict about the existing version", err)
+	}
+}
+
+func TestPublisher_PublishReportsFailedRefresh(t *testing.T) {
+	release := newRelease(t, testsupport.NewSigningKey("Dale Cooper"))
+	refresher := &recordingRefresher{err: errors.New("bucket unavailable")}
+
+	published, err := NewPublisher(testsupport.NewMemoryBucket(nil), refresher).Publish(release)
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if len(published.Warnings) != 1 {
+		t.Errorf("Publish() warnings = %v, want a warning about the refresh", published.Warnings)
+	}
+}
+
+func TestPublisher_PublishSignsUnsignedReleases(t *testing.T) {
+	registryKey := testsupport.NewSigningKey("Gordon Cole")
+	signer, err := pgp.ReadSigner(registryKey.ArmoredPrivateKey(""), nil)
+	if err != nil {
+		t.Fatalf("ReadSigner() error = %v", err)
+	}
+	release := newRelease(t, testsupport.NewSigningKey("Dale Cooper"))
+	release.Signature = nil
+	release.PublicKey = ""
+	bucket := testsupport.NewMemoryBucket(nil)
+
+	published, err := NewPublisher(bucket, nil, WithSigner(signer)).Publish(release)
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	if published.KeyID != registryKey.KeyID() {
+		t.Errorf("Publish() key ID = %v, want %v", published.KeyID, registryKey.KeyID())
+	}
+	objects := bucket.Objects()
+	keys, err := pgp.ReadPublicKeys(objects["black/lodge/1.0.0/keyfile"])
+	if err != nil {
+		t.Fatalf("ReadPublicKeys() error = %v", err)
+	}
+	keyID, err := pgp.VerifyDetached(keys, release.ShaSums, []byte(objects["black/lodge/1.0.0/shasum.sig"]))
+	if err != nil || keyID != registryKey.KeyID() {
+		t.Errorf("VerifyDetached() = %v, %v, want %v", keyID, err, registryKey.KeyID())
+	}
+}
+
+func TestPublisher_PublishKeepsSignatureOfRelease(t *testing.T) {
+	key := testsupport.NewSigningKey("Dale Cooper")
+	signer, err := pgp.ReadSigner(testsupport.NewSigningKey("Gordon Cole").ArmoredPrivateKey(""), nil)
+	if err != nil {
+		t.Fatalf("ReadSigner() error = %v", err)
+	}
+
+	published, err := NewPublisher(testsupport.NewMemoryBucket(nil), nil, WithSigner(signer)).Publish(newRelease(t, key))
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if published.KeyID != key.KeyID() {
+		t.Errorf("Publish() key ID = %v, want %v", published.KeyID, key.KeyID())
+	}
+}
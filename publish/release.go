@@ -0,0 +1,187 @@
+package publish
+
+import (
+	"fmt"
+	"github.com/mdreem/s3_terraform_registry/moduledata"
+	"github.com/mdreem/s3_terraform_registry/pgp"
+	"github.com/mdreem/s3_terraform_registry/providerdata"
+	"github.com/mdreem/s3_terraform_registry/registryerror"
+	"github.com/mdreem/s3_terraform_registry/semver"
+	"sort"
+	"strings"
+)
+
+// Release is a version of a provider to be published, consisting of the files goreleaser creates for it.
+type Release struct {
+	Namespace string
+	Type      string
+	Version   string
+	// Archives maps the file names of the zip-files to the archives.
+	Archives map[string]Archive
+	// ShaSums is the SHA256SUMS file listing the hashes of the archives.
+	ShaSums []byte
+	// Signature is the detached signature of ShaSums, either binary or ASCII armored. It is created by the registry if
+	// empty and the registry has a signing key.
+	Signature []byte
+	// PublicKey is the ASCII armored public key which made Signature.
+	PublicKey string
+	// Manifest is the optional terraform-registry-manifest.json.
+	Manifest []byte
+}
+
+// File is a file of a release and its name in the version folder.
+type File struct {
+	Name    string
+	Content []byte
+	// Path is the file containing the content of archives, which are not read into Content.
+	Path string
+}
+
+// Prepared is a validated release.
+type Prepared struct {
+	// KeyID is the ID of the key which signed the shasum file.
+	KeyID string
+	// Files are the files to be written into the version folder in this order. The archives come last, as a version
+	// is listed as soon as one of its archives exists.
+	Files []File
+}
+
+// Prepare validates the release and returns the files to be written into its version folder.
+func (release Release) Prepare() (Prepared, error) {
+	if err := release.validateName(); err != nil {
+		return Prepared{}, err
+	}
+
+	shaSums, err := providerdata.ParseShaSums(string(release.ShaSums))
+	if err != nil {
+		return Prepared{}, registryerror.BadRequest(err, "invalid shasum file: %v", err)
+	}
+	if err := release.validateArchives(shaSums); err != nil {
+		return Prepared{}, err
+	}
+
+	if !release.IsSigned() {
+		return Prepared{}, registryerror.BadRequest(nil, "the shasum file is not signed and the registry has no signing key")
+	}
+	keys, err := pgp.ReadPublicKeys(release.PublicKey)
+	if err != nil {
+		return Prepared{}, registryerror.BadRequest(err, "invalid public key: %v", err)
+	}
+	keyID, err := pgp.VerifyDetached(keys, release.ShaSums, release.Signature)
+	if err != nil {
+		return Prepared{}, registryerror.BadRequest(err, "invalid signature of the shasum file: %v", err)
+	}
+	signature, err := pgp.Dearmor(release.Signature)
+	if err != nil {
+		return Prepared{}, registryerror.BadRequest(err, "invalid signature of the shasum file: %v", err)
+	}
+
+	files := []File{
+		{Name: "keyfile", Content: []byte(release.PublicKey)},
+		{Name: "key_id", Content: []byte(keyID)},
+	}
+	if len(release.Manifest) > 0 {
+		if _, err := providerdata.ParseManifest(string(release.Manifest)); err != nil {
+			return Prepared{}, registryerror.BadRequest(err, "invalid %s: %v", providerdata.ManifestFilename, err)
+		}
+		files = append(files, File{Name: providerdata.ManifestFilename, Content: release.Manifest})
+	}
+
+	filenames := make([]string, 0, len(release.Archives))
+	for filename := range release.Archives {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+	archivePaths := make(map[string]string, len(release.Archives))
+	for filename, archive := range release.Archives {
+		archivePaths[filename] = archive.Path
+	}
+	hashes, err := providerdata.HashArchives(archivePaths)
+	if err != nil {
+		return Prepared{}, registryerror.BadRequest(err, "%v", err)
+	}
+
+	files = append(files,
+		File{Name: providerdata.HashesFilename, Content: hashes},
+		File{Name: "shasum.sig", Content: signature},
+		File{Name: "shasum", Content: release.ShaSums},
+	)
+	for _, filename := range filenames {
+		files = append(files, File{Name: filename, Path: release.Archives[filename].Path})
+	}
+
+	return Prepared{KeyID: keyID, Files: files}, nil
+}
+
+// IsSigned returns whether the release contains a signature of its shasum file.
+func (release Release) IsSigned() bool {
+	return len(release.Signature) > 0
+}
+
+// SignWith returns the release with its shasum file signed by signer.
+func (release Release) SignWith(signer pgp.Signer) (Release, error) {
+	signature, err := signer.Sign(release.ShaSums)
+	if err != nil {
+		return Release{}, err
+	}
+	publicKey, err := signer.ArmoredPublicKey()
+	if err != nil {
+		return Release{}, fmt.Errorf("unable to export public key: %v", err)
+	}
+
+	release.Signature = signature
+	release.PublicKey = publicKey
+	return release, nil
+}
+
+func (release Release) validateName() error {
+	for _, segment := range []string{release.Namespace, release.Type, release.Version} {
+		if segment == "" || strings.Contains(segment, "/") {
+			return registryerror.BadRequest(nil, "%s/%s/%s is not a valid provider version", release.Namespace, release.Type, release.Version)
+		}
+	}
+	if release.Namespace+"/" == moduledata.Prefix {
+		return registryerror.BadRequest(nil, "the namespace %s is reserved for modules", release.Namespace)
+	}
+	if release.Namespace == providerdata.KeysDirectory || release.Type == providerdata.KeysDirectory {
+		return registryerror.BadRequest(nil, "the name %s is reserved for inherited keys", providerdata.KeysDirectory)
+	}
+	if _, err := semver.Parse(release.Version); err != nil {
+		return registryerror.BadRequest(err, "%s is not a valid version", release.Version)
+	}
+	return nil
+}
+
+// validateArchives checks that the archives are exactly the ones listed in the shasum file and match their hashes.
+func (release Release) validateArchives(shaSums map[string]string) error {
+	if len(release.Archives) == 0 {
+		return registryerror.BadRequest(nil, "a release needs at least one archive")
+	}
+
+	for filename, archive := range release.Archives {
+		if _, _, ok := providerdata.ParseArtifactFilename(release.Type, release.Version, filename); !ok {
+			return registryerror.BadRequest(nil, "%s is not named %s", filename, providerdata.ArtifactFilename(release.Type, release.Version, "<os>", "<arch>"))
+		}
+
+		shaSum, ok := shaSums[filename]
+		if !ok {
+			return registryerror.BadRequest(nil, "%s is not listed in the shasum file", filename)
+		}
+		if archive.SHA256 != shaSum {
+			return registryerror.BadRequest(nil, "the hash of %s does not match the shasum file", filename)
+		}
+	}
+
+	for filename := range shaSums {
+		_, _, isArchive := providerdata.ParseArtifactFilename(release.Type, release.Version, filename)
+		if _, ok := release.Archives[filename]; isArchive && !ok {
+			return registryerror.BadRequest(nil, "%s is listed in the shasum file but missing", filename)
+		}
+	}
+	return nil
+}
+
+// String returns the version folder of the release.
+func (release Release) String() string {
+	return fmt.Sprintf("%s/%s/%s", release.Namespace, release.Type, release.Version)
+}
@@ -0,0 +1,221 @@
+package publish
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/mdreem/s3_terraform_registry/internal/testsupport"
+	"github.com/mdreem/s3_terraform_registry/registryerror"
+	"golang.org/x/mod/sumdb/dirhash"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func shaSumsOf(files map[string][]byte) []byte {
+	filenames := make([]string, 0, len(files))
+	for filename := range files {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	shaSums := ""
+	for _, filename := range filenames {
+		hash := sha256.Sum256(files[filename])
+		shaSums += fmt.Sprintf("%s  %s\n", hex.EncodeToString(hash[:]), filename)
+	}
+	return []byte(shaSums)
+}
+
+// archiveContents are the archives of the release created by newRelease.
+var archiveContents = map[string][]byte{
+	"terraform-provider-lodge_1.0.0_linux_amd64.zip":  testsupport.ZipArchive("linux provider"),
+	"terraform-provider-lodge_1.0.0_darwin_arm64.zip": testsupport.ZipArchive("darwin provider"),
+}
+
+func archiveOf(content string) Archive {
+	hash := sha256.Sum256([]byte(content))
+	return Archive{SHA256: hex.EncodeToString(hash[:])}
+}
+
+// newRelease returns a valid release of black/lodge 1.0.0 signed by key. Its archives are written into a temporary
+// directory.
+func newRelease(t *testing.T, key testsupport.SigningKey) Release {
+	directory := t.TempDir()
+	archives := make(map[string]Archive)
+	for filename, content := range archiveContents {
+		archive, err := SpoolArchive(directory, filename, bytes.NewReader(content))
+		if err != nil {
+			t.Fatalf("SpoolArchive() error = %v", err)
+		}
+		archives[filename] = archive
+	}
+
+	shaSums := shaSumsOf(archiveContents)
+	return Release{
+		Namespace: "black",
+		Type:      "lodge",
+		Version:   "1.0.0",
+		Archives:  archives,
+		ShaSums:   shaSums,
+		Signature: key.Sign(shaSums),
+		PublicKey: key.ArmoredPublicKey(),
+	}
+}
+
+func TestRelease_Prepare(t *testing.T) {
+	key := testsupport.NewSigningKey("Dale Cooper")
+
+	release := newRelease(t, key)
+	release.Manifest = []byte(`{"version": 1, "metadata": {"protocol_versions": ["6.0"]}}`)
+	release.Signature = key.ArmoredSign(release.ShaSums)
+
+	prepared, err := release.Prepare()
+	if err != nil {
+		t.Fatalf("Prepare() error = %v", err)
+	}
+	if prepared.KeyID != key.KeyID() {
+		t.Errorf("Prepare() keyID = %v, want %v", prepared.KeyID, key.KeyID())
+	}
+
+	names := make([]string, 0, len(prepared.Files))
+	for _, file := range prepared.Files {
+		names = append(names, file.Name)
+	}
+	wantNames := []string{
+		"keyfile",
+		"key_id",
+		"terraform-registry-manifest.json",
+		"hashes",
+		"shasum.sig",
+		"shasum",
+		"terraform-provider-lodge_1.0.0_darwin_arm64.zip",
+		"terraform-provider-lodge_1.0.0_linux_amd64.zip",
+	}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("Prepare() files = %v, want %v", names, wantNames)
+	}
+	if string(prepared.Files[4].Content) == string(release.Signature) {
+		t.Errorf("Prepare() did not dearmor the signature")
+	}
+
+	// the h1: hashes are computed from the files in the archives
+	wantHashes := ""
+	for _, archive := range []struct{ filename, provider string }{
+		{filename: "terraform-provider-lodge_1.0.0_darwin_arm64.zip", provider: "darwin provider"},
+		{filename: "terraform-provider-lodge_1.0.0_linux_amd64.zip", provider: "linux provider"},
+	} {
+		hash, _ := dirhash.Hash1([]string{"terraform-provider"}, func(string) (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader(archive.provider)), nil
+		})
+		wantHashes += hash + "  " + archive.filename + "\n"
+	}
+	if string(prepared.Files[3].Content) != wantHashes {
+		t.Errorf("Prepare() hashes = %s, want %s", prepared.Files[3].Content, wantHashes)
+	}
+}
+
+func TestRelease_PrepareRejectsInvalidReleases(t *testing.T) {
+	key := testsupport.NewSigningKey("Dale Cooper")
+	otherKey := testsupport.NewSigningKey("Windom Earle")
+
+	tests := []struct {
+		name    string
+		modify  func(release *Release)
+		wantErr string
+	}{
+		{
+			name:    "invalid version",
+			modify:  func(release *Release) { release.Version = "latest" },
+			wantErr: "latest is not a valid version",
+		},
+		{
+			name:    "reserved namespace",
+			modify:  func(release *Release) { release.Namespace = "modules" },
+			wantErr: "the namespace modules is reserved for modules",
+		},
+		{
+			name:    "reserved namespace for keys",
+			modify:  func(release *Release) { release.Namespace = "keys" },
+			wantErr: "the name keys is reserved for inherited keys",
+		},
+		{
+			name:    "reserved type for keys",
+			modify:  func(release *Release) { release.Type = "keys" },
+			wantErr: "the name keys is reserved for inherited keys",
+		},
+		{
+			name:    "no archives",
+			modify:  func(release *Release) { release.Archives = nil },
+			wantErr: "a release needs at least one archive",
+		},
+		{
+			name: "archive of another version",
+			modify: func(release *Release) {
+				release.Archives["terraform-provider-lodge_1.0.1_linux_amd64.zip"] = archiveOf("linux archive")
+			},
+			wantErr: "terraform-provider-lodge_1.0.1_linux_amd64.zip is not named terraform-provider-lodge_1.0.0_<os>_<arch>.zip",
+		},
+		{
+			name: "modified archive",
+			modify: func(release *Release) {
+				release.Archives["terraform-provider-lodge_1.0.0_linux_amd64.zip"] = archiveOf("bob")
+			},
+			wantErr: "the hash of terraform-provider-lodge_1.0.0_linux_amd64.zip does not match the shasum file",
+		},
+		{
+			name: "archive which is not a zip-file",
+			modify: func(release *Release) {
+				_ = os.WriteFile(release.Archives["terraform-provider-lodge_1.0.0_linux_amd64.zip"].Path, []byte("bob"), 0o600)
+			},
+			wantErr: "terraform-provider-lodge_1.0.0_linux_amd64.zip is not a valid zip-file: zip: not a valid zip file",
+		},
+		{
+			name: "missing archive",
+			modify: func(release *Release) {
+				delete(release.Archives, "terraform-provider-lodge_1.0.0_linux_amd64.zip")
+			},
+			wantErr: "terraform-provider-lodge_1.0.0_linux_amd64.zip is listed in the shasum file but missing",
+		},
+		{
+			name:    "signature of another key",
+			modify:  func(release *Release) { release.Signature = otherKey.Sign(release.ShaSums) },
+			wantErr: "invalid signature of the shasum file: the signature does not verify: openpgp: signature made by unknown entity",
+		},
+		{
+			name:    "unsigned",
+			modify:  func(release *Release) { release.Signature = nil },
+			wantErr: "the shasum file is not signed and the registry has no signing key",
+		},
+		{
+			name:    "public key which is not armored",
+			modify:  func(release *Release) { release.PublicKey = "315" },
+			wantErr: "invalid public key: the public key is not ASCII armored",
+		},
+		{
+			name:    "invalid manifest",
+			modify:  func(release *Release) { release.Manifest = []byte(`{}`) },
+			wantErr: "invalid terraform-registry-manifest.json: manifest does not contain any protocol versions",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release := newRelease(t, key)
+			tt.modify(&release)
+
+			_, err := release.Prepare()
+			if !errors.Is(err, registryerror.ErrBadRequest) {
+				t.Fatalf("Prepare() error = %v, want bad request", err)
+			}
+			var registryError *registryerror.Error
+			if errors.As(err, &registryError) && registryError.Message() != tt.wantErr {
+				t.Errorf("Prepare() error = %v, want %v", registryError.Message(), tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package publish
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/mdreem/s3_terraform_registry/logger"
+	"github.com/mdreem/s3_terraform_registry/moduledata"
+	"github.com/mdreem/s3_terraform_registry/providerdata"
+	"github.com/mdreem/s3_terraform_registry/registryerror"
+	"io"
+	"sort"
+	"strings"
+)
+
+// SignUnsigned signs the shasum files of all provider versions in the bucket which have no shasum.sig yet and returns
+// their version folders. If a version does not announce the signing key yet, neither by its own keys nor by the keys
+// it inherits, the key is added to its keys folder. The signature is written last, so versions are picked up again if
+// signing is interrupted. With dryRun nothing is written.
+func (publisher Publisher) SignUnsigned(dryRun bool) ([]string, error) {
+	if publisher.signer == nil {
+		return nil, errors.New("no signing key configured")
+	}
+
+	unsigned, err := publisher.unsignedVersions()
+	if err != nil {
+		return nil, err
+	}
+	if dryRun {
+		return unsigned, nil
+	}
+
+	publicKey, err := publisher.signer.ArmoredPublicKey()
+	if err != nil {
+		return nil, fmt.Errorf("unable to export public key: %v", err)
+	}
+
+	signed := make([]string, 0, len(unsigned))
+	for _, versionPath := range unsigned {
+		if err := publisher.signVersion(versionPath, publicKey); err != nil {
+			return signed, fmt.Errorf("unable to sign %s: %v", versionPath, err)
+		}
+		logger.Sugar.Infow("signed version", "version", versionPath, "keyID", publisher.signer.KeyID())
+		signed = append(signed, versionPath)
+
+		if publisher.refresher != nil {
+			parts := strings.Split(versionPath, "/")
+			if err := publisher.refresher.RefreshProvider(parts[0], parts[1]); err != nil {
+				logger.Sugar.Errorw("unable to refresh signed provider", "version", versionPath, "error", err)
+			}
+		}
+	}
+	return signed, nil
+}
+
+// unsignedVersions returns the sorted version folders of all providers which contain a shasum but no shasum.sig.
+func (publisher Publisher) unsignedVersions() ([]string, error) {
+	objects, err := publisher.bucket.ListObjects()
+	if err != nil {
+		return nil, err
+	}
+
+	shaSums := make(map[string]bool)
+	signatures := make(map[string]bool)
+	for _, object := range objects {
+		if strings.HasPrefix(object, moduledata.Prefix) {
+			continue
+		}
+		parts := strings.Split(object, "/")
+		if len(parts) != 4 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			continue
+		}
+
+		versionPath := strings.Join(parts[:3], "/")
+		switch parts[3] {
+		case "shasum":
+			shaSums[versionPath] = true
+		case "shasum.sig":
+			signatures[versionPath] = true
+		}
+	}
+
+	unsigned := make([]string, 0)
+	for versionPath := range shaSums {
+		if !signatures[versionPath] {
+			unsigned = append(unsigned, versionPath)
+		}
+	}
+	sort.Strings(unsigned)
+	return unsigned, nil
+}
+
+func (publisher Publisher) signVersion(versionPath string, publicKey string) error {
+	object, err := publisher.bucket.GetObject(versionPath + "/shasum")
+	if err != nil {
+		return err
+	}
+	shaSums, err := io.ReadAll(object.Body)
+	_ = object.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	signature, err := publisher.signer.Sign(shaSums)
+	if err != nil {
+		return err
+	}
+
+	files := make([]File, 0, 2)
+	announced, err := publisher.announcesSigningKey(versionPath)
+	if err != nil {
+		return err
+	}
+	if !announced {
+		keyFile := fmt.Sprintf("%s/%s.asc", providerdata.KeysDirectory, publisher.signer.KeyID())
+		files = append(files, File{Name: keyFile, Content: []byte(publicKey)})
+	}
+	files = append(files, File{Name: "shasum.sig", Content: signature})
+	for _, file := range files {
+		if err := publisher.bucket.PutObject(versionPath+"/"+file.Name, bytes.NewReader(file.Content)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// announcesSigningKey checks whether the version in versionPath already announces the signing key. Keys which cannot
+// be found, e.g. a keyfile without key_id, do not count as announced.
+func (publisher Publisher) announcesSigningKey(versionPath string) (bool, error) {
+	keys, err := providerdata.SigningKeys(publisher.bucket, versionPath)
+	if errors.Is(err, registryerror.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	for _, key := range keys {
+		if strings.EqualFold(strings.TrimSpace(key.KeyID), publisher.signer.KeyID()) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
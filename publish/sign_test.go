@@ -0,0 +1,124 @@
+package publish
+
+import (
+	"github.com/mdreem/s3_terraform_registry/internal/testsupport"
+	"github.com/mdreem/s3_terraform_registry/pgp"
+	"github.com/mdreem/s3_terraform_registry/providerdata"
+	"reflect"
+	"testing"
+)
+
+func TestPublisher_SignUnsigned(t *testing.T) {
+	registryKey := testsupport.NewSigningKey("Gordon Cole")
+	authorKey := testsupport.NewSigningKey("Dale Cooper")
+	signer, err := pgp.ReadSigner(registryKey.ArmoredPrivateKey(""), nil)
+	if err != nil {
+		t.Fatalf("ReadSigner() error = %v", err)
+	}
+	initialObjects := map[string]string{
+		"black/lodge/1.0.0/shasum":                "315  terraform-provider-lodge_1.0.0_linux_amd64.zip",
+		"black/lodge/1.0.0/shasum.sig":            "signed by its author",
+		"black/lodge/1.0.0/keyfile":               "key of its author",
+		"black/lodge/1.0.0/key_id":                "AUTHOR",
+		"black/lodge/1.1.0/shasum":                "316  terraform-provider-lodge_1.1.0_linux_amd64.zip",
+		"white/lodge/2.0.0/shasum":                "317  terraform-provider-lodge_2.0.0_linux_amd64.zip",
+		"white/lodge/2.0.0/keyfile":               authorKey.ArmoredPublicKey(),
+		"white/lodge/2.0.0/key_id":                authorKey.KeyID(),
+		"modules/black/lodge/aws/1.0.0/lodge.zip": "module",
+	}
+
+	t.Run("dry run", func(t *testing.T) {
+		bucket := testsupport.NewMemoryBucket(initialObjects)
+
+		got, err := NewPublisher(bucket, nil, WithSigner(signer)).SignUnsigned(true)
+		if err != nil {
+			t.Fatalf("SignUnsigned() error = %v", err)
+		}
+		if want := []string{"black/lodge/1.1.0", "white/lodge/2.0.0"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("SignUnsigned() got = %v, want %v", got, want)
+		}
+		if !reflect.DeepEqual(bucket.Objects(), initialObjects) {
+			t.Errorf("SignUnsigned() modified the bucket in a dry run: %v", bucket.Objects())
+		}
+	})
+
+	t.Run("sign", func(t *testing.T) {
+		bucket := testsupport.NewMemoryBucket(initialObjects)
+		refresher := &recordingRefresher{}
+
+		got, err := NewPublisher(bucket, refresher, WithSigner(signer)).SignUnsigned(false)
+		if err != nil {
+			t.Fatalf("SignUnsigned() error = %v", err)
+		}
+		if want := []string{"black/lodge/1.1.0", "white/lodge/2.0.0"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("SignUnsigned() got = %v, want %v", got, want)
+		}
+		if want := []string{"black/lodge", "white/lodge"}; !reflect.DeepEqual(refresher.refreshed, want) {
+			t.Errorf("SignUnsigned() refreshed %v, want %v", refresher.refreshed, want)
+		}
+
+		objects := bucket.Objects()
+		if objects["black/lodge/1.0.0/shasum.sig"] != "signed by its author" || objects["black/lodge/1.0.0/key_id"] != "AUTHOR" {
+			t.Errorf("SignUnsigned() modified the signed version: %v", objects)
+		}
+		if objects["white/lodge/2.0.0/keyfile"] != authorKey.ArmoredPublicKey() || objects["white/lodge/2.0.0/key_id"] != authorKey.KeyID() {
+			t.Errorf("SignUnsigned() replaced the keys of white/lodge/2.0.0: %v", objects)
+		}
+		for _, versionPath := range got {
+			if objects[versionPath+"/keys/"+registryKey.KeyID()+".asc"] != registryKey.ArmoredPublicKey() {
+				t.Errorf("SignUnsigned() did not add the signing key to the keys of %s: %v", versionPath, objects)
+			}
+			expectAnnouncedSignature(t, bucket, versionPath, registryKey.KeyID())
+		}
+	})
+
+	t.Run("sign with inherited keys", func(t *testing.T) {
+		objects := map[string]string{
+			"black/keys/registry.asc":  registryKey.ArmoredPublicKey(),
+			"black/lodge/1.1.0/shasum": "316  terraform-provider-lodge_1.1.0_linux_amd64.zip",
+		}
+		bucket := testsupport.NewMemoryBucket(objects)
+
+		if _, err := NewPublisher(bucket, nil, WithSigner(signer)).SignUnsigned(false); err != nil {
+			t.Fatalf("SignUnsigned() error = %v", err)
+		}
+
+		objects["black/lodge/1.1.0/shasum.sig"] = bucket.Objects()["black/lodge/1.1.0/shasum.sig"]
+		if !reflect.DeepEqual(bucket.Objects(), objects) {
+			t.Errorf("SignUnsigned() wrote more than the signature: %v", bucket.Objects())
+		}
+		expectAnnouncedSignature(t, bucket, "black/lodge/1.1.0", registryKey.KeyID())
+	})
+
+	t.Run("without signing key", func(t *testing.T) {
+		if _, err := NewPublisher(testsupport.NewMemoryBucket(initialObjects), nil).SignUnsigned(true); err == nil {
+			t.Errorf("SignUnsigned() without signing key succeeded")
+		}
+	})
+}
+
+// expectAnnouncedSignature checks that the version in versionPath announces the key keyID and that shasum.sig is a
+// signature of shasum made by it.
+func expectAnnouncedSignature(t *testing.T, bucket *testsupport.MemoryBucket, versionPath string, keyID string) {
+	t.Helper()
+
+	keys, err := providerdata.SigningKeys(bucket, versionPath)
+	if err != nil {
+		t.Fatalf("SigningKeys() of %s error = %v", versionPath, err)
+	}
+	for _, key := range keys {
+		if key.KeyID != keyID {
+			continue
+		}
+		entities, err := pgp.ReadPublicKeys(key.ASCIIArmor)
+		if err != nil {
+			t.Fatalf("ReadPublicKeys() error = %v", err)
+		}
+		objects := bucket.Objects()
+		if _, err := pgp.VerifyDetached(entities, []byte(objects[versionPath+"/shasum"]), []byte(objects[versionPath+"/shasum.sig"])); err != nil {
+			t.Errorf("VerifyDetached() of %s error = %v", versionPath, err)
+		}
+		return
+	}
+	t.Errorf("%s does not announce the key %s: %v", versionPath, keyID, keys)
+}
@@ -0,0 +1,90 @@
+package registryerror
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The kinds of errors which are reported to clients. Check for them with errors.Is.
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrBadRequest = errors.New("bad request")
+	ErrUpstream   = errors.New("upstream failure")
+	// ErrRangeNotSatisfiable is reported if a requested range does not overlap the requested file.
+	ErrRangeNotSatisfiable = errors.New("range not satisfiable")
+	// ErrUnauthorized is reported if a request lacks valid credentials.
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrForbidden is reported if the credentials of a request do not allow it.
+	ErrForbidden = errors.New("forbidden")
+	// ErrConflict is reported if a request would overwrite existing data.
+	ErrConflict = errors.New("conflict")
+	// ErrTooLarge is reported if a request exceeds the size the registry accepts.
+	ErrTooLarge = errors.New("too large")
+)
+
+// Error carries a message which can be shown to clients together with the kind of the error and its cause.
+type Error struct {
+	kind    error
+	message string
+	cause   error
+}
+
+func NotFound(cause error, format string, a ...interface{}) error {
+	return newError(ErrNotFound, cause, format, a...)
+}
+
+func BadRequest(cause error, format string, a ...interface{}) error {
+	return newError(ErrBadRequest, cause, format, a...)
+}
+
+func Upstream(cause error, format string, a ...interface{}) error {
+	return newError(ErrUpstream, cause, format, a...)
+}
+
+func RangeNotSatisfiable(cause error, format string, a ...interface{}) error {
+	return newError(ErrRangeNotSatisfiable, cause, format, a...)
+}
+
+func Unauthorized(cause error, format string, a ...interface{}) error {
+	return newError(ErrUnauthorized, cause, format, a...)
+}
+
+func Forbidden(cause error, format string, a ...interface{}) error {
+	return newError(ErrForbidden, cause, format, a...)
+}
+
+func Conflict(cause error, format string, a ...interface{}) error {
+	return newError(ErrConflict, cause, format, a...)
+}
+
+func TooLarge(cause error, format string, a ...interface{}) error {
+	return newError(ErrTooLarge, cause, format, a...)
+}
+
+func newError(kind error, cause error, format string, a ...interface{}) error {
+	return &Error{
+		kind:    kind,
+		message: fmt.Sprintf(format, a...),
+		cause:   cause,
+	}
+}
+
+func (err *Error) Error() string {
+	if err.cause == nil {
+		return err.message
+	}
+	return fmt.Sprintf("%s: %v", err.message, err.cause)
+}
+
+// Message returns the message without the cause, as the cause may contain details not meant for clients.
+func (err *Error) Message() string {
+	return err.message
+}
+
+func (err *Error) Is(target error) bool {
+	return target == err.kind
+}
+
+func (err *Error) Unwrap() error {
+	return err.cause
+}
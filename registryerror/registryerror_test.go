@@ -0,0 +1,64 @@
+package registryerror
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestError_Is(t *testing.T) {
+	cause := errors.New("NoSuchKey: The specified key does not exist")
+
+	tests := []struct {
+		name        string
+		err         error
+		wantKind    error
+		wantMessage string
+		wantError   string
+	}{
+		{
+			name:        "not found with cause",
+			err:         NotFound(cause, "unable to find %s", "black/lodge/1.0.0/shasum"),
+			wantKind:    ErrNotFound,
+			wantMessage: "unable to find black/lodge/1.0.0/shasum",
+			wantError:   "unable to find black/lodge/1.0.0/shasum: NoSuchKey: The specified key does not exist",
+		},
+		{
+			name:        "bad request without cause",
+			err:         BadRequest(nil, "invalid version %s", "latest"),
+			wantKind:    ErrBadRequest,
+			wantMessage: "invalid version latest",
+			wantError:   "invalid version latest",
+		},
+		{
+			name:        "wrapped upstream failure",
+			err:         fmt.Errorf("refreshing failed: %w", Upstream(cause, "unable to list objects")),
+			wantKind:    ErrUpstream,
+			wantMessage: "unable to list objects",
+			wantError:   "refreshing failed: unable to list objects: NoSuchKey: The specified key does not exist",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, kind := range []error{ErrNotFound, ErrBadRequest, ErrUpstream} {
+				if errors.Is(tt.err, kind) != (kind == tt.wantKind) {
+					t.Errorf("errors.Is(%v, %v) = %v", tt.err, kind, errors.Is(tt.err, kind))
+				}
+			}
+			if !errors.Is(tt.err, cause) && tt.wantKind != ErrBadRequest {
+				t.Errorf("cause is not part of the error chain")
+			}
+
+			var registryError *Error
+			if !errors.As(tt.err, &registryError) {
+				t.Fatalf("errors.As() did not find registry error")
+			}
+			if registryError.Message() != tt.wantMessage {
+				t.Errorf("Message() got = %v, want %v", registryError.Message(), tt.wantMessage)
+			}
+			if tt.err.Error() != tt.wantError {
+				t.Errorf("Error() got = %v, want %v", tt.err.Error(), tt.wantError)
+			}
+		})
+	}
+}
@@ -3,11 +3,14 @@ package s3
 import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 )
 
 type BucketReaderWriter interface {
 	ListObjects
 	GetObject
+	PutObject
 }
 
 type Bucket struct {
@@ -24,3 +27,7 @@ var CreateSession = func(region string) *session.Session {
 		Region: aws.String(region),
 	}))
 }
+
+var CreateClient = func(region string) s3iface.S3API {
+	return s3.New(CreateSession(region))
+}
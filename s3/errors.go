@@ -0,0 +1,33 @@
+package s3
+
+import (
+	"errors"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/mdreem/s3_terraform_registry/registryerror"
+	"net/http"
+)
+
+// classifyError maps errors returned by S3 to the kinds of errors the registry reports to its clients.
+func classifyError(err error, format string, a ...interface{}) error {
+	var requestFailure awserr.RequestFailure
+	if errors.As(err, &requestFailure) && requestFailure.StatusCode() == http.StatusNotFound && requestFailure.Code() != s3.ErrCodeNoSuchBucket {
+		return registryerror.NotFound(err, format, a...)
+	}
+	if errors.As(err, &requestFailure) && requestFailure.StatusCode() == http.StatusRequestedRangeNotSatisfiable {
+		return registryerror.RangeNotSatisfiable(err, format, a...)
+	}
+
+	var awsError awserr.Error
+	if errors.As(err, &awsError) && awsError.Code() == s3.ErrCodeNoSuchKey {
+		return registryerror.NotFound(err, format, a...)
+	}
+
+	return registryerror.Upstream(err, format, a...)
+}
+
+// isNotModified checks whether err reports that a conditional request matched the current version of the object.
+func isNotModified(err error) bool {
+	var requestFailure awserr.RequestFailure
+	return errors.As(err, &requestFailure) && requestFailure.StatusCode() == http.StatusNotModified
+}
@@ -0,0 +1,59 @@
+package s3
+
+import (
+	"errors"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/mdreem/s3_terraform_registry/registryerror"
+	"net/http"
+	"testing"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantKind error
+	}{
+		{
+			name:     "missing key is not found",
+			err:      awserr.NewRequestFailure(awserr.New("NoSuchKey", "The specified key does not exist.", nil), http.StatusNotFound, "id"),
+			wantKind: registryerror.ErrNotFound,
+		},
+		{
+			name:     "missing key without request failure is not found",
+			err:      awserr.New("NoSuchKey", "The specified key does not exist.", nil),
+			wantKind: registryerror.ErrNotFound,
+		},
+		{
+			name:     "missing bucket is an upstream failure",
+			err:      awserr.NewRequestFailure(awserr.New("NoSuchBucket", "The specified bucket does not exist", nil), http.StatusNotFound, "id"),
+			wantKind: registryerror.ErrUpstream,
+		},
+		{
+			name:     "access denied is an upstream failure",
+			err:      awserr.NewRequestFailure(awserr.New("AccessDenied", "Access Denied", nil), http.StatusForbidden, "id"),
+			wantKind: registryerror.ErrUpstream,
+		},
+		{
+			name:     "invalid range is not satisfiable",
+			err:      awserr.NewRequestFailure(awserr.New("InvalidRange", "The requested range is not satisfiable", nil), http.StatusRequestedRangeNotSatisfiable, "id"),
+			wantKind: registryerror.ErrRangeNotSatisfiable,
+		},
+		{
+			name:     "network errors are upstream failures",
+			err:      errors.New("connection reset by peer"),
+			wantKind: registryerror.ErrUpstream,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := classifyError(tt.err, "unable to get %s", "black/lodge/1.0.0/shasum")
+			if !errors.Is(got, tt.wantKind) {
+				t.Errorf("classifyError() got = %v, want kind %v", got, tt.wantKind)
+			}
+			if !errors.Is(got, tt.err) {
+				t.Errorf("classifyError() lost the cause %v", tt.err)
+			}
+		})
+	}
+}
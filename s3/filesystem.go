@@ -0,0 +1,367 @@
+package s3
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mdreem/s3_terraform_registry/logger"
+	"github.com/mdreem/s3_terraform_registry/registryerror"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ListFingerprints is implemented by storages which send no event notifications. The fingerprint of an object changes
+// whenever the object changes, so changes can be detected by comparing listings.
+type ListFingerprints interface {
+	ListFingerprints() (map[string]string, error)
+}
+
+// Filesystem stores objects as files below a root directory, using the keys as paths. Files and directories whose
+// names start with a dot are hidden, they are used for files which are being written.
+type Filesystem struct {
+	root string
+}
+
+func NewFilesystem(root string) (Filesystem, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return Filesystem{}, fmt.Errorf("unable to open root directory: %v", err)
+	}
+	if !info.IsDir() {
+		return Filesystem{}, fmt.Errorf("%s is not a directory", root)
+	}
+	return Filesystem{root: root}, nil
+}
+
+func (filesystem Filesystem) ListObjects() ([]string, error) {
+	return filesystem.ListObjectsWithPrefix("", "")
+}
+
+// ListObjectsWithPrefix returns all keys starting with prefix in lexical order. If a delimiter is given, keys
+// containing it after the prefix are rolled up into their common prefix like S3 does.
+func (filesystem Filesystem) ListObjectsWithPrefix(prefix string, delimiter string) ([]string, error) {
+	files, err := filesystem.walk(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make([]string, 0, len(files))
+	seenPrefixes := make(map[string]bool)
+	for _, file := range files {
+		key := file.key
+		if delimiter != "" {
+			if index := strings.Index(strings.TrimPrefix(key, prefix), delimiter); index >= 0 {
+				key = key[:len(prefix)+index+len(delimiter)]
+				if seenPrefixes[key] {
+					continue
+				}
+				seenPrefixes[key] = true
+			}
+		}
+		objects = append(objects, key)
+	}
+	return objects, nil
+}
+
+// ListFingerprints returns the fingerprints of all objects, which are their ETags.
+func (filesystem Filesystem) ListFingerprints() (map[string]string, error) {
+	return filesystem.ListETagsWithPrefix("")
+}
+
+// ListETagsWithPrefix returns the ETags of all objects whose keys start with prefix.
+func (filesystem Filesystem) ListETagsWithPrefix(prefix string) (map[string]string, error) {
+	files, err := filesystem.walk(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	eTags := make(map[string]string, len(files))
+	for _, file := range files {
+		eTags[file.key] = eTag(file.info)
+	}
+	return eTags, nil
+}
+
+type filesystemObject struct {
+	key  string
+	info fs.FileInfo
+}
+
+// walk returns the files whose keys start with prefix, sorted by their keys. Only the directory containing the prefix
+// is walked.
+func (filesystem Filesystem) walk(prefix string) ([]filesystemObject, error) {
+	files := make([]filesystemObject, 0)
+	prefixDirectory := prefix[:strings.LastIndex(prefix, "/")+1]
+	if prefixDirectory != "" && !fs.ValidPath(strings.TrimSuffix(prefixDirectory, "/")) {
+		return files, nil
+	}
+	directory := filepath.Join(filesystem.root, filepath.FromSlash(prefixDirectory))
+
+	err := filepath.WalkDir(directory, func(filePath string, entry fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && filePath == directory {
+			return filepath.SkipDir
+		}
+		if err != nil {
+			return err
+		}
+		if filePath != directory && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(filesystem.root, filePath)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(relativePath)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			// deleted while walking
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		files = append(files, filesystemObject{key: key, info: info})
+		return nil
+	})
+	if err != nil {
+		logger.Sugar.Errorw("an error occurred when listing files", "prefix", prefix, "error", err)
+		return nil, registryerror.Upstream(err, "unable to list objects with prefix %s", prefix)
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].key < files[j].key
+	})
+	return files, nil
+}
+
+func (filesystem Filesystem) GetObject(key string) (BucketObject, error) {
+	return filesystem.GetObjectWithOptions(key, GetObjectOptions{})
+}
+
+// GetObjectWithOptions opens the file of key. Ranges and conditions are evaluated like S3 does: only single byte
+// ranges are supported, other ranges are ignored and the whole file is returned.
+func (filesystem Filesystem) GetObjectWithOptions(key string, options GetObjectOptions) (BucketObject, error) {
+	filePath, err := filesystem.path(key)
+	if err != nil {
+		return BucketObject{}, err
+	}
+
+	file, err := os.Open(filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return BucketObject{}, registryerror.NotFound(err, "unable to get %s", key)
+	}
+	if err != nil {
+		return BucketObject{}, registryerror.Upstream(err, "unable to get %s", key)
+	}
+
+	object, err := readObject(file, options)
+	if err != nil || object.Body == nil {
+		_ = file.Close()
+	}
+	if errors.Is(err, errRangeNotSatisfiable) {
+		return BucketObject{}, registryerror.RangeNotSatisfiable(err, "unable to get %s", key)
+	}
+	if err != nil {
+		return BucketObject{}, registryerror.Upstream(err, "unable to get %s", key)
+	}
+	return object, nil
+}
+
+var errRangeNotSatisfiable = errors.New("range not satisfiable")
+
+// readObject returns the object stored in file. Its body is nil if the object is not modified or only its metadata
+// is requested.
+func readObject(file *os.File, options GetObjectOptions) (BucketObject, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return BucketObject{}, err
+	}
+	if info.IsDir() {
+		return BucketObject{}, fmt.Errorf("%s is a directory", info.Name())
+	}
+
+	object := BucketObject{
+		ContentLength: info.Size(),
+		ContentType:   contentType(info.Name()),
+		ETag:          eTag(info),
+		LastModified:  info.ModTime(),
+	}
+
+	if isNotModifiedFile(object, options) {
+		return BucketObject{NotModified: true}, nil
+	}
+	if options.HeadOnly {
+		// ranges are ignored, as HEAD requests are answered with the metadata of the whole object
+		return object, nil
+	}
+
+	start, end, ok, satisfiable := parseRange(options.Range, info.Size())
+	if !satisfiable {
+		return BucketObject{}, fmt.Errorf("%w: %s of %d bytes", errRangeNotSatisfiable, options.Range, info.Size())
+	}
+	if !ok {
+		object.Body = file
+		return object, nil
+	}
+
+	if _, err := file.Seek(start, io.SeekStart); err != nil {
+		return BucketObject{}, err
+	}
+	object.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(file, end-start+1), file}
+	object.ContentLength = end - start + 1
+	object.ContentRange = fmt.Sprintf("bytes %d-%d/%d", start, end, info.Size())
+	return object, nil
+}
+
+// isNotModifiedFile evaluates the conditions of the request. If-None-Match takes precedence over If-Modified-Since.
+func isNotModifiedFile(object BucketObject, options GetObjectOptions) bool {
+	if options.IfNoneMatch != "" {
+		return options.IfNoneMatch == "*" || options.IfNoneMatch == object.ETag
+	}
+	if !options.IfModifiedSince.IsZero() {
+		return !object.LastModified.Truncate(time.Second).After(options.IfModifiedSince)
+	}
+	return false
+}
+
+// parseRange parses a single byte range of the form bytes=<start>-<end>, bytes=<start>- or bytes=-<suffix length>. It
+// returns whether a range was requested and whether it is satisfiable.
+func parseRange(byteRange string, size int64) (int64, int64, bool, bool) {
+	if !strings.HasPrefix(byteRange, "bytes=") || strings.Contains(byteRange, ",") {
+		return 0, 0, false, true
+	}
+	startValue, endValue, found := strings.Cut(strings.TrimSpace(strings.TrimPrefix(byteRange, "bytes=")), "-")
+	if !found {
+		return 0, 0, false, true
+	}
+
+	if startValue == "" {
+		suffixLength, err := strconv.ParseInt(endValue, 10, 64)
+		if err != nil || suffixLength < 0 {
+			return 0, 0, false, true
+		}
+		if suffixLength == 0 || size == 0 {
+			return 0, 0, false, false
+		}
+		if suffixLength > size {
+			suffixLength = size
+		}
+		return size - suffixLength, size - 1, true, true
+	}
+
+	start, err := strconv.ParseInt(startValue, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, false, true
+	}
+	end := size - 1
+	if endValue != "" {
+		if end, err = strconv.ParseInt(endValue, 10, 64); err != nil || end < start {
+			return 0, 0, false, true
+		}
+		if end > size-1 {
+			end = size - 1
+		}
+	}
+	if start >= size {
+		return 0, 0, false, false
+	}
+	return start, end, true, true
+}
+
+// PutObject writes content into a hidden file first and renames it, so readers never see partially written files.
+func (filesystem Filesystem) PutObject(key string, content io.ReadSeeker) error {
+	filePath, err := filesystem.path(key)
+	if err != nil {
+		return err
+	}
+
+	directory := filepath.Dir(filePath)
+	if err := os.MkdirAll(directory, 0o755); err != nil {
+		return registryerror.Upstream(err, "unable to put %s", key)
+	}
+
+	file, err := os.CreateTemp(directory, "."+filepath.Base(filePath)+".*")
+	if err != nil {
+		return registryerror.Upstream(err, "unable to put %s", key)
+	}
+	_, err = io.Copy(file, content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(file.Name(), 0o644)
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), filePath)
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+		logger.Sugar.Errorw("an error occurred when putting object", "key", key, "error", err)
+		return registryerror.Upstream(err, "unable to put %s", key)
+	}
+	return nil
+}
+
+// DeleteObject removes the file of key and the directories which became empty. Like S3, deleting a missing object
+// succeeds.
+func (filesystem Filesystem) DeleteObject(key string) error {
+	filePath, err := filesystem.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(filePath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		logger.Sugar.Errorw("an error occurred when deleting object", "key", key, "error", err)
+		return registryerror.Upstream(err, "unable to delete %s", key)
+	}
+
+	root := filepath.Clean(filesystem.root)
+	for directory := filepath.Dir(filePath); directory != root && strings.HasPrefix(directory, root); directory = filepath.Dir(directory) {
+		// fails if the directory is not empty
+		if os.Remove(directory) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// path returns the path of the file of key. Keys which could point outside of the root directory are rejected.
+func (filesystem Filesystem) path(key string) (string, error) {
+	if !fs.ValidPath(key) || key == "." {
+		return "", registryerror.NotFound(nil, "%s is not a valid key", key)
+	}
+	return filepath.Join(filesystem.root, filepath.FromSlash(key)), nil
+}
+
+// eTag derives an ETag from the size and the modification time of a file, so it is not read to compute it.
+func eTag(info fs.FileInfo) string {
+	return fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size())
+}
+
+func contentType(filename string) string {
+	if mimeType := mime.TypeByExtension(path.Ext(filename)); mimeType != "" {
+		return mimeType
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,261 @@
+package s3_test
+
+import (
+	"errors"
+	"github.com/mdreem/s3_terraform_registry/registryerror"
+	"github.com/mdreem/s3_terraform_registry/s3"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newFilesystem(t *testing.T, files map[string]string) (s3.Filesystem, string) {
+	root := t.TempDir()
+	for key, content := range files {
+		filePath := filepath.Join(root, filepath.FromSlash(key))
+		if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+			t.Fatalf("unable to create directory: %v", err)
+		}
+		if err := os.WriteFile(filePath, []byte(content), 0o644); err != nil {
+			t.Fatalf("unable to write file: %v", err)
+		}
+	}
+
+	filesystem, err := s3.NewFilesystem(root)
+	if err != nil {
+		t.Fatalf("NewFilesystem() error = %v", err)
+	}
+	return filesystem, root
+}
+
+func TestFilesystem_ListObjectsWithPrefix(t *testing.T) {
+	filesystem, _ := newFilesystem(t, map[string]string{
+		"black/lodge/1.0.0/shasum":       "315",
+		"black/lodge/1.0.0/keys/key.asc": "key",
+		"black/lodge/1.1.0/shasum":       "316",
+		"black/lodge/.1.2.0/shasum":      "hidden",
+		"black/lodge/1.2.0/.shasum.tmp":  "being written",
+		"white/lodge/1.0.0/shasum":       "317",
+	})
+
+	tests := []struct {
+		name      string
+		prefix    string
+		delimiter string
+		want      []string
+	}{
+		{
+			name:   "all objects",
+			prefix: "",
+			want: []string{
+				"black/lodge/1.0.0/keys/key.asc",
+				"black/lodge/1.0.0/shasum",
+				"black/lodge/1.1.0/shasum",
+				"white/lodge/1.0.0/shasum",
+			},
+		},
+		{
+			name:   "prefix within a directory name",
+			prefix: "black/lodge/1.0",
+			want:   []string{"black/lodge/1.0.0/keys/key.asc", "black/lodge/1.0.0/shasum"},
+		},
+		{
+			name:      "delimiter",
+			prefix:    "black/lodge/1.0.0/",
+			delimiter: "/",
+			want:      []string{"black/lodge/1.0.0/keys/", "black/lodge/1.0.0/shasum"},
+		},
+		{
+			name:   "missing directory",
+			prefix: "red/room/",
+			want:   []string{},
+		},
+		{
+			name:   "prefix outside of the root",
+			prefix: "../",
+			want:   []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := filesystem.ListObjectsWithPrefix(tt.prefix, tt.delimiter)
+			if err != nil {
+				t.Fatalf("ListObjectsWithPrefix() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ListObjectsWithPrefix() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilesystem_GetObjectWithOptions(t *testing.T) {
+	filesystem, root := newFilesystem(t, map[string]string{"black/lodge/1.0.0/shasum": "315 coffee"})
+	lastModified := time.Date(1989, 2, 24, 11, 30, 0, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(root, "black/lodge/1.0.0/shasum"), lastModified, lastModified); err != nil {
+		t.Fatalf("unable to set modification time: %v", err)
+	}
+	head, err := filesystem.GetObjectWithOptions("black/lodge/1.0.0/shasum", s3.GetObjectOptions{HeadOnly: true})
+	if err != nil {
+		t.Fatalf("GetObjectWithOptions() error = %v", err)
+	}
+
+	tests := []struct {
+		name             string
+		key              string
+		options          s3.GetObjectOptions
+		wantBody         string
+		wantContentRange string
+		wantNotModified  bool
+		wantErr          error
+	}{
+		{
+			name:     "whole object",
+			key:      "black/lodge/1.0.0/shasum",
+			wantBody: "315 coffee",
+		},
+		{
+			name:             "range",
+			key:              "black/lodge/1.0.0/shasum",
+			options:          s3.GetObjectOptions{Range: "bytes=4-"},
+			wantBody:         "coffee",
+			wantContentRange: "bytes 4-9/10",
+		},
+		{
+			name:             "suffix range",
+			key:              "black/lodge/1.0.0/shasum",
+			options:          s3.GetObjectOptions{Range: "bytes=-3"},
+			wantBody:         "fee",
+			wantContentRange: "bytes 7-9/10",
+		},
+		{
+			name:     "several ranges are ignored",
+			key:      "black/lodge/1.0.0/shasum",
+			options:  s3.GetObjectOptions{Range: "bytes=0-1,4-5"},
+			wantBody: "315 coffee",
+		},
+		{
+			name:    "range after the end",
+			key:     "black/lodge/1.0.0/shasum",
+			options: s3.GetObjectOptions{Range: "bytes=10-"},
+			wantErr: registryerror.ErrRangeNotSatisfiable,
+		},
+		{
+			name:            "matching ETag",
+			key:             "black/lodge/1.0.0/shasum",
+			options:         s3.GetObjectOptions{IfNoneMatch: head.ETag},
+			wantNotModified: true,
+		},
+		{
+			name:     "other ETag",
+			key:      "black/lodge/1.0.0/shasum",
+			options:  s3.GetObjectOptions{IfNoneMatch: `"coffee"`},
+			wantBody: "315 coffee",
+		},
+		{
+			name:            "not modified since",
+			key:             "black/lodge/1.0.0/shasum",
+			options:         s3.GetObjectOptions{IfModifiedSince: lastModified},
+			wantNotModified: true,
+		},
+		{
+			name:     "modified since",
+			key:      "black/lodge/1.0.0/shasum",
+			options:  s3.GetObjectOptions{IfModifiedSince: lastModified.Add(-time.Hour)},
+			wantBody: "315 coffee",
+		},
+		{
+			name:    "missing object",
+			key:     "black/lodge/1.0.0/shasum.sig",
+			wantErr: registryerror.ErrNotFound,
+		},
+		{
+			name:    "key outside of the root",
+			key:     "../shasum",
+			wantErr: registryerror.ErrNotFound,
+		},
+		{
+			name:    "directory",
+			key:     "black/lodge/1.0.0",
+			wantErr: registryerror.ErrUpstream,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := filesystem.GetObjectWithOptions(tt.key, tt.options)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("GetObjectWithOptions() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetObjectWithOptions() error = %v", err)
+			}
+			if got.NotModified != tt.wantNotModified {
+				t.Fatalf("GetObjectWithOptions() not modified = %v, want %v", got.NotModified, tt.wantNotModified)
+			}
+			if tt.wantNotModified {
+				return
+			}
+
+			body, _ := io.ReadAll(got.Body)
+			_ = got.Body.Close()
+			if string(body) != tt.wantBody || got.ContentLength != int64(len(tt.wantBody)) || got.ContentRange != tt.wantContentRange {
+				t.Errorf("GetObjectWithOptions() body = %q, length = %d, range = %q", body, got.ContentLength, got.ContentRange)
+			}
+			if got.ETag != head.ETag || !got.LastModified.Equal(lastModified) {
+				t.Errorf("GetObjectWithOptions() ETag = %v, last modified = %v", got.ETag, got.LastModified)
+			}
+		})
+	}
+}
+
+func TestFilesystem_PutAndDeleteObject(t *testing.T) {
+	filesystem, root := newFilesystem(t, map[string]string{"black/lodge/1.0.0/shasum": "315"})
+	before, err := filesystem.ListFingerprints()
+	if err != nil {
+		t.Fatalf("ListFingerprints() error = %v", err)
+	}
+
+	if err := filesystem.PutObject("black/lodge/1.1.0/shasum", strings.NewReader("316")); err != nil {
+		t.Fatalf("PutObject() error = %v", err)
+	}
+	if err := filesystem.PutObject("../shasum", strings.NewReader("316")); err == nil {
+		t.Errorf("PutObject() outside of the root succeeded")
+	}
+	object, err := filesystem.GetObject("black/lodge/1.1.0/shasum")
+	if err != nil {
+		t.Fatalf("GetObject() error = %v", err)
+	}
+	body, _ := io.ReadAll(object.Body)
+	_ = object.Body.Close()
+	if string(body) != "316" {
+		t.Errorf("GetObject() got = %s, want 316", body)
+	}
+
+	after, err := filesystem.ListFingerprints()
+	if err != nil {
+		t.Fatalf("ListFingerprints() error = %v", err)
+	}
+	if len(after) != 2 || after["black/lodge/1.0.0/shasum"] != before["black/lodge/1.0.0/shasum"] {
+		t.Errorf("ListFingerprints() got = %v, before %v", after, before)
+	}
+
+	if err := filesystem.DeleteObject("black/lodge/1.1.0/shasum"); err != nil {
+		t.Fatalf("DeleteObject() error = %v", err)
+	}
+	if err := filesystem.DeleteObject("black/lodge/1.1.0/shasum"); err != nil {
+		t.Errorf("DeleteObject() of missing object error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "black/lodge/1.1.0")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("DeleteObject() left the empty directory: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "black/lodge")); err != nil {
+		t.Errorf("DeleteObject() removed a directory which is not empty: %v", err)
+	}
+}
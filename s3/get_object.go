@@ -5,35 +5,110 @@ import (
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/mdreem/s3_terraform_registry/logger"
 	"io"
+	"time"
 )
 
 type GetObject interface {
 	GetObject(key string) (BucketObject, error)
+	GetObjectWithOptions(key string, options GetObjectOptions) (BucketObject, error)
+}
+
+// GetObjectOptions are passed through to S3 to request parts of an object or to get it only if it changed.
+type GetObjectOptions struct {
+	// Range is the value of an HTTP Range header, e.g. bytes=0-1023.
+	Range           string
+	IfNoneMatch     string
+	IfModifiedSince time.Time
+	// HeadOnly only gets the metadata of the object, Body is nil.
+	HeadOnly bool
 }
 
 type BucketObject struct {
 	Body          io.ReadCloser
 	ContentLength int64
 	ContentType   string
+	ETag          string
+	LastModified  time.Time
+	// ContentRange is set if only a range of the object was requested.
+	ContentRange string
+	// NotModified is set if the object matched the conditions of the request, Body is nil then.
+	NotModified bool
 }
 
 func (bucket Bucket) GetObject(key string) (BucketObject, error) {
-	sess := CreateSession(bucket.region)
-	svc := s3.New(sess)
+	return bucket.GetObjectWithOptions(key, GetObjectOptions{})
+}
+
+func (bucket Bucket) GetObjectWithOptions(key string, options GetObjectOptions) (BucketObject, error) {
+	if options.HeadOnly {
+		return bucket.headObject(key, options)
+	}
+
+	svc := CreateClient(bucket.region)
 
 	object, err := svc.GetObject(&s3.GetObjectInput{
-		Bucket: aws.String(bucket.bucketName),
-		Key:    aws.String(key),
+		Bucket:          aws.String(bucket.bucketName),
+		Key:             aws.String(key),
+		Range:           optionalString(options.Range),
+		IfNoneMatch:     optionalString(options.IfNoneMatch),
+		IfModifiedSince: optionalTime(options.IfModifiedSince),
 	})
 
+	if isNotModified(err) {
+		return BucketObject{NotModified: true}, nil
+	}
 	if err != nil {
-		logger.Sugar.Errorw("an error occurred when getting object", "error", err)
-		return BucketObject{}, err
+		logger.Sugar.Errorw("an error occurred when getting object", "key", key, "error", err)
+		return BucketObject{}, classifyError(err, "unable to get %s", key)
 	}
 
 	return BucketObject{
 		Body:          object.Body,
-		ContentLength: *object.ContentLength,
-		ContentType:   *object.ContentType,
-	}, err
+		ContentLength: aws.Int64Value(object.ContentLength),
+		ContentType:   aws.StringValue(object.ContentType),
+		ETag:          aws.StringValue(object.ETag),
+		LastModified:  aws.TimeValue(object.LastModified),
+		ContentRange:  aws.StringValue(object.ContentRange),
+	}, nil
+}
+
+func (bucket Bucket) headObject(key string, options GetObjectOptions) (BucketObject, error) {
+	svc := CreateClient(bucket.region)
+
+	// ranges are ignored, as HEAD requests are answered with the metadata of the whole object
+	object, err := svc.HeadObject(&s3.HeadObjectInput{
+		Bucket:          aws.String(bucket.bucketName),
+		Key:             aws.String(key),
+		IfNoneMatch:     optionalString(options.IfNoneMatch),
+		IfModifiedSince: optionalTime(options.IfModifiedSince),
+	})
+
+	if isNotModified(err) {
+		return BucketObject{NotModified: true}, nil
+	}
+	if err != nil {
+		logger.Sugar.Errorw("an error occurred when getting metadata of object", "key", key, "error", err)
+		return BucketObject{}, classifyError(err, "unable to get %s", key)
+	}
+
+	return BucketObject{
+		ContentLength: aws.Int64Value(object.ContentLength),
+		ContentType:   aws.StringValue(object.ContentType),
+		ETag:          aws.StringValue(object.ETag),
+		LastModified:  aws.TimeValue(object.LastModified),
+	}, nil
+}
+
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return aws.String(value)
+}
+
+func optionalTime(value time.Time) *time.Time {
+	if value.IsZero() {
+		return nil
+	}
+	return aws.Time(value)
 }
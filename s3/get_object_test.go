@@ -0,0 +1,96 @@
+package s3_test
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	awss3 "github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/mdreem/s3_terraform_registry/internal/testsupport"
+	"github.com/mdreem/s3_terraform_registry/s3"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// recordingS3Client records the input of GetObject and answers with output or err.
+type recordingS3Client struct {
+	s3iface.S3API
+	input  *awss3.GetObjectInput
+	output *awss3.GetObjectOutput
+	err    error
+}
+
+func (client *recordingS3Client) GetObject(input *awss3.GetObjectInput) (*awss3.GetObjectOutput, error) {
+	client.input = input
+	return client.output, client.err
+}
+
+func TestBucket_GetObjectWithOptions(t *testing.T) {
+	lastModified := time.Date(1989, 2, 24, 11, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		options   s3.GetObjectOptions
+		output    *awss3.GetObjectOutput
+		err       error
+		wantInput *awss3.GetObjectInput
+		want      s3.BucketObject
+	}{
+		{
+			name:    "get range",
+			options: s3.GetObjectOptions{Range: "bytes=0-2"},
+			output: &awss3.GetObjectOutput{
+				Body:          testsupport.CreateReaderFor("315"),
+				ContentLength: aws.Int64(3),
+				ContentRange:  aws.String("bytes 0-2/10"),
+				ETag:          aws.String(`"coffee"`),
+				LastModified:  aws.Time(lastModified),
+			},
+			wantInput: &awss3.GetObjectInput{
+				Bucket: aws.String("registry"),
+				Key:    aws.String("black/lodge/1.0.0/shasum"),
+				Range:  aws.String("bytes=0-2"),
+			},
+			want: s3.BucketObject{
+				Body:          testsupport.CreateReaderFor("315"),
+				ContentLength: 3,
+				ContentRange:  "bytes 0-2/10",
+				ETag:          `"coffee"`,
+				LastModified:  lastModified,
+			},
+		},
+		{
+			name:    "get unmodified object",
+			options: s3.GetObjectOptions{IfNoneMatch: `"coffee"`, IfModifiedSince: lastModified},
+			err:     awserr.NewRequestFailure(awserr.New("NotModified", "Not Modified", nil), http.StatusNotModified, "id"),
+			wantInput: &awss3.GetObjectInput{
+				Bucket:          aws.String("registry"),
+				Key:             aws.String("black/lodge/1.0.0/shasum"),
+				IfNoneMatch:     aws.String(`"coffee"`),
+				IfModifiedSince: aws.Time(lastModified),
+			},
+			want: s3.BucketObject{NotModified: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &recordingS3Client{output: tt.output, err: tt.err}
+			originalCreateClient := s3.CreateClient
+			defer func() { s3.CreateClient = originalCreateClient }()
+			s3.CreateClient = func(_ string) s3iface.S3API {
+				return client
+			}
+
+			got, err := s3.New("eu-central-1", "registry").GetObjectWithOptions("black/lodge/1.0.0/shasum", tt.options)
+			if err != nil {
+				t.Fatalf("GetObjectWithOptions() error = %v", err)
+			}
+			if !reflect.DeepEqual(client.input, tt.wantInput) {
+				t.Errorf("GetObjectWithOptions() input = %v, want %v", client.input, tt.wantInput)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetObjectWithOptions() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,27 +4,107 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/mdreem/s3_terraform_registry/logger"
+	"github.com/mdreem/s3_terraform_registry/registryerror"
+	"sort"
 )
 
 type ListObjects interface {
 	ListObjects() ([]string, error)
+	ListObjectsWithPrefix(prefix string, delimiter string) ([]string, error)
+}
+
+// ListETags is implemented by storages which list the ETags of objects along with their keys, so changes of objects
+// can be detected without reading them.
+type ListETags interface {
+	ListETagsWithPrefix(prefix string) (map[string]string, error)
+}
+
+// ListObjectsWithETags returns the sorted keys of all objects starting with prefix and, if bucket lists ETags, their
+// ETags from the same listing. Otherwise the ETags are nil.
+func ListObjectsWithETags(bucket ListObjects, prefix string) ([]string, map[string]string, error) {
+	lister, ok := bucket.(ListETags)
+	if !ok && prefix == "" {
+		objects, err := bucket.ListObjects()
+		return objects, nil, err
+	}
+	if !ok {
+		objects, err := bucket.ListObjectsWithPrefix(prefix, "")
+		return objects, nil, err
+	}
+
+	eTags, err := lister.ListETagsWithPrefix(prefix)
+	if err != nil {
+		return nil, nil, err
+	}
+	objects := make([]string, 0, len(eTags))
+	for key := range eTags {
+		objects = append(objects, key)
+	}
+	sort.Strings(objects)
+	return objects, eTags, nil
 }
 
 func (bucket Bucket) ListObjects() ([]string, error) {
-	sess := CreateSession(bucket.region)
-	svc := s3.New(sess)
+	return bucket.ListObjectsWithPrefix("", "")
+}
 
-	objectList, err := svc.ListObjectsV2(&s3.ListObjectsV2Input{Bucket: aws.String(bucket.bucketName)})
+// ListObjectsWithPrefix returns all keys starting with prefix, following continuation tokens until the listing is
+// complete. If a delimiter is given, keys containing it after the prefix are rolled up into their common prefix,
+// which is returned including the trailing delimiter.
+func (bucket Bucket) ListObjectsWithPrefix(prefix string, delimiter string) ([]string, error) {
+	objects := make([]string, 0)
+	err := bucket.listObjects(prefix, delimiter, func(objectList *s3.ListObjectsV2Output) {
+		for _, commonPrefix := range objectList.CommonPrefixes {
+			objects = append(objects, *commonPrefix.Prefix)
+		}
+		for _, item := range objectList.Contents {
+			objects = append(objects, *item.Key)
+		}
+	})
 	if err != nil {
-		logger.Sugar.Errorw("an error occurred when listing versions", "error", err)
 		return nil, err
 	}
+	return objects, nil
+}
 
-	objects := make([]string, 0)
+// ListETagsWithPrefix returns the ETags of all objects whose keys start with prefix.
+func (bucket Bucket) ListETagsWithPrefix(prefix string) (map[string]string, error) {
+	eTags := make(map[string]string)
+	err := bucket.listObjects(prefix, "", func(objectList *s3.ListObjectsV2Output) {
+		for _, item := range objectList.Contents {
+			eTags[*item.Key] = aws.StringValue(item.ETag)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return eTags, nil
+}
+
+// listObjects passes every page of the listing to addPage, following continuation tokens until the listing is
+// complete.
+func (bucket Bucket) listObjects(prefix string, delimiter string, addPage func(objectList *s3.ListObjectsV2Output)) error {
+	svc := CreateClient(bucket.region)
 
-	for _, item := range objectList.Contents {
-		objects = append(objects, *item.Key)
+	input := &s3.ListObjectsV2Input{Bucket: aws.String(bucket.bucketName)}
+	if prefix != "" {
+		input.Prefix = aws.String(prefix)
+	}
+	if delimiter != "" {
+		input.Delimiter = aws.String(delimiter)
 	}
 
-	return objects, err
+	for {
+		objectList, err := svc.ListObjectsV2(input)
+		if err != nil {
+			logger.Sugar.Errorw("an error occurred when listing objects", "prefix", prefix, "error", err)
+			return registryerror.Upstream(err, "unable to list objects with prefix %s", prefix)
+		}
+		addPage(objectList)
+
+		if !aws.BoolValue(objectList.IsTruncated) || objectList.NextContinuationToken == nil {
+			return nil
+		}
+		input.ContinuationToken = objectList.NextContinuationToken
+	}
 }
@@ -0,0 +1,171 @@
+package s3_test
+
+import (
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/mdreem/s3_terraform_registry/internal/testsupport"
+	"github.com/mdreem/s3_terraform_registry/s3"
+	"reflect"
+	"testing"
+)
+
+func bucketContent() []string {
+	return []string{
+		"black/lodge/1.0.0/terraform-provider-lodge_1.0.0_linux_amd64.zip",
+		"black/lodge/1.0.0/shasum",
+		"black/lodge/1.0.1/terraform-provider-lodge_1.0.1_linux_amd64.zip",
+		"black/lodge/1.0.1/shasum",
+		"black/owl/1.0.0/terraform-provider-owl_1.0.0_linux_amd64.zip",
+		"white/lodge/2.0.0/terraform-provider-lodge_2.0.0_linux_amd64.zip",
+		"white/lodge/2.0.0/shasum",
+	}
+}
+
+func TestBucket_ListObjectsWithPrefix(t *testing.T) {
+	type args struct {
+		prefix    string
+		delimiter string
+	}
+	tests := []struct {
+		name          string
+		pageSize      int
+		args          args
+		want          []string
+		wantListCalls int
+	}{
+		{
+			name:     "list whole bucket in a single page",
+			pageSize: 1000,
+			args:     args{},
+			want: []string{
+				"black/lodge/1.0.0/shasum",
+				"black/lodge/1.0.0/terraform-provider-lodge_1.0.0_linux_amd64.zip",
+				"black/lodge/1.0.1/shasum",
+				"black/lodge/1.0.1/terraform-provider-lodge_1.0.1_linux_amd64.zip",
+				"black/owl/1.0.0/terraform-provider-owl_1.0.0_linux_amd64.zip",
+				"white/lodge/2.0.0/shasum",
+				"white/lodge/2.0.0/terraform-provider-lodge_2.0.0_linux_amd64.zip",
+			},
+			wantListCalls: 1,
+		},
+		{
+			name:     "follow continuation tokens of truncated listings",
+			pageSize: 2,
+			args:     args{},
+			want: []string{
+				"black/lodge/1.0.0/shasum",
+				"black/lodge/1.0.0/terraform-provider-lodge_1.0.0_linux_amd64.zip",
+				"black/lodge/1.0.1/shasum",
+				"black/lodge/1.0.1/terraform-provider-lodge_1.0.1_linux_amd64.zip",
+				"black/owl/1.0.0/terraform-provider-owl_1.0.0_linux_amd64.zip",
+				"white/lodge/2.0.0/shasum",
+				"white/lodge/2.0.0/terraform-provider-lodge_2.0.0_linux_amd64.zip",
+			},
+			wantListCalls: 4,
+		},
+		{
+			name:     "list only keys below prefix",
+			pageSize: 1,
+			args:     args{prefix: "black/lodge/"},
+			want: []string{
+				"black/lodge/1.0.0/shasum",
+				"black/lodge/1.0.0/terraform-provider-lodge_1.0.0_linux_amd64.zip",
+				"black/lodge/1.0.1/shasum",
+				"black/lodge/1.0.1/terraform-provider-lodge_1.0.1_linux_amd64.zip",
+			},
+			wantListCalls: 4,
+		},
+		{
+			name:          "roll up keys at the delimiter",
+			pageSize:      1,
+			args:          args{prefix: "black/", delimiter: "/"},
+			want:          []string{"black/lodge/", "black/owl/"},
+			wantListCalls: 2,
+		},
+	}
+	createClient := s3.CreateClient
+	defer func() {
+		s3.CreateClient = createClient
+	}()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := testsupport.NewTestS3Client(bucketContent(), tt.pageSize)
+			s3.CreateClient = func(region string) s3iface.S3API {
+				return client
+			}
+
+			bucket := s3.New("eu-central-1", "testbucket")
+			got, err := bucket.ListObjectsWithPrefix(tt.args.prefix, tt.args.delimiter)
+			if err != nil {
+				t.Fatalf("ListObjectsWithPrefix() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ListObjectsWithPrefix() got = %v, want %v", got, tt.want)
+			}
+			if client.ListCalls != tt.wantListCalls {
+				t.Errorf("ListObjectsWithPrefix() made %d calls, want %d", client.ListCalls, tt.wantListCalls)
+			}
+		})
+	}
+}
+
+func TestBucket_ListETagsWithPrefix(t *testing.T) {
+	createClient := s3.CreateClient
+	defer func() {
+		s3.CreateClient = createClient
+	}()
+	client := testsupport.NewTestS3Client(bucketContent(), 1)
+	s3.CreateClient = func(region string) s3iface.S3API {
+		return client
+	}
+
+	bucket := s3.New("eu-central-1", "testbucket")
+	got, err := bucket.ListETagsWithPrefix("white/")
+	if err != nil {
+		t.Fatalf("ListETagsWithPrefix() error = %v", err)
+	}
+	want := map[string]string{
+		"white/lodge/2.0.0/shasum": `"white/lodge/2.0.0/shasum"`,
+		"white/lodge/2.0.0/terraform-provider-lodge_2.0.0_linux_amd64.zip": `"white/lodge/2.0.0/terraform-provider-lodge_2.0.0_linux_amd64.zip"`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ListETagsWithPrefix() got = %v, want %v", got, want)
+	}
+	if client.ListCalls != 2 {
+		t.Errorf("ListETagsWithPrefix() made %d calls, want 2", client.ListCalls)
+	}
+}
+
+func TestListObjectsWithETags(t *testing.T) {
+	createClient := s3.CreateClient
+	defer func() {
+		s3.CreateClient = createClient
+	}()
+	client := testsupport.NewTestS3Client(bucketContent(), 1)
+	s3.CreateClient = func(region string) s3iface.S3API {
+		return client
+	}
+
+	objects, eTags, err := s3.ListObjectsWithETags(s3.New("eu-central-1", "testbucket"), "white/")
+	if err != nil {
+		t.Fatalf("ListObjectsWithETags() error = %v", err)
+	}
+	wantObjects := []string{
+		"white/lodge/2.0.0/shasum",
+		"white/lodge/2.0.0/terraform-provider-lodge_2.0.0_linux_amd64.zip",
+	}
+	if !reflect.DeepEqual(objects, wantObjects) {
+		t.Errorf("ListObjectsWithETags() objects = %v, want %v", objects, wantObjects)
+	}
+	if eTags["white/lodge/2.0.0/shasum"] != `"white/lodge/2.0.0/shasum"` || len(eTags) != 2 {
+		t.Errorf("ListObjectsWithETags() eTags = %v", eTags)
+	}
+	if client.ListCalls != 2 {
+		t.Errorf("ListObjectsWithETags() made %d calls, want 2", client.ListCalls)
+	}
+
+	objects, eTags, err = s3.ListObjectsWithETags(testsupport.NewTestBucket(wantObjects), "white/")
+	if err != nil || !reflect.DeepEqual(objects, wantObjects) || eTags != nil {
+		t.Errorf("ListObjectsWithETags() of bucket without ETags = %v, %v, %v", objects, eTags, err)
+	}
+}
@@ -0,0 +1,27 @@
+package s3
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"time"
+)
+
+// Presigner creates URLs which allow downloading objects without credentials until they expire.
+type Presigner interface {
+	PresignGetObject(key string, expiry time.Duration) (string, error)
+}
+
+func (bucket Bucket) PresignGetObject(key string, expiry time.Duration) (string, error) {
+	svc := CreateClient(bucket.region)
+
+	request, _ := svc.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(bucket.bucketName),
+		Key:    aws.String(key),
+	})
+
+	url, err := request.Presign(expiry)
+	if err != nil {
+		return "", classifyError(err, "unable to presign %s", key)
+	}
+	return url, nil
+}
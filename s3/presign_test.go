@@ -0,0 +1,42 @@
+package s3_test
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/mdreem/s3_terraform_registry/s3"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestBucket_PresignGetObject(t *testing.T) {
+	originalCreateSession := s3.CreateSession
+	defer func() { s3.CreateSession = originalCreateSession }()
+	s3.CreateSession = func(region string) *session.Session {
+		return session.Must(session.NewSession(&aws.Config{
+			Region:           aws.String(region),
+			Endpoint:         aws.String("http://localhost:4566"),
+			S3ForcePathStyle: aws.Bool(true),
+			Credentials:      credentials.NewStaticCredentials("test", "test", ""),
+		}))
+	}
+
+	bucket := s3.New("eu-central-1", "registry")
+	presignedURL, err := bucket.PresignGetObject("black/lodge/1.0.0/terraform-provider-lodge_1.0.0_linux_amd64.zip", 5*time.Minute)
+	if err != nil {
+		t.Fatalf("PresignGetObject() error = %v", err)
+	}
+
+	parsedURL, err := url.Parse(presignedURL)
+	if err != nil {
+		t.Fatalf("unable to parse presigned URL %s: %v", presignedURL, err)
+	}
+	const wantedPath = "/registry/black/lodge/1.0.0/terraform-provider-lodge_1.0.0_linux_amd64.zip"
+	if parsedURL.Path != wantedPath {
+		t.Errorf("PresignGetObject() path = %v, want %v", parsedURL.Path, wantedPath)
+	}
+	if expires := parsedURL.Query().Get("X-Amz-Expires"); expires != "300" {
+		t.Errorf("PresignGetObject() expires = %v, want 300", expires)
+	}
+}
@@ -0,0 +1,53 @@
+package s3
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/mdreem/s3_terraform_registry/logger"
+	"io"
+)
+
+// PutObject writes objects into the bucket. Objects are replaced if they already exist. The content is streamed, so
+// large objects can be written from files.
+type PutObject interface {
+	PutObject(key string, content io.ReadSeeker) error
+	DeleteObject(key string) error
+}
+
+func (bucket Bucket) PutObject(key string, content io.ReadSeeker) error {
+	svc := CreateClient(bucket.region)
+
+	contentLength, err := content.Seek(0, io.SeekEnd)
+	if err == nil {
+		_, err = content.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		return classifyError(err, "unable to put %s", key)
+	}
+
+	_, err = svc.PutObject(&s3.PutObjectInput{
+		Bucket:        aws.String(bucket.bucketName),
+		Key:           aws.String(key),
+		Body:          content,
+		ContentLength: aws.Int64(contentLength),
+	})
+	if err != nil {
+		logger.Sugar.Errorw("an error occurred when putting object", "key", key, "error", err)
+		return classifyError(err, "unable to put %s", key)
+	}
+	return nil
+}
+
+func (bucket Bucket) DeleteObject(key string) error {
+	svc := CreateClient(bucket.region)
+
+	_, err := svc.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(bucket.bucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		logger.Sugar.Errorw("an error occurred when deleting object", "key", key, "error", err)
+		return classifyError(err, "unable to delete %s", key)
+	}
+	return nil
+}
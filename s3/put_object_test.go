@@ -0,0 +1,68 @@
+package s3_test
+
+import (
+	"errors"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	awss3 "github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/mdreem/s3_terraform_registry/registryerror"
+	"github.com/mdreem/s3_terraform_registry/s3"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// writingS3Client records the objects written and deleted and answers with err.
+type writingS3Client struct {
+	s3iface.S3API
+	putInput    *awss3.PutObjectInput
+	putContent  string
+	deleteInput *awss3.DeleteObjectInput
+	err         error
+}
+
+func (client *writingS3Client) PutObject(input *awss3.PutObjectInput) (*awss3.PutObjectOutput, error) {
+	client.putInput = input
+	content, _ := io.ReadAll(input.Body)
+	client.putContent = string(content)
+	return &awss3.PutObjectOutput{}, client.err
+}
+
+func (client *writingS3Client) DeleteObject(input *awss3.DeleteObjectInput) (*awss3.DeleteObjectOutput, error) {
+	client.deleteInput = input
+	return &awss3.DeleteObjectOutput{}, client.err
+}
+
+func TestBucket_PutObject(t *testing.T) {
+	client := &writingS3Client{}
+	originalCreateClient := s3.CreateClient
+	defer func() { s3.CreateClient = originalCreateClient }()
+	s3.CreateClient = func(_ string) s3iface.S3API {
+		return client
+	}
+
+	bucket := s3.New("eu-central-1", "registry")
+	if err := bucket.PutObject("black/lodge/1.0.0/key_id", strings.NewReader("315")); err != nil {
+		t.Fatalf("PutObject() error = %v", err)
+	}
+	if aws.StringValue(client.putInput.Bucket) != "registry" || aws.StringValue(client.putInput.Key) != "black/lodge/1.0.0/key_id" {
+		t.Errorf("PutObject() input = %v", client.putInput)
+	}
+	if client.putContent != "315" || aws.Int64Value(client.putInput.ContentLength) != 3 {
+		t.Errorf("PutObject() wrote %q with length %d", client.putContent, aws.Int64Value(client.putInput.ContentLength))
+	}
+
+	if err := bucket.DeleteObject("black/lodge/1.0.0/key_id"); err != nil {
+		t.Fatalf("DeleteObject() error = %v", err)
+	}
+	if aws.StringValue(client.deleteInput.Key) != "black/lodge/1.0.0/key_id" {
+		t.Errorf("DeleteObject() input = %v", client.deleteInput)
+	}
+
+	client.err = awserr.NewRequestFailure(awserr.New("InternalError", "We encountered an internal error", nil), http.StatusInternalServerError, "id")
+	if err := bucket.PutObject("black/lodge/1.0.0/key_id", strings.NewReader("315")); !errors.Is(err, registryerror.ErrUpstream) {
+		t.Errorf("PutObject() error = %v, want upstream error", err)
+	}
+}
@@ -0,0 +1,10 @@
+package schema
+
+// RefreshSummary describes the outcome of a refresh of the whole index.
+type RefreshSummary struct {
+	Duration   string   `json:"duration"`
+	Generation uint64   `json:"generation"`
+	Providers  int      `json:"providers"`
+	Modules    int      `json:"modules"`
+	Errors     []string `json:"errors"`
+}
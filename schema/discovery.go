@@ -2,4 +2,5 @@ package schema
 
 type Discovery struct {
 	ProvidersV1 string `json:"providers.v1"`
+	ModulesV1   string `json:"modules.v1"`
 }
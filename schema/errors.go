@@ -0,0 +1,5 @@
+package schema
+
+type Errors struct {
+	Errors []string `json:"errors"`
+}
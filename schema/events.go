@@ -0,0 +1,6 @@
+package schema
+
+type Refreshed struct {
+	Providers []string `json:"providers"`
+	Modules   []string `json:"modules"`
+}
@@ -0,0 +1,16 @@
+package schema
+
+type MirrorVersion struct{}
+
+type MirrorVersions struct {
+	Versions map[string]MirrorVersion `json:"versions"`
+}
+
+type MirrorArchive struct {
+	URL    string   `json:"url"`
+	Hashes []string `json:"hashes,omitempty"`
+}
+
+type MirrorArchives struct {
+	Archives map[string]MirrorArchive `json:"archives"`
+}
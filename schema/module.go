@@ -0,0 +1,13 @@
+package schema
+
+type ModuleVersion struct {
+	Version string `json:"version"`
+}
+
+type ModuleVersionList struct {
+	Versions []ModuleVersion `json:"versions"`
+}
+
+type ModuleVersions struct {
+	Modules []ModuleVersionList `json:"modules"`
+}
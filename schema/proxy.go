@@ -1,9 +1,20 @@
 package schema
 
-import "io"
+import (
+	"io"
+	"time"
+)
 
 type ProxyResponse struct {
 	Body          io.ReadCloser
 	ContentLength int64
 	ContentType   string
+	ETag          string
+	LastModified  time.Time
+	// ContentRange is set if only a range of the file is returned.
+	ContentRange string
+	// NotModified is set if the file matched the conditions of the request. Body is nil then.
+	NotModified bool
+	// RedirectURL is set instead of Body if the file is downloaded from RedirectURL directly.
+	RedirectURL string
 }
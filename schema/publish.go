@@ -0,0 +1,10 @@
+package schema
+
+// Published describes a provider version which has been published.
+type Published struct {
+	Provider string   `json:"provider"`
+	Version  string   `json:"version"`
+	KeyID    string   `json:"key_id"`
+	Files    []string `json:"files"`
+	Warnings []string `json:"warnings,omitempty"`
+}
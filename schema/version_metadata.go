@@ -0,0 +1,11 @@
+package schema
+
+// VersionMetadata holds everything read from storage which is needed to answer download requests for any platform of
+// a provider version.
+type VersionMetadata struct {
+	Protocols []string
+	ShaSums   map[string]string
+	// Hashes are the further hashes of the archives by file name, e.g. their h1: hashes.
+	Hashes        map[string][]string
+	GpgPublicKeys []GpgPublicKey
+}
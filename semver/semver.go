@@ -0,0 +1,182 @@
+package semver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a version number as defined by Semantic Versioning 2.0.0 (https://semver.org).
+type Version struct {
+	Major      uint64
+	Minor      uint64
+	Patch      uint64
+	Prerelease []string
+	Build      []string
+	original   string
+}
+
+func Parse(version string) (Version, error) {
+	rest := version
+
+	var build []string
+	if index := strings.Index(rest, "+"); index >= 0 {
+		var err error
+		build, err = parseIdentifiers(rest[index+1:], false)
+		if err != nil {
+			return Version{}, fmt.Errorf("invalid build metadata in version %s: %v", version, err)
+		}
+		rest = rest[:index]
+	}
+
+	var prerelease []string
+	if index := strings.Index(rest, "-"); index >= 0 {
+		var err error
+		prerelease, err = parseIdentifiers(rest[index+1:], true)
+		if err != nil {
+			return Version{}, fmt.Errorf("invalid prerelease in version %s: %v", version, err)
+		}
+		rest = rest[:index]
+	}
+
+	parts := strings.Split(rest, ".")
+	if len(parts) != 3 {
+		return Version{}, fmt.Errorf("version %s does not consist of major, minor and patch version", version)
+	}
+
+	numbers := make([]uint64, len(parts))
+	for i, part := range parts {
+		if !isNumeric(part) {
+			return Version{}, fmt.Errorf("version %s contains invalid number %q", version, part)
+		}
+		number, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return Version{}, fmt.Errorf("version %s contains invalid number %q: %v", version, part, err)
+		}
+		numbers[i] = number
+	}
+
+	return Version{
+		Major:      numbers[0],
+		Minor:      numbers[1],
+		Patch:      numbers[2],
+		Prerelease: prerelease,
+		Build:      build,
+		original:   version,
+	}, nil
+}
+
+func (version Version) String() string {
+	return version.original
+}
+
+func (version Version) IsPrerelease() bool {
+	return len(version.Prerelease) > 0
+}
+
+// Compare returns -1, 0 or 1 if version has lower, equal or higher precedence than other. Build metadata is not
+// taken into account.
+func (version Version) Compare(other Version) int {
+	if result := compareNumbers(version.Major, other.Major); result != 0 {
+		return result
+	}
+	if result := compareNumbers(version.Minor, other.Minor); result != 0 {
+		return result
+	}
+	if result := compareNumbers(version.Patch, other.Patch); result != 0 {
+		return result
+	}
+
+	// a version without prerelease has higher precedence than any prerelease of it
+	switch {
+	case len(version.Prerelease) == 0 && len(other.Prerelease) == 0:
+		return 0
+	case len(version.Prerelease) == 0:
+		return 1
+	case len(other.Prerelease) == 0:
+		return -1
+	}
+
+	for i := 0; i < len(version.Prerelease) && i < len(other.Prerelease); i++ {
+		if result := compareIdentifiers(version.Prerelease[i], other.Prerelease[i]); result != 0 {
+			return result
+		}
+	}
+	return compareNumbers(uint64(len(version.Prerelease)), uint64(len(other.Prerelease)))
+}
+
+func parseIdentifiers(identifiers string, isPrerelease bool) ([]string, error) {
+	parts := strings.Split(identifiers, ".")
+	for _, part := range parts {
+		if part == "" {
+			return nil, fmt.Errorf("empty identifier")
+		}
+		for _, character := range part {
+			if !isIdentifierCharacter(character) {
+				return nil, fmt.Errorf("identifier %q contains invalid character %q", part, character)
+			}
+		}
+		if isPrerelease && isDigits(part) && len(part) > 1 && part[0] == '0' {
+			return nil, fmt.Errorf("numeric identifier %q has leading zeroes", part)
+		}
+	}
+	return parts, nil
+}
+
+func compareIdentifiers(identifier string, other string) int {
+	identifierIsNumeric := isDigits(identifier)
+	otherIsNumeric := isDigits(other)
+
+	switch {
+	case identifierIsNumeric && otherIsNumeric:
+		// identifiers without leading zeroes can be compared by length first to avoid overflows
+		if len(identifier) != len(other) {
+			return compareNumbers(uint64(len(identifier)), uint64(len(other)))
+		}
+		return strings.Compare(identifier, other)
+	case identifierIsNumeric:
+		return -1
+	case otherIsNumeric:
+		return 1
+	default:
+		return strings.Compare(identifier, other)
+	}
+}
+
+func compareNumbers(number uint64, other uint64) int {
+	switch {
+	case number < other:
+		return -1
+	case number > other:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// isNumeric checks whether the given string is a number without leading zeroes.
+func isNumeric(number string) bool {
+	if !isDigits(number) {
+		return false
+	}
+	return len(number) == 1 || number[0] != '0'
+}
+
+func isDigits(value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, character := range value {
+		if character < '0' || character > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func isIdentifierCharacter(character rune) bool {
+	return character >= '0' && character <= '9' ||
+		character >= 'a' && character <= 'z' ||
+		character >= 'A' && character <= 'Z' ||
+		character == '-'
+}
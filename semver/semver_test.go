@@ -0,0 +1,108 @@
+package semver
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		want    Version
+		wantErr bool
+	}{
+		{
+			name:    "parse release version",
+			version: "1.10.0",
+			want:    Version{Major: 1, Minor: 10, Patch: 0, original: "1.10.0"},
+		},
+		{
+			name:    "parse prerelease and build metadata",
+			version: "2.0.0-rc.1+build-5.sha",
+			want: Version{
+				Major:      2,
+				Minor:      0,
+				Patch:      0,
+				Prerelease: []string{"rc", "1"},
+				Build:      []string{"build-5", "sha"},
+				original:   "2.0.0-rc.1+build-5.sha",
+			},
+		},
+		{
+			name:    "allow leading zeroes in build metadata",
+			version: "1.0.0+001",
+			want:    Version{Major: 1, Build: []string{"001"}, original: "1.0.0+001"},
+		},
+		{name: "reject leading v", version: "v1.0.0", wantErr: true},
+		{name: "reject missing patch version", version: "1.0", wantErr: true},
+		{name: "reject leading zeroes", version: "01.0.0", wantErr: true},
+		{name: "reject leading zeroes in numeric prerelease", version: "1.0.0-01", wantErr: true},
+		{name: "reject empty prerelease identifier", version: "1.0.0-alpha..1", wantErr: true},
+		{name: "reject invalid characters", version: "1.0.0-alpha_1", wantErr: true},
+		{name: "reject arbitrary directory names", version: "latest", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.version)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() got = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVersion_Compare(t *testing.T) {
+	// ordered by precedence as given in the examples of the specification
+	ordered := []string{
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+		"1.9.0",
+		"1.10.0",
+		"1.11.0",
+		"2.0.0",
+	}
+
+	shuffled := []string{
+		"1.10.0", "1.0.0-beta.11", "2.0.0", "1.0.0-alpha.beta", "1.0.0", "1.0.0-alpha",
+		"1.9.0", "1.0.0-rc.1", "1.11.0", "1.0.0-beta.2", "1.0.0-alpha.1", "1.0.0-beta",
+	}
+
+	versions := make([]Version, 0)
+	for _, version := range shuffled {
+		parsed, err := Parse(version)
+		if err != nil {
+			t.Fatalf("unable to parse %s: %v", version, err)
+		}
+		versions = append(versions, parsed)
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].Compare(versions[j]) < 0
+	})
+
+	got := make([]string, 0)
+	for _, version := range versions {
+		got = append(got, version.String())
+	}
+	if !reflect.DeepEqual(got, ordered) {
+		t.Errorf("Compare() sorted to %v, want %v", got, ordered)
+	}
+
+	withBuild, _ := Parse("1.0.0+build.1")
+	withoutBuild, _ := Parse("1.0.0")
+	if withBuild.Compare(withoutBuild) != 0 {
+		t.Errorf("Compare() should ignore build metadata")
+	}
+}
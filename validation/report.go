@@ -0,0 +1,64 @@
+package validation
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Finding is a problem found at a path of the bucket.
+type Finding struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// Report lists the problems found in the bucket. Errors break the registry for the affected versions, warnings
+// point to files which are ignored.
+type Report struct {
+	Versions int       `json:"versions"`
+	Errors   []Finding `json:"errors"`
+	Warnings []Finding `json:"warnings"`
+}
+
+func newReport() Report {
+	return Report{Errors: make([]Finding, 0), Warnings: make([]Finding, 0)}
+}
+
+func (report *Report) addError(path string, format string, a ...interface{}) {
+	report.Errors = append(report.Errors, Finding{Path: path, Message: fmt.Sprintf(format, a...)})
+}
+
+func (report *Report) addWarning(path string, format string, a ...interface{}) {
+	report.Warnings = append(report.Warnings, Finding{Path: path, Message: fmt.Sprintf(format, a...)})
+}
+
+func (report *Report) sort() {
+	for _, findings := range [][]Finding{report.Errors, report.Warnings} {
+		sort.SliceStable(findings, func(i, j int) bool {
+			return findings[i].Path < findings[j].Path
+		})
+	}
+}
+
+func (report Report) HasErrors() bool {
+	return len(report.Errors) > 0
+}
+
+// WriteText writes the report in a human readable form.
+func (report Report) WriteText(writer io.Writer) error {
+	lines := make([]string, 0, len(report.Errors)+len(report.Warnings)+1)
+	for _, finding := range report.Errors {
+		lines = append(lines, fmt.Sprintf("ERROR   %s: %s", finding.Path, finding.Message))
+	}
+	for _, finding := range report.Warnings {
+		lines = append(lines, fmt.Sprintf("WARNING %s: %s", finding.Path, finding.Message))
+	}
+	lines = append(lines, fmt.Sprintf("checked %d versions: %d errors, %d warnings", report.Versions, len(report.Errors), len(report.Warnings)))
+
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(writer, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
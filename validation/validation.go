@@ -0,0 +1,311 @@
+package validation
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/mdreem/s3_terraform_registry/moduledata"
+	"github.com/mdreem/s3_terraform_registry/pgp"
+	"github.com/mdreem/s3_terraform_registry/providerdata"
+	"github.com/mdreem/s3_terraform_registry/s3"
+	"github.com/mdreem/s3_terraform_registry/semver"
+	"sort"
+	"strings"
+)
+
+// Bucket is the storage which is validated.
+type Bucket interface {
+	s3.ListObjects
+	s3.GetObject
+}
+
+// requiredFiles must exist in every provider version.
+var requiredFiles = []string{"shasum", "shasum.sig"}
+
+// keyFiles are the files announcing the key of a version. They are only required if the version has no keys in
+// providerdata.KeysDirectory and inherits none.
+var keyFiles = []string{"keyfile", "key_id"}
+
+// Validate checks every provider and module version in bucket against the layout the registry expects.
+func Validate(bucket Bucket) (Report, error) {
+	objects, err := bucket.ListObjects()
+	if err != nil {
+		return Report{}, err
+	}
+
+	report := newReport()
+	providerVersions := make(map[string][]string)
+	moduleVersions := make(map[string][]string)
+	inheritedKeys := make(map[string][]string)
+	for _, object := range objects {
+		if strings.HasSuffix(object, "/") {
+			continue
+		}
+
+		if providerdata.IsInheritedKeyFile(object) {
+			directory := object[:strings.LastIndex(object, "/")+1]
+			inheritedKeys[directory] = append(inheritedKeys[directory], strings.TrimPrefix(object, directory))
+			continue
+		}
+
+		if strings.HasPrefix(object, moduledata.Prefix) {
+			moduleKey, ok := moduledata.ParseModuleKey(object)
+			if !ok {
+				report.addWarning(object, "is not part of a module version, expected %s<namespace>/<name>/<system>/<version>/<archive>", moduledata.Prefix)
+				continue
+			}
+			versionPath := strings.TrimSuffix(object, "/"+moduleKey.Filename)
+			moduleVersions[versionPath] = append(moduleVersions[versionPath], moduleKey.Filename)
+			continue
+		}
+
+		parts := strings.SplitN(object, "/", 4)
+		if len(parts) == 4 && strings.Contains(parts[3], "/") && !strings.HasPrefix(parts[3], providerdata.KeysDirectory+"/") {
+			parts = nil
+		}
+		if len(parts) != 4 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			report.addWarning(object, "is not part of a provider version, expected <namespace>/<type>/<version>/<file>")
+			continue
+		}
+		versionPath := strings.Join(parts[:3], "/")
+		providerVersions[versionPath] = append(providerVersions[versionPath], parts[3])
+	}
+
+	inheritedKeyCounts := make(map[string]int)
+	for _, directory := range sortedKeys(inheritedKeys) {
+		inheritedKeyCounts[directory] = validateKeysDirectory(bucket, &report, directory, inheritedKeys[directory])
+	}
+	for _, versionPath := range sortedKeys(providerVersions) {
+		validateProviderVersion(bucket, &report, versionPath, providerVersions[versionPath], inheritedKeyCounts)
+	}
+	for _, versionPath := range sortedKeys(moduleVersions) {
+		validateModuleVersion(&report, versionPath, moduleVersions[versionPath])
+	}
+
+	report.sort()
+	return report, nil
+}
+
+func validateProviderVersion(bucket Bucket, report *Report, versionPath string, filenames []string, inheritedKeyCounts map[string]int) {
+	report.Versions++
+	parts := strings.Split(versionPath, "/")
+	providerType, version := parts[1], parts[2]
+
+	if _, err := semver.Parse(version); err != nil {
+		report.addWarning(versionPath, "is skipped as %s is not a valid semantic version", version)
+		return
+	}
+
+	files := make(map[string]bool)
+	archives := make([]string, 0)
+	directoryKeys := make([]string, 0)
+	for _, filename := range filenames {
+		files[filename] = true
+		switch {
+		case strings.HasPrefix(filename, providerdata.KeysDirectory+"/"):
+			directoryKeys = append(directoryKeys, strings.TrimPrefix(filename, providerdata.KeysDirectory+"/"))
+		case isRequiredFile(filename) || filename == providerdata.ManifestFilename || filename == providerdata.HashesFilename:
+		case strings.HasSuffix(filename, ".zip"):
+			if _, _, ok := providerdata.ParseArtifactFilename(providerType, version, filename); !ok {
+				report.addError(versionPath+"/"+filename, "is not named %s", providerdata.ArtifactFilename(providerType, version, "<os>", "<arch>"))
+				continue
+			}
+			archives = append(archives, filename)
+		default:
+			report.addWarning(versionPath+"/"+filename, "is not used by the registry")
+		}
+	}
+
+	if len(archives) == 0 {
+		report.addError(versionPath, "contains no archives")
+	}
+	for _, filename := range requiredFiles {
+		if !files[filename] {
+			report.addError(versionPath, "%s is missing", filename)
+		}
+	}
+
+	if files["shasum"] {
+		validateShaSums(bucket, report, versionPath, archives)
+	}
+	validateKeys(bucket, report, versionPath, files, directoryKeys, inheritedKeyCounts)
+	if files[providerdata.ManifestFilename] {
+		manifestPath := versionPath + "/" + providerdata.ManifestFilename
+		if manifest, err := readObject(bucket, manifestPath); err != nil {
+			report.addError(manifestPath, "unable to read: %v", err)
+		} else if _, err := providerdata.ParseManifest(manifest); err != nil {
+			report.addError(manifestPath, "the version is skipped: %v", err)
+		}
+	}
+	if files[providerdata.HashesFilename] {
+		hashesPath := versionPath + "/" + providerdata.HashesFilename
+		if hashes, err := readObject(bucket, hashesPath); err != nil {
+			report.addError(hashesPath, "unable to read: %v", err)
+		} else if _, err := providerdata.ParseHashes(hashes); err != nil {
+			report.addError(hashesPath, "%v", err)
+		}
+	}
+}
+
+// validateKeys checks that the version announces at least one key, either in keyfile or in the keys directory, or
+// inherits one, and that all key files can be read. key_id is only needed if keyfile contains a single key.
+func validateKeys(bucket Bucket, report *Report, versionPath string, files map[string]bool, directoryKeys []string, inheritedKeyCounts map[string]int) {
+	keys := validateKeysDirectory(bucket, report, versionPath+"/"+providerdata.KeysDirectory+"/", directoryKeys)
+	for _, directory := range providerdata.InheritedKeysDirectories(versionPath) {
+		keys += inheritedKeyCounts[directory]
+	}
+
+	if !files["keyfile"] {
+		if keys == 0 {
+			for _, filename := range keyFiles {
+				report.addError(versionPath, "%s is missing", filename)
+			}
+		} else if files["key_id"] {
+			report.addWarning(versionPath+"/key_id", "is not used by the registry without keyfile")
+		}
+		return
+	}
+
+	keyfile, err := readObject(bucket, versionPath+"/keyfile")
+	if err != nil {
+		report.addError(versionPath+"/keyfile", "unable to read: %v", err)
+		return
+	}
+	entities, err := pgp.ReadPublicKeys(keyfile)
+	if err != nil {
+		report.addError(versionPath+"/keyfile", "%v", err)
+	}
+	if len(entities) > 1 {
+		return
+	}
+
+	if !files["key_id"] {
+		report.addError(versionPath, "key_id is missing")
+	} else if keyID, err := readObject(bucket, versionPath+"/key_id"); err != nil {
+		report.addError(versionPath+"/key_id", "unable to read: %v", err)
+	} else if strings.TrimSpace(keyID) == "" {
+		report.addError(versionPath+"/key_id", "is empty")
+	}
+}
+
+// validateKeysDirectory checks the key files and their metadata in directory and returns the number of key files.
+func validateKeysDirectory(bucket Bucket, report *Report, directory string, filenames []string) int {
+	keys := make(map[string]bool)
+	metadata := make([]string, 0)
+	for _, filename := range filenames {
+		name, isMetadata, ok := providerdata.ParseKeyFilename(filename)
+		switch {
+		case !ok:
+			report.addWarning(directory+filename, "is not used by the registry")
+		case isMetadata:
+			metadata = append(metadata, name)
+		default:
+			keys[name] = true
+			if armoredKey, err := readObject(bucket, directory+filename); err != nil {
+				report.addError(directory+filename, "unable to read: %v", err)
+			} else if _, err := pgp.ReadPublicKeys(armoredKey); err != nil {
+				report.addError(directory+filename, "%v", err)
+			}
+		}
+	}
+
+	for _, name := range metadata {
+		metadataPath := directory + name + ".json"
+		if !keys[name] {
+			report.addWarning(metadataPath, "belongs to no key, expected %s.asc", name)
+			continue
+		}
+		if content, err := readObject(bucket, metadataPath); err != nil {
+			report.addError(metadataPath, "unable to read: %v", err)
+		} else if _, err := providerdata.ParseKeyMetadata(content); err != nil {
+			report.addError(metadataPath, "%v", err)
+		}
+	}
+	return len(keys)
+}
+
+// validateShaSums checks that every archive is listed in the shasum file. Lines without archive are only reported
+// as warnings, as the registry never offers their platforms.
+func validateShaSums(bucket Bucket, report *Report, versionPath string, archives []string) {
+	shaSumPath := versionPath + "/shasum"
+	shaSumFile, err := readObject(bucket, shaSumPath)
+	if err != nil {
+		report.addError(shaSumPath, "unable to read: %v", err)
+		return
+	}
+	shaSums, err := providerdata.ParseShaSums(shaSumFile)
+	if err != nil {
+		report.addError(shaSumPath, "%v", err)
+		return
+	}
+
+	listed := make(map[string]bool)
+	for _, archive := range archives {
+		listed[archive] = true
+		if _, ok := shaSums[archive]; !ok {
+			report.addError(versionPath+"/"+archive, "is not listed in the shasum file")
+		}
+	}
+	for _, filename := range sortedKeys(shaSums) {
+		if !listed[filename] && strings.HasSuffix(filename, ".zip") {
+			report.addWarning(shaSumPath, "lists %s, which does not exist", filename)
+		}
+	}
+}
+
+func validateModuleVersion(report *Report, versionPath string, filenames []string) {
+	report.Versions++
+	version := versionPath[strings.LastIndex(versionPath, "/")+1:]
+	if _, err := semver.Parse(version); err != nil {
+		report.addWarning(versionPath, "is skipped as %s is not a valid semantic version", version)
+		return
+	}
+
+	archives := 0
+	for _, filename := range filenames {
+		if moduledata.IsArchive(filename) {
+			archives++
+		} else {
+			report.addWarning(versionPath+"/"+filename, "is not used by the registry")
+		}
+	}
+	if archives == 0 {
+		report.addError(versionPath, "contains no archive")
+	}
+	if archives > 1 {
+		report.addWarning(versionPath, "contains %d archives, only the first one is served", archives)
+	}
+}
+
+func isRequiredFile(filename string) bool {
+	for _, requiredFile := range append(requiredFiles, keyFiles...) {
+		if filename == requiredFile {
+			return true
+		}
+	}
+	return false
+}
+
+func readObject(bucket Bucket, key string) (string, error) {
+	object, err := bucket.GetObject(key)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = object.Body.Close()
+	}()
+
+	buffer := new(bytes.Buffer)
+	if _, err := buffer.ReadFrom(object.Body); err != nil {
+		return "", fmt.Errorf("unable to read %s: %v", key, err)
+	}
+	return buffer.String(), nil
+}
+
+func sortedKeys[V any](entries map[string]V) []string {
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
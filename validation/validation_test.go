@@ -0,0 +1,184 @@
+package validation
+
+import (
+	"bytes"
+	"github.com/mdreem/s3_terraform_registry/internal/testsupport"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func validVersion(key testsupport.SigningKey, basePath string, archives ...string) map[string]string {
+	objects := map[string]string{
+		basePath + "/shasum.sig": "signature",
+		basePath + "/keyfile":    key.ArmoredPublicKey(),
+		basePath + "/key_id":     key.KeyID(),
+	}
+	shaSums := ""
+	for _, archive := range archives {
+		objects[basePath+"/"+archive] = "archive"
+		shaSums += "a2c2e2d3a16ba8a8b9ba9a6a2f0d2d3fa2c2e2d3a16ba8a8b9ba9a6a2f0d2d3f  " + archive + "\n"
+	}
+	objects[basePath+"/shasum"] = shaSums
+	return objects
+}
+
+func TestValidate(t *testing.T) {
+	key := testsupport.NewSigningKey("Dale Cooper")
+
+	objects := validVersion(key, "black/lodge/1.0.0", "terraform-provider-lodge_1.0.0_linux_amd64.zip")
+	for key, content := range validVersion(key, "black/lodge/1.0.1", "terraform-provider-lodge_1.0.1_linux_amd64.zip") {
+		objects[key] = content
+	}
+	// archive without shasum line, wrongly named archive and missing key_id
+	objects["black/lodge/1.0.1/terraform-provider-lodge_1.0.1_darwin_arm64.zip"] = "archive"
+	objects["black/lodge/1.0.1/terraform-provider-owl_1.0.1_linux_amd64.zip"] = "archive"
+	delete(objects, "black/lodge/1.0.1/key_id")
+	// valid and invalid hashes files
+	objects["black/lodge/1.0.0/hashes"] = "h1:tJxD0RVk2gAwR1UcUxr4d4s5dUbXSCQv0lTTexHGjRY=  terraform-provider-lodge_1.0.0_linux_amd64.zip\n"
+	objects["black/lodge/1.0.1/hashes"] = "tJxD0RVk2gAwR1UcUxr4d4s5dUbXSCQv0lTTexHGjRY=  terraform-provider-lodge_1.0.1_linux_amd64.zip\n"
+	// version without archives
+	objects["black/lodge/1.0.2/shasum"] = ""
+	// ignored files
+	objects["black/lodge/latest/terraform-provider-lodge_latest_linux_amd64.zip"] = "archive"
+	objects["black/lodge/1.0.0/notes.txt"] = "damn fine coffee"
+	objects["README.md"] = "registry"
+	// modules
+	objects["modules/black/lodge/aws/1.0.0/lodge.tar.gz"] = "archive"
+	objects["modules/black/lodge/aws/1.0.1/notes.txt"] = "damn fine coffee"
+
+	report, err := Validate(testsupport.NewMemoryBucket(objects))
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	wantErrors := []Finding{
+		{Path: "black/lodge/1.0.1", Message: "key_id is missing"},
+		{Path: "black/lodge/1.0.1/hashes", Message: "line 1 of hashes does not contain a hash with scheme"},
+		{Path: "black/lodge/1.0.1/terraform-provider-lodge_1.0.1_darwin_arm64.zip", Message: "is not listed in the shasum file"},
+		{Path: "black/lodge/1.0.1/terraform-provider-owl_1.0.1_linux_amd64.zip", Message: "is not named terraform-provider-lodge_1.0.1_<os>_<arch>.zip"},
+		{Path: "black/lodge/1.0.2", Message: "contains no archives"},
+		{Path: "black/lodge/1.0.2", Message: "shasum.sig is missing"},
+		{Path: "black/lodge/1.0.2", Message: "keyfile is missing"},
+		{Path: "black/lodge/1.0.2", Message: "key_id is missing"},
+		{Path: "modules/black/lodge/aws/1.0.1", Message: "contains no archive"},
+	}
+	if !reflect.DeepEqual(report.Errors, wantErrors) {
+		t.Errorf("Validate() errors = %v, want %v", report.Errors, wantErrors)
+	}
+
+	wantWarnings := []Finding{
+		{Path: "README.md", Message: "is not part of a provider version, expected <namespace>/<type>/<version>/<file>"},
+		{Path: "black/lodge/1.0.0/notes.txt", Message: "is not used by the registry"},
+		{Path: "black/lodge/latest", Message: "is skipped as latest is not a valid semantic version"},
+		{Path: "modules/black/lodge/aws/1.0.1/notes.txt", Message: "is not used by the registry"},
+	}
+	if !reflect.DeepEqual(report.Warnings, wantWarnings) {
+		t.Errorf("Validate() warnings = %v, want %v", report.Warnings, wantWarnings)
+	}
+	if report.Versions != 6 || !report.HasErrors() {
+		t.Errorf("Validate() versions = %d, has errors = %v", report.Versions, report.HasErrors())
+	}
+
+	text := new(bytes.Buffer)
+	if err := report.WriteText(text); err != nil {
+		t.Fatalf("WriteText() error = %v", err)
+	}
+	if !strings.HasSuffix(text.String(), "checked 6 versions: 9 errors, 4 warnings\n") {
+		t.Errorf("WriteText() got = %v", text.String())
+	}
+}
+
+func TestValidate_ValidBucket(t *testing.T) {
+	key := testsupport.NewSigningKey("Dale Cooper")
+	objects := validVersion(key, "black/lodge/1.0.0", "terraform-provider-lodge_1.0.0_linux_amd64.zip")
+
+	report, err := Validate(testsupport.NewMemoryBucket(objects))
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if report.HasErrors() || len(report.Warnings) != 0 {
+		t.Errorf("Validate() got = %+v", report)
+	}
+}
+
+func TestValidate_KeysDirectory(t *testing.T) {
+	key := testsupport.NewSigningKey("Dale Cooper")
+	otherKey := testsupport.NewSigningKey("Gordon Cole")
+
+	objects := validVersion(key, "black/lodge/1.0.0", "terraform-provider-lodge_1.0.0_linux_amd64.zip")
+	delete(objects, "black/lodge/1.0.0/keyfile")
+	delete(objects, "black/lodge/1.0.0/key_id")
+	objects["black/lodge/1.0.0/keys/cooper.asc"] = key.ArmoredPublicKey()
+	objects["black/lodge/1.0.0/keys/cole.asc"] = "not a key"
+	objects["black/lodge/1.0.0/keys/cole.json"] = `{"source": "FBI"}`
+	objects["black/lodge/1.0.0/keys/earle.json"] = `{}`
+	objects["black/lodge/1.0.0/keys/notes.txt"] = "damn fine coffee"
+	// a keyfile with several keys needs no key_id
+	for key, content := range validVersion(key, "black/lodge/1.0.1", "terraform-provider-lodge_1.0.1_linux_amd64.zip") {
+		objects[key] = content
+	}
+	objects["black/lodge/1.0.1/keyfile"] = key.ArmoredPublicKey() + otherKey.ArmoredPublicKey()
+	delete(objects, "black/lodge/1.0.1/key_id")
+
+	report, err := Validate(testsupport.NewMemoryBucket(objects))
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	wantErrors := []Finding{
+		{Path: "black/lodge/1.0.0/keys/cole.asc", Message: "the public key is not ASCII armored"},
+	}
+	if !reflect.DeepEqual(report.Errors, wantErrors) {
+		t.Errorf("Validate() errors = %v, want %v", report.Errors, wantErrors)
+	}
+	wantWarnings := []Finding{
+		{Path: "black/lodge/1.0.0/keys/earle.json", Message: "belongs to no key, expected earle.asc"},
+		{Path: "black/lodge/1.0.0/keys/notes.txt", Message: "is not used by the registry"},
+	}
+	if !reflect.DeepEqual(report.Warnings, wantWarnings) {
+		t.Errorf("Validate() warnings = %v, want %v", report.Warnings, wantWarnings)
+	}
+}
+
+func TestValidate_InheritedKeys(t *testing.T) {
+	key := testsupport.NewSigningKey("Dale Cooper")
+
+	objects := make(map[string]string)
+	for _, version := range []string{"1.0.0", "1.0.1"} {
+		for key, content := range validVersion(key, "black/lodge/"+version, "terraform-provider-lodge_"+version+"_linux_amd64.zip") {
+			objects[key] = content
+		}
+		delete(objects, "black/lodge/"+version+"/keyfile")
+		delete(objects, "black/lodge/"+version+"/key_id")
+	}
+	for key, content := range validVersion(key, "white/lodge/1.0.0", "terraform-provider-lodge_1.0.0_linux_amd64.zip") {
+		objects[key] = content
+	}
+	delete(objects, "white/lodge/1.0.0/keyfile")
+	objects["black/keys/cooper.asc"] = key.ArmoredPublicKey()
+	objects["black/keys/cooper.json"] = `{"source": "FBI"}`
+	objects["keys/cole.asc"] = "not a key"
+
+	report, err := Validate(testsupport.NewMemoryBucket(objects))
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	// white/lodge inherits the invalid key of the bucket root
+	wantErrors := []Finding{
+		{Path: "keys/cole.asc", Message: "the public key is not ASCII armored"},
+	}
+	if !reflect.DeepEqual(report.Errors, wantErrors) {
+		t.Errorf("Validate() errors = %v, want %v", report.Errors, wantErrors)
+	}
+	wantWarnings := []Finding{
+		{Path: "white/lodge/1.0.0/key_id", Message: "is not used by the registry without keyfile"},
+	}
+	if !reflect.DeepEqual(report.Warnings, wantWarnings) {
+		t.Errorf("Validate() warnings = %v, want %v", report.Warnings, wantWarnings)
+	}
+	if report.Versions != 3 {
+		t.Errorf("Validate() versions = %d, want 3", report.Versions)
+	}
+}